@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"path"
+	"strings"
+)
+
+// Name of the layout AMP versions of posts are rendered with, found at
+// _layouts/amp.html
+const ampLayout = "amp"
+
+// Helper function that returns the AMP versions of the posts, when enabled
+// with amp: true in the _config.yml, written to amp/ below the permalink of
+// each post, e.g. /2013/02/14/first/amp/ for /2013/02/14/first/
+//
+// The AMP version is a copy of the post rendered with the amp layout. The
+// post gets the amp_url of its AMP version, and the AMP version gets the
+// canonical_url of the post, so the two can be linked in templates.
+func (s *Site) ampPages() []Page {
+	if !s.Conf.GetBool("amp", false) {
+		return nil
+	}
+
+	pages := []Page{}
+	for _, post := range s.posts {
+		if post.GetString("output_ext") != ".html" {
+			continue
+		}
+		dir := strings.TrimSuffix(post.GetUrl(), "index.html")
+		if !strings.HasSuffix(dir, "/") {
+			dir = strings.TrimSuffix(dir, path.Ext(dir)) + "/"
+		}
+
+		amp := Page{}
+		for k, v := range post {
+			if k != "redirect_from" && k != "amp_url" {
+				amp[k] = v
+			}
+		}
+		amp["url"] = dir + "amp/index.html"
+		amp["layout"] = ampLayout
+		amp["canonical_url"] = s.pageUrl(post)
+		post["amp_url"] = s.pageUrl(amp)
+		pages = append(pages, amp)
+	}
+	return pages
+}
+
+// Helper function that links a post and its AMP version, adding a link to
+// the AMP version to the head of the post, and a canonical link to the post
+// to the head of the AMP version, unless its layout already has one. Other
+// copies of a page with a canonical_url, such as variants, get it the same.
+func ampLinks(page Page, b []byte) []byte {
+	if url := page.GetString("amp_url"); url != "" {
+		return injectHead(b, fmt.Sprintf(`<link rel="amphtml" href="%s">`, html.EscapeString(url)))
+	}
+	if url := page.GetString("canonical_url"); url != "" && !strings.Contains(string(b), `rel="canonical"`) {
+		return injectHead(b, fmt.Sprintf(`<link rel="canonical" href="%s">`, html.EscapeString(url)))
+	}
+	return b
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAmpPages(t *testing.T) {
+	site, cleanup := newTestSite(t, map[string]string{
+		"_config.yml":                 "url: http://example.com\namp: true\n",
+		"_layouts/default.html":       "<html><head></head><body>{{.content}}</body></html>",
+		"_layouts/amp.html":           "<html amp><head>{{seo}}</head><body>{{.content}}</body></html>",
+		"_posts/2013-02-14-first.md":  "---\ntitle: First\nredirect_from: /old/\n---\nfirst",
+		"_posts/2013-02-15-second.md": "---\ntitle: Second\npermalink: /:year/:title.html\n---\nsecond",
+		"about.md":                    "---\ntitle: About\n---\nabout"})
+	defer cleanup()
+
+	if err := site.Generate(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]string{
+		"first/index.html":           "<html><head><link rel=\"amphtml\" href=\"http://example.com/first/amp/\"></head><body><p>first</p>\n</body></html>",
+		"2013/second/amp/index.html": "<link rel=\"canonical\" href=\"http://example.com/2013/second.html\">",
+		"about.html":                 "<html><head></head><body><p>about</p>\n</body></html>",
+	}
+	for fn, expected := range tests {
+		b, err := ioutil.ReadFile(filepath.Join(site.Dest, fn))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(b), expected) {
+			t.Errorf("Expected %s to contain [%s] got [%s]", fn, expected, b)
+		}
+	}
+
+	// the canonical link of the seo tags isn't repeated
+	b, _ := ioutil.ReadFile(filepath.Join(site.Dest, "first/amp/index.html"))
+	if n := strings.Count(string(b), `rel="canonical"`); n != 1 || strings.Contains(string(b), "amphtml") {
+		t.Errorf("Expected a single canonical link in the AMP version, got [%s]", b)
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"launchpad.net/goyaml"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+var ErrBadBulkPost = errors.New("Invalid post. Expecting a date and a slug")
+
+// Layouts of the date of a post in the bulk_posts index.
+var bulkDateLayouts = []string{"2006-01-02", "2006-01-02 15:04", "2006-01-02 15:04:05"}
+
+// Helper function that reads the posts listed in the bulk_posts index of the
+// _config.yml, a single YAML or JSON file such as one exported from a CMS,
+// rather than a file per post:
+//
+//	[{"date": "2013-02-14", "slug": "first", "title": "First Post",
+//	  "tags": ["go"], "content": "The *first* post."}]
+//
+// Every entry is front-end matter, along with the Markdown content of the
+// post, and is read as if it were _posts/2013-02-14-first.md. Entries without
+// a date and a slug are skipped, or fail the build with strict_front_matter.
+func (s *Site) readBulkPosts() error {
+	index := s.Conf.GetString("bulk_posts")
+	if index == "" {
+		return nil
+	}
+	c, err := s.readFile(joinPath(s.Src, index))
+	if err != nil {
+		return err
+	}
+	var entries []map[interface{}]interface{}
+	if err := goyaml.Unmarshal(c, &entries); err != nil {
+		return fmt.Errorf("%s: %s", index, err)
+	}
+
+	for i, entry := range entries {
+		matter := Config{}
+		for key, val := range entry {
+			matter[fmt.Sprint(key)] = val
+		}
+		rel := fmt.Sprintf("%s[%d]", index, i)
+
+		f, err := bulkPostName(matter)
+		if err != nil {
+			if err := s.parseError(rel, &BuildError{rel, err}); err != nil {
+				return err
+			}
+			continue
+		}
+		content := matter.GetString("content")
+		delete(entry, "content")
+		delete(entry, "date")
+		delete(entry, "slug")
+		yaml, err := goyaml.Marshal(entry)
+		if err != nil {
+			return fmt.Errorf("%s: %s", rel, err)
+		}
+
+		rel = filepath.Join("_posts", f)
+		post, err := parsePost(rel, f, []byte("---\n"+string(yaml)+"---\n"+content), s.converters, true)
+		if err != nil {
+			if err := s.parseError(rel, err); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := s.addPost(rel, post); err != nil {
+			return err
+		}
+	}
+
+	// keep the posts most recent first, along with those read from files
+	sort.Stable(byDate(s.posts))
+	return nil
+}
+
+// Helper function that returns the name of the file a post of the bulk_posts
+// index would have, e.g. 2013-02-14-00-00-00-first.md, from its date and
+// slug. The name always has a time, so that a slug such as 10-20-things
+// isn't taken for one.
+func bulkPostName(matter Config) (string, error) {
+	slug := matter.GetString("slug")
+	if slug == "" {
+		return "", ErrBadBulkPost
+	}
+	date := matter.GetString("date")
+	if d, ok := matter["date"].(time.Time); ok {
+		date = d.Format("2006-01-02 15:04:05")
+	}
+	for _, layout := range bulkDateLayouts {
+		if d, err := time.Parse(layout, date); err == nil {
+			return d.Format("2006-01-02-15-04-05-") + slug + ".md", nil
+		}
+	}
+	return "", ErrBadBulkPost
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadBulkPosts(t *testing.T) {
+	site, cleanup := newTestSite(t, map[string]string{
+		"_config.yml":               "bulk_posts: export/posts.yml\n",
+		"_layouts/default.html":     "{{.page.title}}: {{.content}}",
+		"_posts/2013-02-15-file.md": "---\ntitle: File\n---\nfile",
+		"export/posts.yml": "- date: 2013-02-14\n  slug: first\n  title: First\n  tags: [go]\n  content: |\n    The *first* post.\n" +
+			"- date: 2013-02-16 10:30\n  slug: third\n  title: Third\n  content: third\n" +
+			"- title: Broken\n  content: no date\n"})
+	defer cleanup()
+
+	slugs := []string{}
+	for _, post := range site.posts {
+		slugs = append(slugs, post.GetSlug())
+	}
+	if !reflect.DeepEqual(slugs, []string{"third", "file", "first"}) {
+		t.Errorf("Expected the bulk posts along with the others, newest first, got %v", slugs)
+	}
+	if date := site.posts[0].GetDate().Format("2006-01-02 15:04"); date != "2013-02-16 10:30" {
+		t.Errorf("Expected the time of the bulk post, got %s", date)
+	}
+	if tags := site.posts[2].GetTags(); !reflect.DeepEqual(tags, []string{"go"}) {
+		t.Errorf("Expected the front matter of the bulk post, got tags %v", tags)
+	}
+
+	if err := site.Generate(); err != nil {
+		t.Fatal(err)
+	}
+	b, _ := ioutil.ReadFile(filepath.Join(site.Dest, "first", "index.html"))
+	if expected := "First: <p>The <em>first</em> post.</p>\n"; string(b) != expected {
+		t.Errorf("Expected the bulk post [%s] got [%s]", expected, b)
+	}
+	if _, err := ioutil.ReadFile(filepath.Join(site.Dest, "export", "posts.yml")); err == nil {
+		t.Errorf("Expected the bulk_posts index not to be copied to the site")
+	}
+
+	site.Conf.Set("strict_front_matter", true)
+	err := site.Reload()
+	if err == nil || !strings.Contains(err.Error(), "export/posts.yml[2]: "+ErrBadBulkPost.Error()) {
+		t.Errorf("Expected the entry without a date to fail with strict_front_matter, got %v", err)
+	}
+}
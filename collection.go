@@ -0,0 +1,297 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Default permalink of the documents of a collection, mirroring their path
+// in the collection's directory, e.g. _docs/guide.md to docs/guide.html
+const defaultCollectionPermalink = "/:collection/:path:output_ext"
+
+// A collection groups documents, such as the chapters of a manual, read from
+// a directory of their own named after the collection, e.g. _docs. The
+// collections are listed in the _config.yml, optionally all in the
+// collections_dir:
+//
+//	collections_dir: content
+//	collections:
+//	  docs:
+//	    output: true
+//	    permalink: /manual/:path/
+//	    sort_by: weight
+//	    order: asc
+//	    outputs: [html, txt]
+//
+// Documents are ordered by the sort_by key of their front-end matter, or
+// else by path, and are linked to the previous and next ones.
+type collection struct {
+	name      string
+	dir       string // Directory of the documents, relative to the source
+	output    bool   // Whether the documents are written to the site
+	permalink string
+	sortBy    string
+	desc      bool
+	outputs   []string // Formats the documents are written in, see output_formats
+	docs      []Page
+}
+
+// Name of the format documents are written in by default, to their own url
+// with their own layout.
+const htmlOutput = "html"
+
+// Helper function that parses the collections listed in the _config.yml,
+// either as a map of names to options, or a list of names.
+func parseCollections(conf Config) ([]*collection, error) {
+	names := []string{}
+	switch v := conf.Get("collections").(type) {
+	case map[interface{}]interface{}:
+		for name := range v {
+			names = append(names, fmt.Sprint(name))
+		}
+		sort.Strings(names)
+	default:
+		names = conf.GetStringSlice("collections")
+	}
+
+	base := conf.GetString("collections_dir")
+	collections := []*collection{}
+	for _, name := range names {
+		switch name {
+		case "posts", "pages", "drafts":
+			return nil, fmt.Errorf("Invalid collection name: %s", name)
+		}
+		key := "collections." + name
+		c := collection{
+			name:      name,
+			dir:       filepath.Join(base, "_"+name),
+			output:    conf.GetBool(key+".output", false),
+			permalink: conf.GetString(key + ".permalink"),
+			sortBy:    conf.GetString(key + ".sort_by"),
+			desc:      conf.GetString(key+".order") == "desc",
+			outputs:   conf.GetStringSlice(key + ".outputs"),
+		}
+		if c.permalink == "" {
+			c.permalink = defaultCollectionPermalink
+		}
+		collections = append(collections, &c)
+	}
+	return collections, nil
+}
+
+// Helper function that returns the collection containing the file, relative
+// to the source directory, or nil.
+func (s *Site) collectionOf(rel string) *collection {
+	for _, c := range s.collections {
+		if strings.HasPrefix(rel, c.dir+string(filepath.Separator)) {
+			return c
+		}
+	}
+	return nil
+}
+
+// Helper function that adds a parsed document to its collection, applying
+// the front-end matter defaults in scope of the collection's name and its
+// permalink, unless the document has its own. Unpublished documents are
+// skipped, unless enabled in the _config.yml.
+func (s *Site) addDocument(c *collection, rel string, doc Page) error {
+	relPath, _ := filepath.Rel(c.dir, rel)
+	doc["collection"] = c.name
+	doc["relative_path"] = filepath.ToSlash(relPath)
+
+	if err := s.applyDefaults(rel, c.name, doc); err != nil {
+		return err
+	}
+	permalink := doc.GetString("permalink")
+	if permalink == "" {
+		permalink = c.permalink
+	}
+	if err := doc.setPermalink(permalink); err != nil {
+		return fmt.Errorf("%s: %s", rel, err)
+	}
+
+	if s.isPublished(doc) {
+		c.docs = append(c.docs, doc)
+	}
+	return nil
+}
+
+// Helper function that sorts the documents of each collection, links every
+// document to the previous and next ones, and makes the documents available
+// to templates under the collection's name, e.g. site.docs
+func (s *Site) orderCollections() {
+	for _, c := range s.collections {
+		sort.Sort(byField{c.docs, c.sortBy, c.desc})
+
+		// neighbours are linked by a copy, so that pages don't refer to
+		// each other in a cycle
+		link := func(doc Page) Page {
+			p := Page{}
+			for k, v := range doc {
+				if k != "previous" && k != "next" {
+					p[k] = v
+				}
+			}
+			return p
+		}
+		for i, doc := range c.docs {
+			doc["previous"] = nil
+			doc["next"] = nil
+			if i > 0 {
+				doc["previous"] = link(c.docs[i-1])
+			}
+			if i < len(c.docs)-1 {
+				doc["next"] = link(c.docs[i+1])
+			}
+		}
+		s.Conf.Set(c.name, c.docs)
+	}
+}
+
+// Helper function that returns the documents of all collections that are
+// written to the site.
+func (s *Site) documents() []Page {
+	docs := []Page{}
+	for _, c := range s.collections {
+		if c.output {
+			docs = append(docs, c.docs...)
+		}
+	}
+	return docs
+}
+
+// Helper function that returns a copy of the document for each format it is
+// written in, listed in the outputs of its front-end matter or else of its
+// collection. Formats other than html are described by output_formats in the
+// _config.yml, each with the extension and layout of its files:
+//
+//	output_formats:
+//	  txt:
+//	    extension: .txt
+//	    layout: plain
+//
+// The copies share the rendered content, and are written next to the html,
+// e.g. docs/setup.txt for docs/setup.html. The layout defaults to the name of
+// the format.
+func (s *Site) documentOutputs(doc Page) ([]Page, error) {
+	outputs := doc.GetStrings("outputs")
+	if _, ok := doc["outputs"]; !ok {
+		for _, c := range s.collections {
+			if c.name == doc.GetString("collection") {
+				outputs = c.outputs
+			}
+		}
+	}
+	if len(outputs) == 0 {
+		return []Page{doc}, nil
+	}
+
+	docs := []Page{}
+	for _, name := range outputs {
+		if name == htmlOutput {
+			docs = append(docs, doc)
+			continue
+		}
+		key := "output_formats." + name
+		ext := s.Conf.GetString(key + ".extension")
+		if ext == "" {
+			return nil, fmt.Errorf("Unknown output format: %s", name)
+		}
+		layout := s.Conf.GetString(key + ".layout")
+		if layout == "" {
+			layout = name
+		}
+
+		out := Page{}
+		for k, v := range doc {
+			if k != "redirect_from" {
+				out[k] = v
+			}
+		}
+		out["layout"] = layout
+		if !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		if err := out.setUrl(removeExt(doc.GetUrl()) + ext); err != nil {
+			return nil, err
+		}
+		docs = append(docs, out)
+	}
+	return docs, nil
+}
+
+// byField sorts documents by a key of their front-end matter, then by path,
+// in ascending or descending order. Documents without the key come last.
+type byField struct {
+	docs []Page
+	key  string
+	desc bool
+}
+
+func (b byField) Len() int      { return len(b.docs) }
+func (b byField) Swap(i, j int) { b.docs[i], b.docs[j] = b.docs[j], b.docs[i] }
+func (b byField) Less(i, j int) bool {
+	x, y := b.docs[i][b.key], b.docs[j][b.key]
+	switch {
+	case x == nil && y != nil:
+		return false
+	case x != nil && y == nil:
+		return true
+	}
+
+	c := 0
+	if x != nil {
+		c = compareValues(x, y)
+	}
+	if c == 0 {
+		c = strings.Compare(b.docs[i].GetPath(), b.docs[j].GetPath())
+	}
+	if b.desc {
+		return c > 0
+	}
+	return c < 0
+}
+
+// Helper function that compares two front-end matter values: numbers by
+// value, dates by time, and anything else as strings.
+func compareValues(x, y interface{}) int {
+	if a, ok := toFloat(x); ok {
+		if b, ok := toFloat(y); ok {
+			switch {
+			case a < b:
+				return -1
+			case a > b:
+				return 1
+			}
+			return 0
+		}
+	}
+	if a, ok := x.(time.Time); ok {
+		if b, ok := y.(time.Time); ok {
+			switch {
+			case a.Before(b):
+				return -1
+			case a.After(b):
+				return 1
+			}
+			return 0
+		}
+	}
+	return strings.Compare(fmt.Sprint(x), fmt.Sprint(y))
+}
+
+// Helper function that returns a number as a float.
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
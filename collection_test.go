@@ -0,0 +1,104 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadCollections(t *testing.T) {
+	site, cleanup := newTestSite(t, map[string]string{
+		"_config.yml": "collections_dir: content\ncollections:\n" +
+			"  docs:\n    output: true\n    sort_by: weight\n" +
+			"  notes:\n    order: desc\n",
+		"_layouts/default.html":      "{{.content}}|{{with .page.previous}}{{.title}}{{end}}|{{with .page.next}}{{.title}}{{end}}",
+		"content/_docs/intro.md":     "---\ntitle: Intro\nweight: 1\n---\nintro",
+		"content/_docs/api/index.md": "---\ntitle: API\nweight: 10\n---\napi",
+		"content/_docs/setup.md":     "---\ntitle: Setup\nweight: 2\n---\nsetup",
+		"content/_docs/appendix.md":  "---\ntitle: Appendix\n---\nappendix",
+		"content/_docs/diagram.png":  "png",
+		"content/_notes/2013-a.md":   "---\ntitle: A\n---\na",
+		"content/_notes/2014-b.md":   "---\ntitle: B\n---\nb",
+		"index.html":                 "---\n---\n{{range .site.docs}}{{.collection}}:{{.title}} {{end}}"})
+	defer cleanup()
+
+	titles := func(docs []Page) []string {
+		list := []string{}
+		for _, doc := range docs {
+			list = append(list, doc.GetTitle())
+		}
+		return list
+	}
+	if docs := titles(site.collections[0].docs); !reflect.DeepEqual(docs, []string{"Intro", "Setup", "API", "Appendix"}) {
+		t.Errorf("Expected docs ordered by weight, got %v", docs)
+	}
+	if notes := titles(site.collections[1].docs); !reflect.DeepEqual(notes, []string{"B", "A"}) {
+		t.Errorf("Expected notes in descending order, got %v", notes)
+	}
+
+	if err := site.Generate(); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{
+		"docs/intro.html":          "<p>intro</p>\n||Setup",
+		"docs/setup.html":          "<p>setup</p>\n|Intro|API",
+		"docs/api/index.html":      "<p>api</p>\n|Setup|Appendix",
+		"index.html":               "docs:Intro docs:Setup docs:API docs:Appendix ||",
+		"notes/2013-a.html":        "",
+		"docs/diagram.png":         "",
+		"content/_docs/intro.html": "",
+	}
+	for file, content := range expected {
+		b, err := ioutil.ReadFile(filepath.Join(site.Dest, file))
+		if content == "" {
+			if err == nil {
+				t.Errorf("Expected [%s] not to be written", file)
+			}
+			continue
+		}
+		if string(b) != content {
+			t.Errorf("Expected [%s] to contain [%s] got [%s]", file, content, b)
+		}
+	}
+}
+
+func TestParseCollectionsReserved(t *testing.T) {
+	if _, err := parseCollections(Config{"collections": []interface{}{"posts"}}); err == nil {
+		t.Errorf("Expected error for a collection named posts")
+	}
+}
+
+func TestCollectionOutputs(t *testing.T) {
+	site, cleanup := newTestSite(t, map[string]string{
+		"_config.yml": "collections:\n  docs:\n    output: true\n    outputs: [html, txt]\n" +
+			"output_formats:\n  txt:\n    extension: .txt\n    layout: plain\n  pdf:\n    extension: pdf\n",
+		"_layouts/default.html": "<main>{{.content}}</main>",
+		"_layouts/plain.html":   "{{.page.title}}: {{.page.excerpt}}",
+		"_layouts/pdf.html":     "%PDF {{.page.title}}",
+		"_docs/setup.md":        "---\ntitle: Setup\n---\nsetup *now*",
+		"_docs/print.md":        "---\ntitle: Print\noutputs: [pdf]\npermalink: /print/\n---\nprint"})
+	defer cleanup()
+
+	if err := site.Generate(); err != nil {
+		t.Fatal(err)
+	}
+	for fn, expected := range map[string]string{
+		"docs/setup.html": "<main><p>setup <em>now</em></p>\n</main>",
+		"docs/setup.txt":  "Setup: setup now",
+		"print/index.pdf": "%PDF Print",
+	} {
+		if b, _ := ioutil.ReadFile(filepath.Join(site.Dest, fn)); string(b) != expected {
+			t.Errorf("Expected %s to be [%s] got [%s]", fn, expected, b)
+		}
+	}
+	if _, err := ioutil.ReadFile(filepath.Join(site.Dest, "print", "index.html")); err == nil {
+		t.Errorf("Expected no html for a document without the html output")
+	}
+
+	site.collections[0].outputs = []string{"epub"}
+	if err := site.Generate(); err == nil || !strings.Contains(err.Error(), "Unknown output format: epub") {
+		t.Errorf("Expected an unknown output format to fail, got %v", err)
+	}
+}
@@ -1,32 +1,133 @@
 package main
 
 import (
+	"fmt"
 	"io/ioutil"
 	"launchpad.net/goyaml"
+	"strconv"
+	"strings"
 )
 
 // Config represents the key-value pairs in a _config.yml file.
 // The file is freeform, and thus requires the flexibility of a map.
 type Config map[string]interface{}
 
-// Sets a parameter value.
+// Sets a parameter value. A dotted key, such as feed.limit, sets the value
+// in nested maps, creating them as needed, so it resolves to the same
+// structure as the nested keys of the _config.yml file. Any intermediate value
+// that isn't a map is replaced.
 func (c Config) Set(key string, val interface{}) {
-	c[key] = val
+	if _, ok := c[key]; ok || !strings.Contains(key, ".") {
+		c[key] = val
+		return
+	}
+
+	keys := strings.Split(key, ".")
+	last := len(keys) - 1
+	m, ok := c[keys[0]].(map[interface{}]interface{})
+	if !ok {
+		m = map[interface{}]interface{}{}
+		c[keys[0]] = m
+	}
+	for _, k := range keys[1:last] {
+		next, ok := m[k].(map[interface{}]interface{})
+		if !ok {
+			next = map[interface{}]interface{}{}
+			m[k] = next
+		}
+		m = next
+	}
+	m[keys[last]] = val
 }
 
-// Gets a parameter value.
+// Gets a parameter value. A dotted key, such as feed.limit, gets the value
+// from nested maps.
 func (c Config) Get(key string) interface{} {
-	return c[key]
+	v, _ := c.lookup(key)
+	return v
 }
 
 // Gets a parameter value as a string. If none exists return an empty string.
+// Numbers and booleans are formatted as strings.
+//
+// Like all typed getters, the key may be a dotted path to a value nested in
+// the _config.yml, e.g. sass.style
 func (c Config) GetString(key string) (str string) {
-	if v, ok := c[key]; ok {
+	v, _ := c.lookup(key)
+	switch v.(type) {
+	case string:
 		str = v.(string)
+	case int, int64, float64, bool:
+		str = fmt.Sprint(v)
 	}
 	return
 }
 
+// Gets a parameter value as an int. If none exists, or the value isn't a
+// whole number, return the default value.
+func (c Config) GetInt(key string, def int) int {
+	v, _ := c.lookup(key)
+	switch n := v.(type) {
+	case int:
+		return n
+	case int64:
+		return int(n)
+	case float64:
+		if n == float64(int(n)) {
+			return int(n)
+		}
+	case string:
+		if i, err := strconv.Atoi(strings.TrimSpace(n)); err == nil {
+			return i
+		}
+	}
+	return def
+}
+
+// Gets a parameter value as a bool. If none exists, or the value isn't a
+// boolean, return the default value.
+func (c Config) GetBool(key string, def bool) bool {
+	v, _ := c.lookup(key)
+	switch b := v.(type) {
+	case bool:
+		return b
+	case string:
+		if x, err := strconv.ParseBool(strings.TrimSpace(b)); err == nil {
+			return x
+		}
+	}
+	return def
+}
+
+// Gets a parameter value as a string array. A single string is returned as
+// an array of one. If none exists return nil.
+func (c Config) GetStringSlice(key string) (strs []string) {
+	v, _ := c.lookup(key)
+	switch v.(type) {
+	case []interface{}:
+		for _, s := range v.([]interface{}) {
+			strs = append(strs, fmt.Sprint(s))
+		}
+	case []string:
+		strs = v.([]string)
+	case string:
+		strs = []string{v.(string)}
+	}
+	return
+}
+
+// Helper function that looks up a parameter value. A key that isn't found
+// as is, and contains dots, is looked up as a path to a nested value.
+func (c Config) lookup(key string) (interface{}, bool) {
+	if v, ok := c[key]; ok {
+		return v, true
+	}
+	if !strings.Contains(key, ".") {
+		return nil, false
+	}
+	return lookupPath(map[string]interface{}(c), key)
+}
+
 // ParseConfig will parse a YAML file at the given path and return
 // a key-value Config structure.
 //
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"text/template"
+)
+
+var testConfig = []byte(`title: Blog
+limit: 20
+ratio: 1.5
+count: "7"
+future: true
+draft: "false"
+exclude: [node_modules, vendor]
+author: Brad
+feed:
+  limit: 5
+  compact: yes
+  tags: [go, web]
+`)
+
+func TestConfigGetString(t *testing.T) {
+	conf, err := parseConfig(testConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := map[string]string{
+		"title":      "Blog",
+		"limit":      "20",
+		"future":     "true",
+		"feed.limit": "5",
+		"exclude":    "",
+		"missing":    "",
+		"feed.nope":  "",
+		"title.nope": "",
+	}
+	for key, val := range tests {
+		if str := conf.GetString(key); str != val {
+			t.Errorf("Expected GetString [%s] got [%s] for key [%s]", val, str, key)
+		}
+	}
+}
+
+func TestConfigGetInt(t *testing.T) {
+	conf, err := parseConfig(testConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := map[string]int{
+		"limit":      20,
+		"count":      7,
+		"feed.limit": 5,
+		"ratio":      -1,
+		"title":      -1,
+		"missing":    -1,
+	}
+	for key, val := range tests {
+		if i := conf.GetInt(key, -1); i != val {
+			t.Errorf("Expected GetInt [%d] got [%d] for key [%s]", val, i, key)
+		}
+	}
+	if i := (Config{"limit": 10.0}).GetInt("limit", -1); i != 10 {
+		t.Errorf("Expected whole float to be converted to int, got [%d]", i)
+	}
+}
+
+func TestConfigGetBool(t *testing.T) {
+	conf, err := parseConfig(testConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		key      string
+		def      bool
+		expected bool
+	}{
+		{"future", false, true},
+		{"draft", true, false},
+		{"feed.compact", false, true},
+		{"title", true, true},
+		{"missing", true, true},
+		{"missing", false, false},
+	}
+	for _, test := range tests {
+		if b := conf.GetBool(test.key, test.def); b != test.expected {
+			t.Errorf("Expected GetBool [%v] got [%v] for key [%s]", test.expected, b, test.key)
+		}
+	}
+}
+
+func TestConfigGetStringSlice(t *testing.T) {
+	conf, err := parseConfig(testConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := map[string][]string{
+		"exclude":   {"node_modules", "vendor"},
+		"feed.tags": {"go", "web"},
+		"author":    {"Brad"},
+		"missing":   nil,
+	}
+	for key, val := range tests {
+		if strs := conf.GetStringSlice(key); !reflect.DeepEqual(strs, val) {
+			t.Errorf("Expected GetStringSlice %v got %v for key [%s]", val, strs, key)
+		}
+	}
+}
+
+func TestConfigSetNested(t *testing.T) {
+	conf, err := parseConfig(testConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf.Set("feed.limit", 50)
+	conf.Set("markdown.smartypants", true)
+	conf.Set("s3.bucket.region", "eu-west-1")
+	conf.Set("title.sub", "nested")
+
+	tests := map[string]interface{}{
+		"feed.limit":           50,
+		"feed.compact":         true,
+		"markdown.smartypants": true,
+		"s3.bucket.region":     "eu-west-1",
+		"title.sub":            "nested",
+	}
+	for key, val := range tests {
+		if v := conf.Get(key); v != val {
+			t.Errorf("Expected Get [%v] got [%v] for key [%s]", val, v, key)
+		}
+	}
+
+	// templates see the same nested structure as the _config.yml
+	feed, ok := conf["feed"].(map[interface{}]interface{})
+	if !ok || feed["limit"] != 50 {
+		t.Errorf("Expected nested feed map, got %v", conf["feed"])
+	}
+	var buf bytes.Buffer
+	tmpl := template.Must(template.New("").Parse("{{.site.feed.limit}} {{.site.s3.bucket.region}}"))
+	if err := tmpl.Execute(&buf, map[string]interface{}{"site": conf}); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "50 eu-west-1" {
+		t.Errorf("Expected nested values in template, got [%s]", buf.String())
+	}
+}
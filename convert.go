@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// A Converter transforms the markup of a page, such as Markdown, to HTML.
+type Converter func([]byte) ([]byte, error)
+
+// Converters registered by file extension. Pages and posts with a registered
+// extension are run through the converter when parsed, before they are
+// wrapped in a layout, and are written with an .html extension. Markdown is
+// converted with blackfriday by default, see registerMarkdown.
+//
+// Each site converts its pages with its own copy of the table, along with
+// the converters in its _config.yml, see newConverters.
+var converters = map[string]Converter{
+	".md":       convertMarkdown,
+	".markdown": convertMarkdown,
+}
+
+// RegisterConverter adds a converter for files with the given extension,
+// e.g. ".adoc", replacing any converter already registered for it. It
+// applies to the sites created afterwards.
+func RegisterConverter(ext string, fn Converter) {
+	addConverter(converters, ext, fn)
+}
+
+// Helper function that adds a converter to the table by extension, with or
+// without its leading dot.
+func addConverter(table map[string]Converter, ext string, fn Converter) {
+	if !strings.HasPrefix(ext, ".") {
+		ext = "." + ext
+	}
+	table[ext] = fn
+}
+
+// Helper function that returns the converters of a site: those registered
+// with RegisterConverter, along with the Markdown engine and the external
+// converters selected in its _config.yml, which replace them by extension.
+func newConverters(conf Config) (map[string]Converter, error) {
+	table := map[string]Converter{}
+	for ext, fn := range converters {
+		table[ext] = fn
+	}
+	if err := registerMarkdown(conf, table); err != nil {
+		return nil, err
+	}
+	if err := registerConverters(conf, table); err != nil {
+		return nil, err
+	}
+	return table, nil
+}
+
+// ExternalConverter returns a converter that runs the named command, writing
+// the markup to its standard input and reading the HTML from its standard
+// output, e.g. ExternalConverter("asciidoctor", "-s", "-o", "-", "-").
+func ExternalConverter(name string, args ...string) Converter {
+	return func(markup []byte) ([]byte, error) {
+		var stderr bytes.Buffer
+		cmd := exec.Command(name, args...)
+		cmd.Stdin = bytes.NewReader(markup)
+		cmd.Stderr = &stderr
+		out, err := cmd.Output()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s\n%s", name, err, bytes.TrimSpace(stderr.Bytes()))
+		}
+		return out, nil
+	}
+}
+
+// Helper function that adds the external converters listed in the
+// _config.yml to the table by extension, for example:
+//
+//	converters:
+//	  adoc: asciidoctor -s -o - -
+//	  rst: rst2html.py
+func registerConverters(conf Config, table map[string]Converter) error {
+	m, ok := conf.Get("converters").(map[interface{}]interface{})
+	if !ok {
+		return nil
+	}
+	for ext, command := range m {
+		fields := strings.Fields(fmt.Sprint(command))
+		if len(fields) == 0 {
+			return fmt.Errorf("No command defined for converter: %v", ext)
+		}
+		addConverter(table, fmt.Sprint(ext), ExternalConverter(fields[0], fields[1:]...))
+	}
+	return nil
+}
+
+// Helper function that converts Markdown to HTML with the default engine,
+// until the engine selected in the _config.yml is registered.
+func convertMarkdown(markup []byte) ([]byte, error) {
+	return newBlackfridayEngine(Config{}).Render(markup), nil
+}
+
+// Returns True if the table has a converter for the file's extension.
+func isConverted(table map[string]Converter, fn string) bool {
+	_, ok := table[filepath.Ext(fn)]
+	return ok
+}
+
+// Helper function that runs the markup through the converter in the table
+// for the file's extension. Markup with no converter is returned unchanged.
+func convert(table map[string]Converter, fn string, markup []byte) ([]byte, error) {
+	if fn, ok := table[filepath.Ext(fn)]; ok {
+		return fn(markup)
+	}
+	return markup, nil
+}
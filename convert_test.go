@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestRegisterConverter(t *testing.T) {
+	RegisterConverter("txt2", func(markup []byte) ([]byte, error) {
+		return bytes.ToUpper(markup), nil
+	})
+	defer delete(converters, ".txt2")
+
+	if !isConverted(converters, "notes.txt2") {
+		t.Errorf("Expected files with a registered converter to be converted")
+	}
+
+	page, err := parsePage("notes.txt2", []byte("---\ntitle: Notes\n---\nhello"), converters)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.GetContent() != "HELLO" {
+		t.Errorf("Expected converted content [HELLO] got [%s]", page.GetContent())
+	}
+	if page.GetUrl() != "notes.html" {
+		t.Errorf("Expected converted page url [notes.html] got [%s]", page.GetUrl())
+	}
+}
+
+func TestConvertUnregistered(t *testing.T) {
+	out, err := convert(converters, "page.html", []byte("<p>{{.page.title}}</p>"))
+	if err != nil || string(out) != "<p>{{.page.title}}</p>" {
+		t.Errorf("Expected markup without a converter to pass through, got [%s] %v", out, err)
+	}
+}
+
+func TestExternalConverter(t *testing.T) {
+	out, err := ExternalConverter("tr", "a-z", "A-Z")([]byte("hello"))
+	if err != nil {
+		t.Skip(err)
+	}
+	if string(out) != "HELLO" {
+		t.Errorf("Expected output of external command [HELLO] got [%s]", out)
+	}
+
+	if _, err := ExternalConverter("jkl-no-such-command")([]byte("hello")); err == nil {
+		t.Errorf("Expected error running a missing command")
+	}
+}
+
+func TestSiteConverters(t *testing.T) {
+	first, cleanup := newTestSite(t, map[string]string{
+		"_config.yml":           "converters:\n  up: tr a-z A-Z\n",
+		"_layouts/default.html": "{{.content}}",
+		"notes.up":              "---\n---\nhello"})
+	defer cleanup()
+	if len(first.pages) != 1 || first.pages[0].GetContent() != "HELLO" {
+		t.Skip("tr is not available")
+	}
+
+	second, cleanup2 := newTestSite(t, map[string]string{
+		"_config.yml":           "",
+		"_layouts/default.html": "{{.content}}",
+		"notes.up":              "---\n---\nhello"})
+	defer cleanup2()
+	if len(second.pages) != 0 || !contains(second.files, "notes.up") {
+		t.Errorf("Expected the converters of one site not to apply to another, got pages %v", second.pages)
+	}
+	if isConverted(converters, "notes.up") {
+		t.Errorf("Expected the converters of a site to be kept out of the registered converters")
+	}
+}
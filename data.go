@@ -0,0 +1,37 @@
+package main
+
+import (
+	"fmt"
+	"launchpad.net/goyaml"
+	"path/filepath"
+)
+
+// Directory containing the data files of a site, e.g. _data/blogroll.yml
+const dataDir = "_data"
+
+// Returns True if the file is a data file in the _data directory.
+func isData(fn string) bool {
+	if filepath.Dir(fn) != dataDir {
+		return false
+	}
+	switch filepath.Ext(fn) {
+	case ".yml", ".yaml":
+		return true
+	}
+	return false
+}
+
+// Helper function that reads a data file, available to templates under its
+// name, e.g. site.data.blogroll for _data/blogroll.yml
+func (s *Site) addData(fn, rel string) error {
+	c, err := s.readFile(fn)
+	if err != nil {
+		return err
+	}
+	var data interface{}
+	if err := goyaml.Unmarshal(c, &data); err != nil {
+		return fmt.Errorf("%s: %s", rel, err)
+	}
+	s.data[removeExt(filepath.Base(rel))] = data
+	return nil
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadData(t *testing.T) {
+	site, cleanup := newTestSite(t, map[string]string{
+		"_config.yml":           "",
+		"_layouts/default.html": "{{.content}}",
+		"_data/authors.yml":     "alice:\n  name: Alice\n",
+		"_data/links.yaml":      "- name: Go\n  url: http://golang.org/\n",
+		"_data/notes.txt":       "ignored",
+		"index.html":            "---\n---\n{{.site.data.authors.alice.name}}{{range .site.data.links}} <a href=\"{{.url}}\">{{.name}}</a>{{end}}"})
+	defer cleanup()
+
+	if _, ok := site.data["notes"]; ok {
+		t.Errorf("Expected only yaml files to be read as data")
+	}
+	if err := site.Generate(); err != nil {
+		t.Fatal(err)
+	}
+	b, _ := ioutil.ReadFile(filepath.Join(site.Dest, "index.html"))
+	if expected := `Alice <a href="http://golang.org/">Go</a>`; string(b) != expected {
+		t.Errorf("Expected the data in the page [%s] got [%s]", expected, b)
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+// A frontMatterDefault is an entry in the defaults list of the _config.yml
+// file, providing front-end matter values for all documents in its scope:
+//
+//	defaults:
+//	  - scope:
+//	      path: projects
+//	      type: pages
+//	    values:
+//	      layout: project
+//
+// The path is relative to the source directory, and an empty path matches
+// all documents. The type is either pages or posts; an empty type matches
+// both.
+type frontMatterDefault struct {
+	path   string
+	typ    string
+	values map[string]interface{}
+}
+
+// Returns True if the document, relative to the source directory and of the
+// given type, is within the scope of the default.
+func (d *frontMatterDefault) matches(rel, typ string) bool {
+	if d.typ != "" && d.typ != typ {
+		return false
+	}
+	return d.path == "" || matchAny([]string{d.path}, rel)
+}
+
+// Returns the specificity of the default's scope. A scope with a path is
+// more specific than one with only a type, and a longer path is more
+// specific than a shorter one.
+func (d *frontMatterDefault) specificity() int {
+	n := len(d.path) * 2
+	if d.typ != "" {
+		n++
+	}
+	return n
+}
+
+// Helper function that parses the defaults list from the _config.yml file.
+func parseDefaults(conf Config) ([]*frontMatterDefault, error) {
+	list, ok := conf.Get("defaults").([]interface{})
+	if !ok {
+		return nil, nil
+	}
+
+	defaults := []*frontMatterDefault{}
+	for i, item := range list {
+		entry, ok := item.(map[interface{}]interface{})
+		if !ok {
+			return nil, fmt.Errorf("Invalid defaults entry %d. Expecting scope and values", i)
+		}
+		d := frontMatterDefault{values: map[string]interface{}{}}
+		if scope, ok := entry["scope"].(map[interface{}]interface{}); ok {
+			d.path, _ = scope["path"].(string)
+			d.typ, _ = scope["type"].(string)
+		}
+		if values, ok := entry["values"].(map[interface{}]interface{}); ok {
+			for key, val := range values {
+				d.values[fmt.Sprint(key)] = val
+			}
+		}
+		defaults = append(defaults, &d)
+	}
+
+	// order from the least to the most specific scope, so that values of
+	// more specific scopes take precedence
+	sort.Stable(bySpecificity(defaults))
+	return defaults, nil
+}
+
+// Helper function that applies the defaults in scope to the page, without
+// overriding any values set in the page's own front-end matter.
+func applyDefaults(defaults []*frontMatterDefault, rel, typ string, page Page) {
+	values := map[string]interface{}{}
+	for _, d := range defaults {
+		if d.matches(rel, typ) {
+			for key, val := range d.values {
+				values[key] = val
+			}
+		}
+	}
+	for key, val := range values {
+		if _, ok := page[key]; !ok {
+			page[key] = val
+		}
+	}
+}
+
+type bySpecificity []*frontMatterDefault
+
+func (d bySpecificity) Len() int           { return len(d) }
+func (d bySpecificity) Swap(i, j int)      { d[i], d[j] = d[j], d[i] }
+func (d bySpecificity) Less(i, j int) bool { return d[i].specificity() < d[j].specificity() }
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestApplyDefaults(t *testing.T) {
+	conf, err := parseConfig([]byte(`
+defaults:
+  - scope:
+      path: projects
+    values:
+      layout: project
+      author: me
+  - scope:
+      type: pages
+    values:
+      layout: page
+  - scope:
+      path: ""
+      type: posts
+    values:
+      layout: post
+      comments: true
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defaults, err := parseDefaults(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		rel    string
+		typ    string
+		page   Page
+		layout string
+	}{
+		{"about.md", "pages", Page{}, "page"},
+		{"_posts/2013-01-01-post.md", "posts", Page{}, "post"},
+		{"projects/jkl.md", "pages", Page{}, "project"},
+		{"projects/jkl.md", "pages", Page{"layout": "custom"}, "custom"}}
+
+	for _, test := range tests {
+		applyDefaults(defaults, test.rel, test.typ, test.page)
+		if layout := test.page.GetLayout(); layout != test.layout {
+			t.Errorf("Expected layout [%s] for [%s] got [%s]", test.layout, test.rel, layout)
+		}
+	}
+
+	post := Page{}
+	applyDefaults(defaults, "_posts/2013-01-01-post.md", "posts", post)
+	if post["comments"] != true || post["author"] != nil {
+		t.Errorf("Expected only post defaults to be applied, got %v", post)
+	}
+}
+
+func TestParseDefaultsInvalid(t *testing.T) {
+	conf, _ := parseConfig([]byte("defaults:\n  - layout\n"))
+	if _, err := parseDefaults(conf); err == nil {
+		t.Errorf("Expected error for defaults entry without scope and values")
+	}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+)
+
+var MsgDeploy = "Deploying %s to %s"
+
+var ErrNoRemote = errors.New("No remote configured. Expecting deploy.remote in the _config.yml")
+
+// Name of the deployer used unless another is selected with deploy.provider
+// in the _config.yml.
+const defaultDeployer = "gh-pages"
+
+// A Deployer publishes the generated site, found in its destination
+// directory.
+type Deployer interface {
+	Deploy(dir string) error
+}
+
+// Deployers by name. Each is created from the deploy section of the
+// _config.yml, so that it may read its own options.
+var deployers = map[string]func(Config) (Deployer, error){
+	"gh-pages": newGhPagesDeployer,
+}
+
+// RegisterDeployer adds a deployer that may be selected by name with
+// deploy.provider in the _config.yml, replacing any deployer already
+// registered with the name.
+func RegisterDeployer(name string, fn func(Config) (Deployer, error)) {
+	deployers[name] = fn
+}
+
+// Deploy publishes the generated site with the deployer selected in the
+// _config.yml, the gh-pages deployer by default. The site must already be
+// generated.
+func (s *Site) Deploy() error {
+	conf := Config{}
+	if m, ok := s.Conf.Get("deploy").(map[interface{}]interface{}); ok {
+		for key, val := range m {
+			conf[fmt.Sprint(key)] = val
+		}
+	}
+
+	name := conf.GetString("provider")
+	if name == "" {
+		name = defaultDeployer
+	}
+	fn, ok := deployers[name]
+	if !ok {
+		return fmt.Errorf("Unknown deploy provider: %s", name)
+	}
+	d, err := fn(conf)
+	if err != nil {
+		return fmt.Errorf("%s: %s", name, err)
+	}
+	if err := d.Deploy(s.Dest); err != nil {
+		return fmt.Errorf("%s: %s", name, err)
+	}
+	return nil
+}
+
+// Deployer that force-pushes the site to a branch of a git remote, such as
+// the gh-pages branch GitHub Pages serves:
+//
+//	deploy:
+//	  remote: git@github.com:user/user.github.io.git
+//	  branch: gh-pages
+//	  message: "Site updated"
+//	  cname: www.example.com
+//
+// The site is committed as the only commit of the branch, from a temporary
+// repository kept out of the destination directory, so that nothing is left
+// behind in it but an empty .nojekyll file, which keeps GitHub from running
+// the already generated site through Jekyll again, and the CNAME file of the
+// custom domain, if cname is set, so that the domain isn't lost when the
+// site is generated without one.
+type ghPagesDeployer struct {
+	remote, branch, message string
+	name, email, cname      string
+}
+
+func newGhPagesDeployer(conf Config) (Deployer, error) {
+	d := ghPagesDeployer{
+		remote:  conf.GetString("remote"),
+		branch:  conf.GetString("branch"),
+		message: conf.GetString("message"),
+		name:    conf.GetString("name"),
+		email:   conf.GetString("email"),
+		cname:   conf.GetString("cname"),
+	}
+	if d.remote == "" {
+		return nil, ErrNoRemote
+	}
+	if d.branch == "" {
+		d.branch = "gh-pages"
+	}
+	if d.message == "" {
+		d.message = "Site updated at " + time.Now().UTC().Format("2006-01-02 15:04:05 UTC")
+	}
+	return d, nil
+}
+
+func (d ghPagesDeployer) Deploy(dir string) error {
+	if err := ioutil.WriteFile(filepath.Join(dir, ".nojekyll"), nil, 0644); err != nil {
+		return err
+	}
+	if d.cname != "" {
+		if err := ioutil.WriteFile(filepath.Join(dir, "CNAME"), []byte(d.cname+"\n"), 0644); err != nil {
+			return err
+		}
+	}
+
+	gitDir, err := ioutil.TempDir("", "jkl-deploy")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(gitDir)
+
+	logf(MsgDeploy, dir, d.remote+" "+d.branch)
+	git := func(args ...string) error {
+		args = append([]string{"--git-dir", gitDir, "--work-tree", dir}, args...)
+		if d.name != "" {
+			args = append([]string{"-c", "user.name=" + d.name}, args...)
+		}
+		if d.email != "" {
+			args = append([]string{"-c", "user.email=" + d.email}, args...)
+		}
+		var stderr bytes.Buffer
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		cmd.Stderr = &stderr
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("git: %s\n%s", err, bytes.TrimSpace(stderr.Bytes()))
+		}
+		return nil
+	}
+
+	for _, args := range [][]string{
+		{"init", "--quiet"},
+		{"add", "--all", "--force", "."},
+		{"commit", "--quiet", "--message", d.message},
+		{"push", "--quiet", "--force", d.remote, "HEAD:refs/heads/" + d.branch},
+	} {
+		if err := git(args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDeployGhPages(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip(err)
+	}
+	remote, err := ioutil.TempDir("", "jkl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(remote)
+	if out, err := exec.Command("git", "init", "--quiet", "--bare", remote).CombinedOutput(); err != nil {
+		t.Fatalf("%s %s", err, out)
+	}
+
+	site, cleanup := newTestSite(t, map[string]string{
+		"_config.yml":           "deploy:\n  remote: " + remote + "\n  message: Deployed\n  name: jkl\n  email: jkl@example.com\n",
+		"_layouts/default.html": "{{.content}}",
+		"index.html":            "---\n---\nHome"})
+	defer cleanup()
+
+	for i := 0; i < 2; i++ {
+		if err := site.Generate(); err != nil {
+			t.Fatal(err)
+		}
+		if err := site.Deploy(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	git := func(args ...string) string {
+		out, err := exec.Command("git", append([]string{"--git-dir", remote}, args...)...).Output()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return strings.TrimSpace(string(out))
+	}
+	if files := git("ls-tree", "--name-only", "gh-pages"); files != ".nojekyll\nindex.html" {
+		t.Errorf("Expected .nojekyll and index.html on the gh-pages branch, got [%s]", files)
+	}
+	if log := git("log", "--format=%s", "gh-pages"); log != "Deployed" {
+		t.Errorf("Expected the site force-pushed as a single commit, got [%s]", log)
+	}
+	if _, err := os.Stat(filepath.Join(site.Dest, ".git")); !os.IsNotExist(err) {
+		t.Errorf("Expected no .git directory left in the destination, got %v", err)
+	}
+}
+
+func TestDeployErrors(t *testing.T) {
+	site := &Site{Conf: Config{}}
+	if err := site.Deploy(); err == nil || !strings.Contains(err.Error(), ErrNoRemote.Error()) {
+		t.Errorf("Expected an error without a remote, got %v", err)
+	}
+
+	site.Conf.Set("deploy", map[interface{}]interface{}{"provider": "ftp"})
+	if err := site.Deploy(); err == nil || !strings.Contains(err.Error(), "Unknown deploy provider: ftp") {
+		t.Errorf("Expected an error for an unknown provider, got %v", err)
+	}
+}
+
+func TestDeployCname(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip(err)
+	}
+	remote, err := ioutil.TempDir("", "jkl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(remote)
+	if out, err := exec.Command("git", "init", "--quiet", "--bare", remote).CombinedOutput(); err != nil {
+		t.Fatalf("%s %s", err, out)
+	}
+
+	site, cleanup := newTestSite(t, map[string]string{
+		"_config.yml":           "deploy:\n  remote: " + remote + "\n  cname: www.example.com\n  name: jkl\n  email: jkl@example.com\n",
+		"_layouts/default.html": "{{.content}}",
+		"index.html":            "---\n---\nHome"})
+	defer cleanup()
+
+	// the site is cleared before each build, and the CNAME written again
+	for i := 0; i < 2; i++ {
+		if err := site.Generate(); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := os.Stat(filepath.Join(site.Dest, "CNAME")); i > 0 && !os.IsNotExist(err) {
+			t.Errorf("Expected the CNAME cleared with the site, got %v", err)
+		}
+		if err := site.Deploy(); err != nil {
+			t.Fatal(err)
+		}
+		out, err := exec.Command("git", "--git-dir", remote, "show", "gh-pages:CNAME").Output()
+		if err != nil || string(out) != "www.example.com\n" {
+			t.Errorf("Expected the CNAME [www.example.com] deployed, got [%s] %v", out, err)
+		}
+	}
+}
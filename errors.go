@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// A BuildError records the file, relative to the source directory, that
+// could not be read or generated, and why.
+type BuildError struct {
+	Path string
+	Err  error
+}
+
+func (e *BuildError) Error() string {
+	return e.Path + ": " + e.Err.Error()
+}
+
+// BuildErrors lists every file that could not be read or generated, so that
+// a broken site can be fixed in one go rather than one error at a time.
+type BuildErrors []*BuildError
+
+func (e BuildErrors) Error() string {
+	msgs := []string{}
+	for _, err := range e {
+		msgs = append(msgs, err.Error())
+	}
+	return fmt.Sprintf("%d file(s) failed to build:\n%s", len(e), strings.Join(msgs, "\n"))
+}
+
+// Helper function that returns the error as a BuildError for the file,
+// unless it already is one.
+func newBuildError(path string, err error) *BuildError {
+	if e, ok := err.(*BuildError); ok {
+		return e
+	}
+	return &BuildError{path, err}
+}
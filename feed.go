@@ -0,0 +1,172 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+var MsgFeedFile = "Generating Feed: %s"
+
+// Default path and maximum number of entries of the Atom feed.
+const (
+	defaultFeedPath  = "feed.xml"
+	defaultFeedLimit = 10
+)
+
+// Atom feed of the site's most recent posts.
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Lang    string      `xml:"http://www.w3.org/XML/1998/namespace lang,attr,omitempty"`
+	Title   string      `xml:"title"`
+	Id      string      `xml:"id"`
+	Links   []atomLink  `xml:"link"`
+	Updated string      `xml:"updated"`
+	Author  *atomAuthor `xml:"author,omitempty"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomEntry struct {
+	Lang    string       `xml:"http://www.w3.org/XML/1998/namespace lang,attr,omitempty"`
+	Title   string       `xml:"title"`
+	Id      string       `xml:"id"`
+	Link    atomLink     `xml:"link"`
+	Updated string       `xml:"updated"`
+	Summary *atomContent `xml:"summary,omitempty"`
+	Content *atomContent `xml:"content,omitempty"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomContent struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+// Helper function that returns the path and maximum number of entries of the
+// Atom feed, and whether it is enabled. The feed is enabled in the
+// _config.yml with either:
+//
+//	feed: true
+//
+//	feed:
+//	  path: atom.xml
+//	  limit: 20
+//	  excerpt_only: true
+//
+// With excerpt_only, entries have a plain text summary of the post, see
+// excerpt_length, rather than its full content.
+func feedOptions(conf Config) (path string, limit int, ok bool) {
+	path = conf.GetString("feed.path")
+	if path == "" {
+		path = defaultFeedPath
+	}
+	limit = conf.GetInt("feed.limit", defaultFeedLimit)
+	if limit <= 0 {
+		limit = defaultFeedLimit
+	}
+
+	switch v := conf.Get("feed").(type) {
+	case bool:
+		ok = v
+	case map[interface{}]interface{}:
+		ok = true
+	}
+	return
+}
+
+// Helper function that writes an Atom feed of the most recent posts to the
+// destination directory. Each entry is identified by the post's guid, so it
+// stays the same if the post's permalink changes.
+func (s *Site) writeFeed() error {
+	path, limit, ok := feedOptions(s.Conf)
+	if !ok {
+		return nil
+	}
+
+	posts := make([]Page, len(s.posts))
+	copy(posts, s.posts)
+	sort.Stable(byDate(posts))
+	if len(posts) > limit {
+		posts = posts[:limit]
+	}
+
+	base := s.siteUrl("/")
+	feed := atomFeed{
+		Lang:    s.Conf.GetString("lang"),
+		Title:   s.Conf.GetString("title"),
+		Id:      base,
+		Links:   []atomLink{{Href: base}, {Href: base + filepath.ToSlash(path), Rel: "self"}},
+		Updated: time.Now().Format(time.RFC3339),
+	}
+	if author := s.Conf.GetString("author"); author != "" {
+		feed.Author = &atomAuthor{author}
+	}
+	if len(posts) > 0 {
+		feed.Updated = posts[0].GetDate().Format(time.RFC3339)
+	}
+
+	for _, post := range posts {
+		entry := atomEntry{
+			Title:   post.GetTitle(),
+			Id:      post.GetString("guid"),
+			Link:    atomLink{Href: s.pageUrl(post)},
+			Updated: post.GetDate().Format(time.RFC3339),
+		}
+		if s.Conf.GetBool("feed.excerpt_only", false) {
+			entry.Summary = &atomContent{Type: "text", Body: post.GetString("excerpt")}
+		} else {
+			entry.Content = &atomContent{Type: "html", Body: post.GetContent()}
+		}
+		if lang := post.GetLang(); lang != feed.Lang {
+			entry.Lang = lang
+		}
+		feed.Entries = append(feed.Entries, entry)
+	}
+
+	logf(MsgFeedFile, path)
+	if err := s.writeXml(path, feed); err != nil {
+		return fmt.Errorf("feed: %s", err)
+	}
+	return nil
+}
+
+// Helper function that sets the stable, unique id of a page or post, used by
+// feed readers to tell entries apart. A guid (or id) in the front-end matter
+// is used as is. Otherwise posts get a tag URI built from the host of the
+// site's url, the date and the slug, e.g. tag:example.com,2013-02-14:my-post
+// which doesn't change with the permalink. Pages, which have no date, use
+// their url.
+func (s *Site) setGuid(page Page) {
+	if page.GetString("guid") != "" {
+		return
+	}
+	date, ok := page["date"].(time.Time)
+	if !ok {
+		page["guid"] = s.pageUrl(page)
+		return
+	}
+
+	host := "localhost"
+	if u, err := url.Parse(s.Conf.GetString("url")); err == nil && u.Hostname() != "" {
+		host = u.Hostname()
+	}
+	page["guid"] = fmt.Sprintf("tag:%s,%s:%s", host, date.Format("2006-01-02"), page.GetSlug())
+}
+
+// byDate sorts pages by date, most recent first.
+type byDate []Page
+
+func (p byDate) Len() int           { return len(p) }
+func (p byDate) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
+func (p byDate) Less(i, j int) bool { return p[i].GetDate().After(p[j].GetDate()) }
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/xml"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestFeedOptions(t *testing.T) {
+	tests := []struct {
+		conf  Config
+		path  string
+		limit int
+		ok    bool
+	}{
+		{Config{}, "feed.xml", 10, false},
+		{Config{"feed": false}, "feed.xml", 10, false},
+		{Config{"feed": true}, "feed.xml", 10, true},
+		{Config{"feed": map[interface{}]interface{}{"path": "atom.xml", "limit": 2}}, "atom.xml", 2, true},
+	}
+
+	for _, test := range tests {
+		path, limit, ok := feedOptions(test.conf)
+		if path != test.path || limit != test.limit || ok != test.ok {
+			t.Errorf("Expected feed options [%s] [%d] [%v] got [%s] [%d] [%v] for %v",
+				test.path, test.limit, test.ok, path, limit, ok, test.conf)
+		}
+	}
+}
+
+func TestWriteFeed(t *testing.T) {
+	site, cleanup := newTestSite(t, map[string]string{
+		"_config.yml":                 "title: Blog\nurl: http://example.com:8080\nfeed: {limit: 2}\n",
+		"_layouts/default.html":       "{{.content}}",
+		"_posts/2013-02-14-first.md":  "---\ntitle: First\nguid: urn:uuid:1b4e28ba\n---\nfirst",
+		"_posts/2013-02-15-second.md": "---\ntitle: Second\nlang: fr\n---\nsecond",
+		"_posts/2013-02-16-third.md":  "---\ntitle: Third\npermalink: /moved/\n---\nthird"})
+	defer cleanup()
+
+	if err := site.Generate(); err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadFile(filepath.Join(site.Dest, "feed.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	feed := atomFeed{}
+	if err := xml.Unmarshal(b, &feed); err != nil {
+		t.Fatal(err)
+	}
+
+	if feed.Title != "Blog" || feed.Lang != "en" || len(feed.Entries) != 2 {
+		t.Fatalf("Expected feed of the 2 most recent posts, got %+v", feed)
+	}
+	third, second := feed.Entries[0], feed.Entries[1]
+	if third.Id != "tag:example.com,2013-02-16:third" || third.Link.Href != "http://example.com:8080/moved/" {
+		t.Errorf("Expected entry id independent of the permalink, got [%s] [%s]", third.Id, third.Link.Href)
+	}
+	if second.Lang != "fr" || second.Content.Body != "<p>second</p>\n" {
+		t.Errorf("Expected entry language and content, got %+v", second)
+	}
+}
+
+func TestSetGuid(t *testing.T) {
+	site, cleanup := newTestSite(t, map[string]string{
+		"_config.yml":                "",
+		"_posts/2013-02-14-first.md": "---\nid: 42\n---\nfirst",
+		"about.md":                   "---\ntitle: About\n---\nabout"})
+	defer cleanup()
+
+	if guid := site.posts[0].GetString("guid"); guid != "42" {
+		t.Errorf("Expected guid from the front-end matter id, got [%s]", guid)
+	}
+	if guid := site.pages[0].GetString("guid"); guid != "/about.html" {
+		t.Errorf("Expected guid of a page to be its url, got [%s]", guid)
+	}
+}
+
+func TestWriteFeedExcerpts(t *testing.T) {
+	site, cleanup := newTestSite(t, map[string]string{
+		"_config.yml":                "excerpt_length: 3\nfeed: {excerpt_only: true}\n",
+		"_layouts/default.html":      "{{.content}}",
+		"_posts/2013-02-14-first.md": "---\ntitle: First\n---\nThe *quick* brown fox jumps"})
+	defer cleanup()
+
+	if err := site.Generate(); err != nil {
+		t.Fatal(err)
+	}
+	b, _ := ioutil.ReadFile(filepath.Join(site.Dest, "feed.xml"))
+	feed := atomFeed{}
+	if err := xml.Unmarshal(b, &feed); err != nil {
+		t.Fatal(err)
+	}
+	entry := feed.Entries[0]
+	if entry.Content != nil || entry.Summary == nil || entry.Summary.Body != "The quick brown…" {
+		t.Errorf("Expected excerpt only entry, got %+v", entry)
+	}
+}
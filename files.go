@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Helper function that lists the static files in a directory of the source,
+// sorted by name, e.g. for a downloads page:
+//
+//	{{range files_in "assets/downloads"}}
+//	  <a href="{{.url}}">{{.name}}</a> ({{.size}} bytes)
+//	{{end}}
+//
+// Each file has its name, its path relative to the source, its size in bytes
+// and its url, prefixed with the site's baseurl. Files in subdirectories are
+// left out, unless recursive is true.
+func (s *Site) filesIn(dir string, recursive ...bool) ([]map[string]interface{}, error) {
+	dir = strings.Trim(path.Clean("/"+filepath.ToSlash(dir)), "/")
+	if dir != "" {
+		dir += "/"
+	}
+	all := len(recursive) > 0 && recursive[0]
+	base := strings.TrimRight(s.Conf.GetString("baseurl"), "/")
+
+	names := []string{}
+	for _, file := range s.files {
+		rel := filepath.ToSlash(file)
+		if !strings.HasPrefix(rel, dir) || (!all && strings.Contains(rel[len(dir):], "/")) {
+			continue
+		}
+		names = append(names, rel)
+	}
+	sort.Strings(names)
+
+	files := []map[string]interface{}{}
+	for _, rel := range names {
+		fi, err := os.Stat(filepath.Join(s.Src, filepath.FromSlash(rel)))
+		if err != nil {
+			return nil, fmt.Errorf("files_in: %s", err)
+		}
+		files = append(files, map[string]interface{}{
+			"name": path.Base(rel),
+			"path": rel,
+			"size": fi.Size(),
+			"url":  base + "/" + rel,
+		})
+	}
+	return files, nil
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestFilesIn(t *testing.T) {
+	site, cleanup := newTestSite(t, map[string]string{
+		"_config.yml":                     "baseurl: /blog\n",
+		"assets/downloads/b.zip":          "bb",
+		"assets/downloads/a.pdf":          "a",
+		"assets/downloads/old/c.zip":      "ccc",
+		"assets/style.css":                "body {}",
+		"assets/downloads-list/other.txt": "other"})
+	defer cleanup()
+
+	tests := []struct {
+		dir       string
+		recursive bool
+		urls      []string
+	}{
+		{"assets/downloads", false, []string{"/blog/assets/downloads/a.pdf", "/blog/assets/downloads/b.zip"}},
+		{"/assets/downloads/", true, []string{"/blog/assets/downloads/a.pdf", "/blog/assets/downloads/b.zip", "/blog/assets/downloads/old/c.zip"}},
+		{"assets/missing", false, []string{}},
+	}
+	for _, test := range tests {
+		files, err := site.filesIn(test.dir, test.recursive)
+		if err != nil {
+			t.Fatal(err)
+		}
+		urls := []string{}
+		for _, file := range files {
+			urls = append(urls, file["url"].(string))
+		}
+		if !reflect.DeepEqual(urls, test.urls) {
+			t.Errorf("Expected files in [%s] to be %v got %v", test.dir, test.urls, urls)
+		}
+	}
+}
+
+func TestFilesInTemplate(t *testing.T) {
+	site, cleanup := newTestSite(t, map[string]string{
+		"_config.yml":           "",
+		"_layouts/default.html": "{{.content}}",
+		"downloads/manual.pdf":  "manual",
+		"downloads/notes.txt":   "notes",
+		"index.html":            "---\n---\n{{range files_in \"downloads\"}}<a href=\"{{.url}}\">{{.name}}</a> {{.size}}\n{{end}}"})
+	defer cleanup()
+
+	if err := site.Generate(); err != nil {
+		t.Fatal(err)
+	}
+	b, _ := ioutil.ReadFile(filepath.Join(site.Dest, "index.html"))
+	expected := "<a href=\"/downloads/manual.pdf\">manual.pdf</a> 6\n<a href=\"/downloads/notes.txt\">notes.txt</a> 5\n"
+	if string(b) != expected {
+		t.Errorf("Expected the listing [%s] got [%s]", expected, b)
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// Files smaller than this number of bytes are not worth compressing.
+const defaultGzipMinSize = 1024
+
+// Helper function that writes a gzip compressed copy of each text-based file
+// of the generated site alongside the original, e.g. index.html.gz, for
+// static hosts that serve pre-compressed files when present. Files smaller
+// than gzip_min_size bytes (1024 by default) are skipped.
+func (s *Site) writeGzip() error {
+	min := s.Conf.GetInt("gzip_min_size", defaultGzipMinSize)
+
+	all, err := s.outputFiles()
+	if err != nil {
+		return err
+	}
+	files := []string{}
+	for _, fn := range all {
+		if !isText(fn) {
+			continue
+		}
+		if fi, err := os.Stat(fn); err != nil || fi.Size() < int64(min) {
+			continue
+		}
+		files = append(files, fn)
+	}
+
+	return s.parallel(len(files), func(i int) error {
+		rel, _ := filepath.Rel(s.Dest, files[i])
+		logf(MsgGzipFile, rel)
+		return s.gzipFile(files[i], files[i]+".gz")
+	})
+}
+
+// Helper function that writes a gzip compressed copy of a file.
+func (s *Site) gzipFile(from, to string) error {
+	b, err := ioutil.ReadFile(from)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	w, _ := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	if _, err := w.Write(b); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return s.writeOutput(to, buf.Bytes(), 0644)
+}
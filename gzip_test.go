@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriteGzip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jkl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	large := strings.Repeat("<p>hello world</p>\n", 100)
+	files := map[string]string{
+		"index.html":    large,
+		"css/style.css": large,
+		"small.html":    "<p>hi</p>",
+		"img/photo.jpg": large,
+		"feed/atom.XML": large}
+	for name, content := range files {
+		os.MkdirAll(filepath.Join(dir, filepath.Dir(name)), 0755)
+		ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644)
+	}
+
+	site := Site{Dest: dir, Conf: Config{"gzip": true}}
+	if err := site.writeGzip(); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]bool{
+		"index.html":    true,
+		"css/style.css": true,
+		"small.html":    false,
+		"img/photo.jpg": false,
+		"feed/atom.XML": true}
+	for name, compressed := range expected {
+		gz := filepath.Join(dir, name+".gz")
+		_, err := os.Stat(gz)
+		if compressed != (err == nil) {
+			t.Errorf("Expected compressed copy of [%s] to exist: %v", name, compressed)
+			continue
+		}
+		if !compressed {
+			continue
+		}
+
+		f, _ := os.Open(gz)
+		r, err := gzip.NewReader(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, _ := ioutil.ReadAll(r)
+		f.Close()
+		if !bytes.Equal(b, []byte(files[name])) {
+			t.Errorf("Expected compressed copy of [%s] to match the original", name)
+		}
+	}
+}
+
+func TestWriteGzipMinSize(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jkl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ioutil.WriteFile(filepath.Join(dir, "small.html"), []byte("<p>hi</p>"), 0644)
+
+	site := Site{Dest: dir, Conf: Config{"gzip": true, "gzip_min_size": 0}}
+	if err := site.writeGzip(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "small.html.gz")); err != nil {
+		t.Errorf("Expected small file to be compressed with gzip_min_size 0")
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+)
+
+var MsgRunHook = "Running %s: %s"
+
+// Helper function that runs the commands of a build hook, before_build or
+// after_build, listed in the _config.yml as a command or a list of commands:
+//
+//	before_build: npm run icons
+//	after_build:
+//	  - purgecss --css css/*.css --content **/*.html -o css
+//	  - curl -X POST https://example.com/notify
+//
+// Each command is run by the shell in the directory, in order, and its
+// output is logged. The first command that fails fails the build. Hooks run
+// whatever the _config.yml says, so only build sites you trust.
+func (s *Site) runHooks(key, dir string) error {
+	for _, command := range s.Conf.GetStringSlice(key) {
+		logf(MsgRunHook, key, command)
+
+		out := &logWriter{}
+		cmd := exec.Command("sh", "-c", command)
+		cmd.Dir = dir
+		cmd.Stdout = out
+		cmd.Stderr = out
+		err := cmd.Run()
+		out.Flush()
+		if err != nil {
+			return fmt.Errorf("%s: %s: %s", key, command, err)
+		}
+	}
+	return nil
+}
+
+// A logWriter logs the output of a command line by line, as it is written.
+type logWriter struct {
+	buf bytes.Buffer
+}
+
+func (w *logWriter) Write(p []byte) (int, error) {
+	w.buf.Write(p)
+	for {
+		i := bytes.IndexByte(w.buf.Bytes(), '\n')
+		if i < 0 {
+			break
+		}
+		logf("%s", w.buf.Next(i + 1)[:i])
+	}
+	return len(p), nil
+}
+
+// Logs any output left after the last line break.
+func (w *logWriter) Flush() {
+	if w.buf.Len() > 0 {
+		logf("%s", w.buf.String())
+		w.buf.Reset()
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGenerateHooks(t *testing.T) {
+	site, cleanup := newTestSite(t, map[string]string{
+		"_config.yml": "before_build:\n  - echo before > before.txt\n  - printf -- '---\\n---\\nicons' > icons.html\n" +
+			"after_build:\n  - ls index.html > after.txt\n  - echo done >> after.txt\n",
+		"_layouts/default.html": "<main>{{.content}}</main>",
+		"index.html":            "home"})
+	defer cleanup()
+
+	if err := site.Generate(); err != nil {
+		t.Fatal(err)
+	}
+	if b, err := ioutil.ReadFile(filepath.Join(site.Src, "before.txt")); err != nil || string(b) != "before\n" {
+		t.Errorf("Expected before_build to run in the source directory, got [%s] %v", b, err)
+	}
+	if b, err := ioutil.ReadFile(filepath.Join(site.Dest, "after.txt")); err != nil || string(b) != "index.html\ndone\n" {
+		t.Errorf("Expected after_build to run in order in the generated site, got [%s] %v", b, err)
+	}
+	if b, err := ioutil.ReadFile(filepath.Join(site.Dest, "icons.html")); err != nil || string(b) != "<main>icons</main>" {
+		t.Errorf("Expected the page written by before_build to be generated, got [%s] %v", b, err)
+	}
+}
+
+func TestGenerateHooksFail(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jkl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ioutil.WriteFile(filepath.Join(dir, "_config.yml"), []byte("before_build: exit 3\nafter_build: touch after.txt\n"), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "index.html"), []byte("home"), 0644)
+
+	_, err = NewSite(dir, filepath.Join(dir, "_site"), nil)
+	if err == nil || !strings.HasPrefix(err.Error(), "before_build: exit 3: ") {
+		t.Fatalf("Expected the failing hook to fail the build, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "_site")); err == nil {
+		t.Errorf("Expected the site not to be generated after before_build failed")
+	}
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"fmt"
+	"launchpad.net/goyaml"
+	"path/filepath"
+	"strings"
+)
+
+// Directory containing the translation tables of a site, one per language,
+// e.g. _i18n/fr.yml
+const i18nDir = "_i18n"
+
+// Returns True if the file is a translation table in the _i18n directory.
+func isLocale(fn string) bool {
+	if filepath.Dir(fn) != i18nDir {
+		return false
+	}
+	switch filepath.Ext(fn) {
+	case ".yml", ".yaml":
+		return true
+	}
+	return false
+}
+
+// Helper function that parses a translation table of keys to translated
+// strings. Keys may be nested, and are then looked up with dotted paths,
+// e.g. nav.home
+func parseLocale(c []byte) (map[string]interface{}, error) {
+	locale := map[string]interface{}{}
+	if err := goyaml.Unmarshal(c, &locale); err != nil {
+		return nil, err
+	}
+	return locale, nil
+}
+
+// Helper function that reads the translation table for the language named
+// by the file, e.g. _i18n/pt-BR.yml
+func (s *Site) addLocale(fn, rel string) error {
+	c, err := s.readFile(fn)
+	if err != nil {
+		return err
+	}
+	locale, err := parseLocale(c)
+	if err != nil {
+		return fmt.Errorf("%s: %s", rel, err)
+	}
+	s.locales[removeExt(filepath.Base(rel))] = locale
+	return nil
+}
+
+// Helper function that translates the key to the language, falling back to
+// the base language (pt for pt-BR), then the site's language. Missing keys
+// translate to the key itself, so templates degrade gracefully.
+func (s *Site) translate(lang, key string) string {
+	langs := []string{lang}
+	if i := strings.Index(lang, "-"); i > 0 {
+		langs = append(langs, lang[:i])
+	}
+	langs = append(langs, s.Conf.GetString("lang"))
+
+	for _, lang := range langs {
+		if str, ok := lookupKey(s.locales[lang], key); ok {
+			return str
+		}
+	}
+	return key
+}
+
+// Helper function that returns the template func that translates keys to
+// the page's language, e.g. {{t "nav.home"}}
+func (s *Site) translator(page Page) func(string) string {
+	return func(key string) string {
+		return s.translate(page.GetLang(), key)
+	}
+}
+
+// Helper function that looks up a dotted key in a translation table.
+func lookupKey(locale map[string]interface{}, key string) (string, bool) {
+	v, ok := lookupPath(locale, key)
+	if !ok || v == nil {
+		return "", false
+	}
+	switch v.(type) {
+	case map[interface{}]interface{}, []interface{}:
+		return "", false
+	}
+	return fmt.Sprint(v), true
+}
+
+// Helper function that writes the pages and posts of a multilingual site,
+// one whose _config.yml lists its languages, under a prefix for their
+// language, e.g. fr/about.html. The language of a page is the first
+// directory of its source path, if it is one of the languages, or else its
+// lang. Pages of the default language stay at the root, unless
+// prefix_default_lang is set. Only html pages are moved, so files such as
+// robots.txt stay where they are.
+//
+// Translations of the same page, the ones sharing a translation_key in
+// their front matter or else the same source path under their language
+// directory, are linked to each other with page.translations, mapping each
+// language to its permalink. With i18n_fallback set, pages missing in a
+// language are written in the default language under that language's prefix.
+func (s *Site) localize() {
+	langs := s.Conf.GetStringSlice("languages")
+	if len(langs) == 0 {
+		return
+	}
+	def := s.Conf.GetString("lang")
+	prefixDefault := s.Conf.GetBool("prefix_default_lang", false)
+
+	isLang := map[string]bool{}
+	for _, lang := range langs {
+		isLang[lang] = true
+	}
+
+	// the url of a page, under the prefix of its language
+	setLang := func(page Page, lang, url string) {
+		page["lang"] = lang
+		if lang != def || prefixDefault {
+			url = lang + "/" + url
+		}
+		page["url"] = url
+		page["pretty_url"] = prettyUrl(url)
+	}
+
+	keys := []string{}
+	groups := map[string]map[string]Page{}
+	for _, pages := range [][]Page{s.pages, s.posts} {
+		for _, page := range pages {
+			if page.GetString("output_ext") != ".html" {
+				continue
+			}
+			lang, key, url := page.GetLang(), page.GetPath(), page.GetUrl()
+			if i := strings.Index(key, "/"); i > 0 && isLang[key[:i]] {
+				lang, key = key[:i], key[i+1:]
+				url = strings.TrimPrefix(url, lang+"/")
+			}
+			if !isLang[lang] {
+				continue
+			}
+			if k := page.GetString("translation_key"); k != "" {
+				key = k
+			}
+			setLang(page, lang, url)
+
+			if groups[key] == nil {
+				groups[key] = map[string]Page{}
+				keys = append(keys, key)
+			}
+			groups[key][lang] = page
+		}
+	}
+
+	for _, key := range keys {
+		group := groups[key]
+		if orig, ok := group[def]; ok && s.Conf.GetBool("i18n_fallback", false) && orig["date"] == nil {
+			url := strings.TrimPrefix(orig.GetUrl(), def+"/")
+			for _, lang := range langs {
+				if _, ok := group[lang]; ok {
+					continue
+				}
+				page := Page{}
+				for k, v := range orig {
+					page[k] = v
+				}
+				page["fallback"] = true
+				setLang(page, lang, url)
+				group[lang] = page
+				s.pages = append(s.pages, page)
+			}
+		}
+
+		translations := map[string]string{}
+		for lang, page := range group {
+			translations[lang] = page.Permalink()
+		}
+		for _, page := range group {
+			page["translations"] = translations
+		}
+	}
+}
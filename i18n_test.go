@@ -0,0 +1,123 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestTranslate(t *testing.T) {
+	site := Site{
+		Conf: Config{"lang": "en"},
+		locales: map[string]map[string]interface{}{
+			"en": {"title": "Blog", "nav": map[interface{}]interface{}{"home": "Home", "about": "About"}},
+			"pt": {"nav": map[interface{}]interface{}{"home": "Início"}}}}
+
+	tests := []struct {
+		lang, key, expected string
+	}{
+		{"en", "nav.home", "Home"},
+		{"pt", "nav.home", "Início"},
+		{"pt-BR", "nav.home", "Início"},
+		{"pt", "nav.about", "About"},
+		{"pt", "title", "Blog"},
+		{"fr", "nav.home", "Home"},
+		{"en", "nav.missing", "nav.missing"},
+		{"en", "nav", "nav"},
+	}
+
+	for _, test := range tests {
+		if str := site.translate(test.lang, test.key); str != test.expected {
+			t.Errorf("Expected [%s] translated to [%s] got [%s] for %s", test.key, test.expected, str, test.lang)
+		}
+	}
+}
+
+func TestTranslateTemplate(t *testing.T) {
+	site, cleanup := newTestSite(t, map[string]string{
+		"_config.yml":           "",
+		"_i18n/en.yml":          "greeting: Hello\n",
+		"_i18n/fr.yml":          "greeting: Bonjour\n",
+		"_layouts/default.html": `{{t "greeting"}} {{.content}}`,
+		"index.md":              "---\ntitle: Home\n---\nworld",
+		"fr/index.md":           "---\nlang: fr\n---\nmonde"})
+	defer cleanup()
+
+	if err := site.Generate(); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{
+		"index.html":    "Hello <p>world</p>\n",
+		"fr/index.html": "Bonjour <p>monde</p>\n"}
+	for file, content := range expected {
+		b, _ := ioutil.ReadFile(filepath.Join(site.Dest, file))
+		if string(b) != content {
+			t.Errorf("Expected [%s] to contain [%s] got [%s]", file, content, b)
+		}
+	}
+}
+
+func TestLocalize(t *testing.T) {
+	site, cleanup := newTestSite(t, map[string]string{
+		"_config.yml":   "languages: [en, fr, de]\ni18n_fallback: true\n",
+		"about.html":    "---\ntitle: About\n---\nabout",
+		"fr/about.html": "---\ntitle: A propos\n---\napropos",
+		"contact.html":  "---\ntranslation_key: contact\n---\ncontact",
+		"kontakt.html":  "---\nlang: de\ntranslation_key: contact\n---\nkontakt",
+		"robots.txt":    "---\n---\nUser-agent: *"})
+	defer cleanup()
+
+	urls := map[string]string{}
+	for _, page := range site.pages {
+		urls[page.GetUrl()] = page.GetLang()
+	}
+	expected := map[string]string{
+		"about.html":      "en",
+		"fr/about.html":   "fr",
+		"de/about.html":   "de",
+		"contact.html":    "en",
+		"fr/contact.html": "fr",
+		"de/kontakt.html": "de",
+		"robots.txt":      "en"}
+	if !reflect.DeepEqual(urls, expected) {
+		t.Errorf("Expected pages %v got %v", expected, urls)
+	}
+
+	for _, page := range site.pages {
+		switch page.GetUrl() {
+		case "about.html":
+			translations := map[string]string{"en": "/about.html", "fr": "/fr/about.html", "de": "/de/about.html"}
+			if !reflect.DeepEqual(page["translations"], translations) {
+				t.Errorf("Expected translations %v got %v", translations, page["translations"])
+			}
+		case "de/about.html":
+			if page["fallback"] != true || page.GetTitle() != "About" {
+				t.Errorf("Expected a fallback to the english page, got %v", page)
+			}
+		case "de/kontakt.html":
+			translations := map[string]string{"en": "/contact.html", "fr": "/fr/contact.html", "de": "/de/kontakt.html"}
+			if !reflect.DeepEqual(page["translations"], translations) {
+				t.Errorf("Expected translations %v got %v", translations, page["translations"])
+			}
+		}
+	}
+}
+
+func TestLocalizePrefixDefault(t *testing.T) {
+	site, cleanup := newTestSite(t, map[string]string{
+		"_config.yml": "languages: [en, fr]\nprefix_default_lang: true\n",
+		"index.html":  "---\n---\nhome",
+		"fr/a.html":   "---\n---\na"})
+	defer cleanup()
+
+	urls := []string{}
+	for _, page := range site.pages {
+		urls = append(urls, page.GetUrl())
+	}
+	sort.Strings(urls)
+	if expected := []string{"en/index.html", "fr/a.html"}; !reflect.DeepEqual(urls, expected) {
+		t.Errorf("Expected pages %v got %v", expected, urls)
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// Helper function that returns the render_includes template function bound
+// to the page being rendered. It renders, in order, each of the _includes
+// listed under <position>_includes in the _config.yml, for example:
+//
+//	head_includes: [analytics.html, fonts.html]
+//	foot_includes: [chat.html]
+//
+// so that {{render_includes "head"}} in a theme's layout lets sites add to
+// every page without editing the layout. The extension of the includes may
+// be omitted, and defaults to .html.
+func (s *Site) includesRenderer(page Page) func(string) (string, error) {
+	return func(position string) (string, error) {
+		names := s.Conf.GetStringSlice(position + "_includes")
+		if len(names) == 0 {
+			return "", nil
+		}
+		if s.templ == nil {
+			return "", fmt.Errorf("render_includes: no templates defined for %s_includes", position)
+		}
+
+		data := map[string]interface{}{
+			"site": s.Conf,
+			"page": page,
+		}
+		var buf bytes.Buffer
+		for _, name := range names {
+			name = appendExt(name, ".html")
+			if s.templ.Lookup(name) == nil {
+				return "", fmt.Errorf("render_includes: %s not found in _includes", name)
+			}
+			if err := s.templ.ExecuteTemplate(&buf, name, data); err != nil {
+				return "", err
+			}
+		}
+		return buf.String(), nil
+	}
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRenderIncludes(t *testing.T) {
+	site, cleanup := newTestSite(t, map[string]string{
+		"_config.yml":              "head_includes: [analytics.html, fonts]\nfoot_includes: [chat.html]\n",
+		"_layouts/default.html":    "<head>{{render_includes \"head\"}}</head>{{.content}}{{render_includes \"foot\"}}{{render_includes \"aside\"}}",
+		"_includes/analytics.html": "<script>track('{{.page.title}}')</script>",
+		"_includes/fonts.html":     "<link href=\"fonts.css\">",
+		"_includes/chat.html":      "<div id=\"chat\"></div>",
+		"index.html":               "---\ntitle: Home\n---\nhome"})
+	defer cleanup()
+
+	if err := site.Generate(); err != nil {
+		t.Fatal(err)
+	}
+	b, _ := ioutil.ReadFile(filepath.Join(site.Dest, "index.html"))
+	expected := "<head><script>track('Home')</script><link href=\"fonts.css\"></head>home<div id=\"chat\"></div>"
+	if string(b) != expected {
+		t.Errorf("Expected includes rendered as [%s] got [%s]", expected, b)
+	}
+
+	site.Conf.Set("head_includes", []interface{}{"missing.html"})
+	if err := site.Generate(); err == nil || !strings.Contains(err.Error(), "missing.html") {
+		t.Errorf("Expected error for a missing include, got %v", err)
+	}
+}
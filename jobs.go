@@ -0,0 +1,46 @@
+package main
+
+import (
+	"runtime"
+	"sync"
+)
+
+// Helper function that returns the number of files the site may generate
+// at once, set with jobs in the _config.yml, or the --jobs flag, and by
+// default the number of CPUs. With jobs: 1 the site is generated one file
+// at a time.
+func (s *Site) jobs() int {
+	if n := s.Conf.GetInt("jobs", runtime.NumCPU()); n > 0 {
+		return n
+	}
+	return 1
+}
+
+// Helper function that calls fn for each of the n items, running as many at
+// once as the site's jobs allow. Every phase of the build runs through the
+// same limiter, so that the total number of files generated at once stays
+// bounded. Returns the first error, once all items are done.
+func (s *Site) parallel(n int, fn func(i int) error) error {
+	if s.limit == nil {
+		s.limit = make(chan struct{}, s.jobs())
+	}
+
+	var wg sync.WaitGroup
+	var once sync.Once
+	var first error
+	for i := 0; i < n; i++ {
+		s.limit <- struct{}{}
+		wg.Add(1)
+		go func(i int) {
+			defer func() {
+				<-s.limit
+				wg.Done()
+			}()
+			if err := fn(i); err != nil {
+				once.Do(func() { first = err })
+			}
+		}(i)
+	}
+	wg.Wait()
+	return first
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestParallel(t *testing.T) {
+	site := Site{Conf: Config{"jobs": 3}}
+
+	var mu sync.Mutex
+	running, max, done := 0, 0, 0
+	err := site.parallel(20, func(i int) error {
+		mu.Lock()
+		running++
+		if running > max {
+			max = running
+		}
+		mu.Unlock()
+
+		time.Sleep(time.Millisecond)
+
+		mu.Lock()
+		running--
+		done++
+		mu.Unlock()
+		if i == 7 {
+			return errors.New("boom")
+		}
+		return nil
+	})
+	if err == nil || err.Error() != "boom" {
+		t.Errorf("Expected the error of the failed item, got %v", err)
+	}
+	if done != 20 {
+		t.Errorf("Expected all 20 items to run, got %d", done)
+	}
+	if max > 3 {
+		t.Errorf("Expected at most 3 items at once, got %d", max)
+	}
+}
@@ -0,0 +1,489 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// Matches a Liquid tag, e.g. {% include footer.html %}, or an output, e.g.
+// {{ page.title | upcase }}, along with their whitespace control dashes.
+var liquidMarkup = regexp.MustCompile(`(?s)\{%(-?)\s*(.*?)\s*(-?)%\}|\{\{(-?)\s*(.*?)\s*(-?)\}\}`)
+
+// Matches a Liquid variable, e.g. page.title, or a literal.
+var (
+	liquidPath   = regexp.MustCompile(`^[A-Za-z_]\w*(\.\w+)*$`)
+	liquidNumber = regexp.MustCompile(`^-?\d+(\.\d+)?$`)
+)
+
+// Liquid filters that are template functions of the same name and arguments,
+// with the input as the first argument.
+var liquidFilters = []string{
+	"capitalize", "date_to_string", "date_to_xmlschema", "downcase",
+	"markdownify", "newline_to_br", "number_of_words", "remove",
+	"remove_first", "replace", "replace_first", "split", "strip_html",
+	"strip_newlines", "truncate", "truncatewords", "upcase", "url_encode"}
+
+// Liquid comparison operators and the template functions they map to.
+var liquidOperators = map[string]string{
+	"==": "eq", "!=": "ne", "<>": "ne", "<": "lt", ">": "gt", "<=": "le", ">=": "ge"}
+
+// Keywords of text/template, the first word of an action that is left as is.
+var templateKeywords = []string{
+	"block", "break", "continue", "define", "else", "end", "if", "nil",
+	"range", "template", "with"}
+
+// A liquidBlock is a Liquid tag opened in a template and not yet closed,
+// such as for, along with the loop variable it declares, if any.
+type liquidBlock struct {
+	tag, variable string
+}
+
+// A liquidTranslator translates the Liquid tags of a template, read from the
+// named file, to text/template actions.
+type liquidTranslator struct {
+	name   string
+	funcs  map[string]bool
+	vars   map[string]int
+	blocks []liquidBlock
+	line   int
+}
+
+// Helper function that translates the most common Liquid tags and outputs of
+// a Jekyll template to text/template, when jekyll_compat is enabled in the
+// _config.yml, so that many Jekyll themes can be used unmodified:
+//
+//	{{ page.title | truncate: 20 }}     {{truncate $.page.title 20}}
+//	{% include footer.html %}           {{template "footer.html" $}}
+//	{% if page.tags and x != "a" %}     {{if and $.page.tags (ne $.x "a")}}
+//	{% for post in site.posts %}        {{range $post := $.site.posts}}
+//	{% assign n = post.title %}         {{$n := $post.title}}
+//
+// Along with elsif, else, unless, raw, comment, and seo. Other tags fail with
+// an unsupported Liquid tag error, pointing at the file and line. Actions in
+// the template that aren't Liquid, such as {{.page.title}} or {{seo}}, with
+// funcs the names of the template functions, are left as they are.
+func translateLiquid(name, src string, funcs map[string]bool) (string, error) {
+	t := liquidTranslator{name: name, funcs: funcs, vars: map[string]int{}}
+
+	var out strings.Builder
+	pos := 0
+	for pos < len(src) {
+		m := liquidMarkup.FindStringSubmatchIndex(src[pos:])
+		if m == nil {
+			break
+		}
+		for i := range m {
+			if m[i] >= 0 {
+				m[i] += pos
+			}
+		}
+		out.WriteString(src[pos:m[0]])
+		t.line = 1 + strings.Count(src[:m[0]], "\n")
+		pos = m[1]
+
+		// outputs
+		if m[2] < 0 {
+			expr := src[m[10]:m[11]]
+			action, err := t.output(expr)
+			if err != nil {
+				return "", err
+			}
+			if action == "" {
+				out.WriteString(src[m[0]:m[1]])
+				continue
+			}
+			out.WriteString(trimAction(action, m[9] > m[8], m[13] > m[12]))
+			continue
+		}
+
+		// tags
+		fields := strings.Fields(src[m[4]:m[5]])
+		if len(fields) == 0 {
+			return "", t.errorf("empty Liquid tag")
+		}
+		tag, args := fields[0], strings.TrimSpace(strings.TrimPrefix(src[m[4]:m[5]], fields[0]))
+
+		switch tag {
+		case "raw", "comment":
+			end := regexp.MustCompile(`\{%-?\s*end` + tag + `\s*-?%\}`).FindStringIndex(src[pos:])
+			if end == nil {
+				return "", t.errorf("%s is not closed", tag)
+			}
+			if tag == "raw" {
+				out.WriteString(strings.Replace(src[pos:pos+end[0]], "{{", `{{"{{"}}`, -1))
+			}
+			pos += end[1]
+			continue
+		}
+
+		action, err := t.tag(tag, args)
+		if err != nil {
+			return "", err
+		}
+		out.WriteString(trimAction(action, m[3] > m[2], m[7] > m[6]))
+	}
+	out.WriteString(src[pos:])
+
+	if len(t.blocks) > 0 {
+		t.line = 1 + strings.Count(src, "\n")
+		return "", t.errorf("%s is not closed", t.blocks[len(t.blocks)-1].tag)
+	}
+	return out.String(), nil
+}
+
+// Functions built into text/template.
+var templateBuiltins = []string{
+	"and", "call", "html", "index", "slice", "js", "len", "not", "or", "print",
+	"printf", "println", "urlquery", "eq", "ne", "lt", "le", "gt", "ge"}
+
+// Helper function that returns the names of the template functions, so
+// that actions calling them aren't taken for Liquid outputs.
+func liquidFuncs(maps ...map[string]interface{}) map[string]bool {
+	names := map[string]bool{}
+	for _, name := range templateBuiltins {
+		names[name] = true
+	}
+	for _, m := range maps {
+		for name := range m {
+			names[name] = true
+		}
+	}
+	return names
+}
+
+// Helper function that parses the templates, the same as ParseFiles, once
+// their Liquid tags are translated.
+func (s *Site) parseLiquid(templ *template.Template) (*template.Template, error) {
+	for _, fn := range s.layouts {
+		b, err := ioutil.ReadFile(fn)
+		if err != nil {
+			return nil, err
+		}
+		rel, _ := filepath.Rel(s.Src, fn)
+		src, err := translateLiquid(rel, string(b), s.liquid)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := templ.New(filepath.Base(fn)).Parse(src); err != nil {
+			return nil, err
+		}
+	}
+	return templ, nil
+}
+
+// Helper function that returns an error at the current line of the template.
+func (t *liquidTranslator) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("%s:%d: %s", t.name, t.line, fmt.Sprintf(format, args...))
+}
+
+// Helper function that translates a Liquid tag to an action.
+func (t *liquidTranslator) tag(tag, args string) (string, error) {
+	switch tag {
+	case "include":
+		fields := strings.Fields(args)
+		if len(fields) != 1 || strings.Contains(args, "{{") {
+			return "", t.errorf("unsupported Liquid tag include with parameters")
+		}
+		return fmt.Sprintf("{{template %s $}}", strconv.Quote(strings.Trim(fields[0], `"'`))), nil
+
+	case "if", "unless", "elsif":
+		cond, err := t.condition(args)
+		if err != nil {
+			return "", err
+		}
+		switch tag {
+		case "unless":
+			t.blocks = append(t.blocks, liquidBlock{tag: tag})
+			return fmt.Sprintf("{{if not %s}}", wrapCall(cond)), nil
+		case "elsif":
+			if err := t.inBlock(tag, "if"); err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("{{else if %s}}", cond), nil
+		}
+		t.blocks = append(t.blocks, liquidBlock{tag: tag})
+		return fmt.Sprintf("{{if %s}}", cond), nil
+
+	case "else":
+		if err := t.inBlock(tag, "if", "unless", "for"); err != nil {
+			return "", err
+		}
+		return "{{else}}", nil
+
+	case "for":
+		fields := strings.Fields(args)
+		if len(fields) != 3 || fields[1] != "in" || !liquidPath.MatchString(fields[0]) {
+			return "", t.errorf("unsupported Liquid tag for %s", args)
+		}
+		list, err := t.operand(fields[2])
+		if err != nil {
+			return "", err
+		}
+		t.blocks = append(t.blocks, liquidBlock{tag: tag, variable: fields[0]})
+		t.vars[fields[0]]++
+		return fmt.Sprintf("{{range $%s := %s}}", fields[0], list), nil
+
+	case "endif", "endunless", "endfor":
+		if err := t.inBlock(tag, strings.TrimPrefix(tag, "end")); err != nil {
+			return "", err
+		}
+		block := t.blocks[len(t.blocks)-1]
+		t.blocks = t.blocks[:len(t.blocks)-1]
+		if block.variable != "" {
+			t.vars[block.variable]--
+		}
+		return "{{end}}", nil
+
+	case "assign":
+		parts := strings.SplitN(args, "=", 2)
+		name := strings.TrimSpace(parts[0])
+		if len(parts) != 2 || !liquidPath.MatchString(name) || strings.Contains(name, ".") {
+			return "", t.errorf("unsupported Liquid tag assign %s", args)
+		}
+		value, err := t.expression(parts[1])
+		if err != nil {
+			return "", err
+		}
+		op := ":="
+		if t.vars[name] > 0 {
+			op = "="
+		}
+		t.vars[name]++
+		return fmt.Sprintf("{{$%s %s %s}}", name, op, value), nil
+
+	case "seo":
+		return "{{seo}}", nil
+	}
+	return "", t.errorf("unsupported Liquid tag %s", tag)
+}
+
+// Helper function that returns an error unless the innermost open block is
+// one of the given tags.
+func (t *liquidTranslator) inBlock(tag string, blocks ...string) error {
+	if len(t.blocks) == 0 || !contains(blocks, t.blocks[len(t.blocks)-1].tag) {
+		return t.errorf("unexpected Liquid tag %s", tag)
+	}
+	return nil
+}
+
+// Helper function that translates a Liquid output to an action, or returns
+// an empty string if it is an action of text/template to be left as is.
+func (t *liquidTranslator) output(expr string) (string, error) {
+	tokens := liquidTokens(expr)
+	if len(tokens) == 0 || (len(tokens) > 1 && tokens[1] != "|") {
+		return "", nil
+	}
+	first := strings.SplitN(tokens[0], ".", 2)[0]
+	if !liquidPath.MatchString(tokens[0]) && !isLiquidLiteral(tokens[0]) {
+		return "", nil
+	}
+	if t.vars[first] == 0 && (t.funcs[first] || contains(templateKeywords, first)) {
+		return "", nil
+	}
+	action, err := t.expression(expr)
+	if err != nil {
+		return "", err
+	}
+	return "{{" + action + "}}", nil
+}
+
+// Helper function that translates a value followed by filters, e.g.
+// page.title | replace: "a", "b" | upcase, to nested function calls, e.g.
+// upcase (replace $.page.title "a" "b").
+func (t *liquidTranslator) expression(expr string) (string, error) {
+	tokens := liquidTokens(expr)
+	if len(tokens) == 0 {
+		return "", t.errorf("missing Liquid value")
+	}
+	value, err := t.operand(tokens[0])
+	if err != nil {
+		return "", err
+	}
+	tokens = tokens[1:]
+
+	for len(tokens) > 0 {
+		if tokens[0] != "|" || len(tokens) < 2 {
+			return "", t.errorf("invalid Liquid filter %s", strings.Join(tokens, " "))
+		}
+		filter := tokens[1]
+		if !contains(liquidFilters, filter) {
+			return "", t.errorf("unsupported Liquid filter %s", filter)
+		}
+		tokens = tokens[2:]
+
+		call := []string{filter, wrapCall(value)}
+		if len(tokens) > 0 && tokens[0] == ":" {
+			tokens = tokens[1:]
+			for len(tokens) > 0 && tokens[0] != "|" {
+				arg, err := t.operand(tokens[0])
+				if err != nil {
+					return "", err
+				}
+				call = append(call, wrapCall(arg))
+				tokens = tokens[1:]
+				if len(tokens) > 0 && tokens[0] == "," {
+					tokens = tokens[1:]
+				}
+			}
+		}
+		value = strings.Join(call, " ")
+	}
+	return value, nil
+}
+
+// Helper function that translates a Liquid condition, such as a and b != 1,
+// to a call of the and, or and comparison functions. Like Liquid, and and or
+// are evaluated from right to left.
+func (t *liquidTranslator) condition(expr string) (string, error) {
+	tokens := liquidTokens(expr)
+	var terms, ops []string
+	for len(tokens) > 0 {
+		end := 0
+		for end < len(tokens) && tokens[end] != "and" && tokens[end] != "or" {
+			end++
+		}
+		term, err := t.comparison(tokens[:end])
+		if err != nil {
+			return "", err
+		}
+		terms = append(terms, term)
+		if end < len(tokens) {
+			ops = append(ops, tokens[end])
+			end++
+		}
+		tokens = tokens[end:]
+	}
+	if len(terms) == 0 || len(terms) == len(ops) {
+		return "", t.errorf("invalid Liquid condition %s", expr)
+	}
+
+	cond := terms[len(terms)-1]
+	for i := len(ops) - 1; i >= 0; i-- {
+		cond = fmt.Sprintf("%s %s %s", ops[i], wrapCall(terms[i]), wrapCall(cond))
+	}
+	return cond, nil
+}
+
+// Helper function that translates a value, or two values compared, e.g.
+// page.layout == "post" to eq $.page.layout "post".
+func (t *liquidTranslator) comparison(tokens []string) (string, error) {
+	switch len(tokens) {
+	case 1:
+		return t.operand(tokens[0])
+	case 3:
+		fn, ok := liquidOperators[tokens[1]]
+		if !ok {
+			return "", t.errorf("unsupported Liquid operator %s", tokens[1])
+		}
+		a, err := t.operand(tokens[0])
+		if err != nil {
+			return "", err
+		}
+		b, err := t.operand(tokens[2])
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%s %s %s", fn, a, b), nil
+	}
+	return "", t.errorf("invalid Liquid condition %s", strings.Join(tokens, " "))
+}
+
+// Helper function that translates a Liquid variable or literal. Variables
+// are looked up in the data of the template, e.g. $.page.title, unless they
+// are declared with for or assign.
+func (t *liquidTranslator) operand(token string) (string, error) {
+	switch {
+	case isLiquidLiteral(token):
+		if token[0] == '\'' {
+			return strconv.Quote(token[1 : len(token)-1]), nil
+		}
+		return token, nil
+	case liquidPath.MatchString(token):
+		first := strings.SplitN(token, ".", 2)[0]
+		if first == "forloop" {
+			return "", t.errorf("unsupported Liquid variable %s", token)
+		}
+		if t.vars[first] > 0 {
+			return "$" + token, nil
+		}
+		return "$." + token, nil
+	}
+	return "", t.errorf("invalid Liquid value %s", token)
+}
+
+// Returns True if the token is a Liquid string, number, boolean or nil.
+func isLiquidLiteral(token string) bool {
+	switch token {
+	case "true", "false", "nil":
+		return true
+	}
+	if len(token) >= 2 && (token[0] == '"' || token[0] == '\'') && token[len(token)-1] == token[0] {
+		return true
+	}
+	return liquidNumber.MatchString(token)
+}
+
+// Helper function that splits a Liquid expression into its values, quoted
+// strings, operators and the punctuation of filters.
+func liquidTokens(expr string) []string {
+	tokens := []string{}
+	for i := 0; i < len(expr); {
+		c := expr[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '"' || c == '\'':
+			end := strings.IndexByte(expr[i+1:], c)
+			if end < 0 {
+				tokens = append(tokens, expr[i:])
+				return tokens
+			}
+			tokens = append(tokens, expr[i:i+end+2])
+			i += end + 2
+		case c == '|' || c == ':' || c == ',':
+			tokens = append(tokens, string(c))
+			i++
+		case strings.IndexByte("=!<>", c) >= 0:
+			j := i + 1
+			for j < len(expr) && strings.IndexByte("=!<>", expr[j]) >= 0 {
+				j++
+			}
+			tokens = append(tokens, expr[i:j])
+			i = j
+		default:
+			j := i + 1
+			for j < len(expr) && strings.IndexByte(" \t\n\r\"'|:,=!<>", expr[j]) < 0 {
+				j++
+			}
+			tokens = append(tokens, expr[i:j])
+			i = j
+		}
+	}
+	return tokens
+}
+
+// Helper function that wraps a function call in parentheses, to pass it as
+// an argument.
+func wrapCall(expr string) string {
+	if strings.Contains(expr, " ") && !strings.HasPrefix(expr, `"`) {
+		return "(" + expr + ")"
+	}
+	return expr
+}
+
+// Helper function that adds the whitespace control dashes of a Liquid tag
+// or output to the action it was translated to.
+func trimAction(action string, left, right bool) string {
+	if left {
+		action = "{{- " + strings.TrimPrefix(action, "{{")
+	}
+	if right {
+		action = strings.TrimSuffix(action, "}}") + " -}}"
+	}
+	return action
+}
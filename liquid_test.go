@@ -0,0 +1,80 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTranslateLiquid(t *testing.T) {
+	funcs := liquidFuncs(funcMap)
+	tests := map[string]string{
+		"<h1>{{ page.title }}</h1>":                                                        "<h1>{{$.page.title}}</h1>",
+		"{{ content }}":                                                                    "{{$.content}}",
+		"{{ page.title | truncate: 20 | upcase }}":                                         "{{upcase (truncate $.page.title 20)}}",
+		`{{ page.title | replace: 'a', "b c" }}`:                                           `{{replace $.page.title "a" "b c"}}`,
+		"{%- include footer.html -%}":                                                      `{{- template "footer.html" $ -}}`,
+		`{% if page.tags and page.layout != "post" %}x{% endif %}`:                         `{{if and $.page.tags (ne $.page.layout "post")}}x{{end}}`,
+		"{% if a or b and c %}{% elsif d >= 2 %}{% else %}{% endif %}":                     "{{if or $.a (and $.b $.c)}}{{else if ge $.d 2}}{{else}}{{end}}",
+		"{% unless page.draft %}x{% endunless %}":                                          "{{if not $.page.draft}}x{{end}}",
+		"{% for post in site.posts %}{{ post.title }}{% else %}none{% endfor %}{{ post }}": "{{range $post := $.site.posts}}{{$post.title}}{{else}}none{{end}}{{$.post}}",
+		"{% assign n = page.title | downcase %}{% assign n = 'x' %}{{ n }}":                `{{$n := downcase $.page.title}}{{$n = "x"}}{{$n}}`,
+		"{% raw %}{{ page.title }}{% endraw %}":                                            `{{"{{"}} page.title }}`,
+		"a{% comment %}{% bogus %}{% endcomment %}b":                                       "ab",
+		"{% seo %}": "{{seo}}",
+		"{{.page.title}} {{seo}} {{template \"x\" .}} {{ $x }}": "{{.page.title}} {{seo}} {{template \"x\" .}} {{ $x }}",
+	}
+	for in, expected := range tests {
+		out, err := translateLiquid("t.html", in, funcs)
+		if err != nil || out != expected {
+			t.Errorf("Expected [%s] to be translated to [%s] got [%s] %v", in, expected, out, err)
+		}
+	}
+
+	errs := map[string]string{
+		"a\n{% capture x %}":                       "t.html:2: unsupported Liquid tag capture",
+		"{% include nav.html active=true %}":       "t.html:1: unsupported Liquid tag include",
+		"{{ page.title | append: 'x' }}":           "t.html:1: unsupported Liquid filter append",
+		"{% if tags contains 'go' %}":              "t.html:1: unsupported Liquid operator contains",
+		"{% for x in list %}\n{{ forloop.index }}": "t.html:2: unsupported Liquid variable forloop.index",
+		"{% if a %}\n":                             "t.html:2: if is not closed",
+		"{% endfor %}":                             "t.html:1: unexpected Liquid tag endfor",
+	}
+	for in, msg := range errs {
+		if _, err := translateLiquid("t.html", in, funcs); err == nil || !strings.Contains(err.Error(), msg) {
+			t.Errorf("Expected error [%s] for [%s] got %v", msg, in, err)
+		}
+	}
+}
+
+func TestJekyllCompat(t *testing.T) {
+	site, cleanup := newTestSite(t, map[string]string{
+		"_config.yml":            "jekyll_compat: true\ntitle: Blog\n",
+		"_includes/footer.html":  "<footer>{{ site.title }}</footer>",
+		"_layouts/default.html":  "<h1>{{ page.title | upcase }}</h1>{{ content }}{% include footer.html %}",
+		"_posts/2013-02-14-a.md": "---\ntitle: First\n---\nText",
+		"index.html":             "---\ntitle: Home\n---\n{% for post in site.posts %}{% if post.title == \"First\" %}<p>{{ post.title }}</p>{% endif %}{% endfor %}"})
+	defer cleanup()
+
+	if err := site.Generate(); err != nil {
+		t.Fatal(err)
+	}
+	b, _ := ioutil.ReadFile(filepath.Join(site.Dest, "index.html"))
+	if expected := "<h1>HOME</h1><p>First</p><footer>Blog</footer>"; string(b) != expected {
+		t.Errorf("Expected [%s] got [%s]", expected, b)
+	}
+}
+
+func TestJekyllCompatUnsupported(t *testing.T) {
+	site, cleanup := newTestSite(t, map[string]string{
+		"_config.yml":           "jekyll_compat: true\n",
+		"_layouts/default.html": "<main>\n{% case page.layout %}{% endcase %}</main>",
+		"index.html":            "---\n---\nHome"})
+	defer cleanup()
+
+	err := site.Generate()
+	if err == nil || !strings.Contains(err.Error(), filepath.Join("_layouts", "default.html")+":2: unsupported Liquid tag case") {
+		t.Errorf("Expected an unsupported Liquid tag error, got %v", err)
+	}
+}
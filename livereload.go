@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+
+	"golang.org/x/net/websocket"
+)
+
+// Path of the websocket endpoint that live reload clients connect to.
+const liveReloadPath = "/__livereload"
+
+// Script injected into html pages served by the development server. It
+// reloads the page when the server pushes a message over the websocket.
+const liveReloadScript = `<script>
+(function() {
+  var proto = location.protocol === "https:" ? "wss://" : "ws://";
+  var ws = new WebSocket(proto + location.host + "` + liveReloadPath + `");
+  ws.onmessage = function() { location.reload(); };
+})();
+</script>
+`
+
+// Live reloader used by the development server, or nil if live reload is
+// disabled. It is enabled when the site is served with the --auto option.
+var reloader *liveReloader
+
+// A liveReloader keeps track of the browsers connected to the development
+// server and tells them to reload whenever the site is regenerated.
+type liveReloader struct {
+	mu      sync.Mutex
+	clients map[chan bool]bool
+}
+
+func newLiveReloader() *liveReloader {
+	return &liveReloader{clients: map[chan bool]bool{}}
+}
+
+// Reload pushes a reload message to all connected browsers. It is safe to
+// call on a nil liveReloader, in which case it does nothing.
+func (l *liveReloader) Reload() {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for client := range l.clients {
+		select {
+		case client <- true:
+		default:
+		}
+	}
+}
+
+// ServeHTTP upgrades the request to a websocket that receives a message
+// whenever the site is regenerated.
+func (l *liveReloader) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	websocket.Handler(l.handle).ServeHTTP(w, r)
+}
+
+func (l *liveReloader) handle(ws *websocket.Conn) {
+	client := make(chan bool, 1)
+	l.mu.Lock()
+	l.clients[client] = true
+	l.mu.Unlock()
+
+	defer func() {
+		l.mu.Lock()
+		delete(l.clients, client)
+		l.mu.Unlock()
+	}()
+
+	// the browser never sends anything, so reading only returns once the
+	// connection is closed
+	closed := make(chan bool)
+	go func() {
+		io.Copy(ioutil.Discard, ws)
+		close(closed)
+	}()
+
+	select {
+	case <-client:
+		websocket.Message.Send(ws, "reload")
+	case <-closed:
+	}
+}
+
+// Helper function that injects a script into an html page, just before the
+// closing body tag or at the end of the page if it has none.
+func injectScript(html []byte, script string) []byte {
+	i := bytes.LastIndex(lowerASCII(html), []byte("</body>"))
+	if i < 0 {
+		return append(html, script...)
+	}
+
+	b := make([]byte, 0, len(html)+len(script))
+	b = append(b, html[:i]...)
+	b = append(b, script...)
+	return append(b, html[i:]...)
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+func TestInjectScript(t *testing.T) {
+	tests := map[string]string{
+		"<html><body>hi</body></html>": "<html><body>hi<script/></body></html>",
+		"<HTML><BODY>hi</BODY></HTML>": "<HTML><BODY>hi<script/></BODY></HTML>",
+		"<p>hi</p>":                    "<p>hi</p><script/>",
+		"<body>\u212a</BODY>\xff":      "<body>\u212a<script/></BODY>\xff"}
+
+	for key, val := range tests {
+		if result := string(injectScript([]byte(key), "<script/>")); result != val {
+			t.Errorf("Expected injected html [%s] got [%s]", val, result)
+		}
+	}
+}
+
+func TestLiveReload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jkl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ioutil.WriteFile(filepath.Join(dir, "index.html"), []byte("<body>hello</body>"), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "style.css"), []byte("body {}"), 0644)
+
+	reloader = newLiveReloader()
+	defer func() { reloader = nil }()
+
+	site := &Site{Dest: dir, Conf: Config{}}
+	server := httptest.NewServer(siteHandler(site))
+	defer server.Close()
+
+	// html pages get the reload script, other files are served untouched
+	w := httptest.NewRecorder()
+	siteHandler(site).ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if body := w.Body.String(); !strings.Contains(body, liveReloadPath) {
+		t.Errorf("Expected reload script injected into html page, got [%s]", body)
+	}
+	w = httptest.NewRecorder()
+	siteHandler(site).ServeHTTP(w, httptest.NewRequest("GET", "/style.css", nil))
+	if body := w.Body.String(); body != "body {}" {
+		t.Errorf("Expected css file served untouched, got [%s]", body)
+	}
+
+	ws, err := websocket.Dial("ws"+strings.TrimPrefix(server.URL, "http")+liveReloadPath, "", server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ws.Close()
+
+	// wait for the client to be registered before reloading
+	for i := 0; i < 100; i++ {
+		reloader.mu.Lock()
+		n := len(reloader.clients)
+		reloader.mu.Unlock()
+		if n > 0 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	reloader.Reload()
+
+	var msg string
+	ws.SetDeadline(time.Now().Add(5 * time.Second))
+	if err := websocket.Message.Receive(ws, &msg); err != nil || msg != "reload" {
+		t.Errorf("Expected reload message got [%s] %v", msg, err)
+	}
+}
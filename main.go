@@ -1,11 +1,9 @@
 package main
 
 import (
-	"github.com/howeyc/fsnotify"
-
 	"flag"
 	"fmt"
-	"net/http"
+	"log"
 	"os"
 	"path/filepath"
 	"strings"
@@ -17,7 +15,7 @@ var (
 	source = flag.String("source", "", "")
 
 	// directory where Jekyll will write files to
-	destination = flag.String("destination", "_site", "")
+	destination = flag.String("destination", "", "")
 
 	// fires up a server that will host your _site directory if True
 	server = flag.Bool("server", false, "")
@@ -28,6 +26,70 @@ var (
 	// re-generates the site when files are modified.
 	auto = flag.Bool("auto", false, "")
 
+	// keeps the site url from the _config.yml when serving the website,
+	// rather than the address of the local server, if True
+	productionUrl = flag.Bool("production-url", false, "")
+
+	// serves the website already in the destination directory, without
+	// generating it first, if True
+	noBuild = flag.Bool("no-build", false, "")
+
+	// opens the served website in the default browser if True
+	openUrl = flag.Bool("open", false, "")
+
+	// serves the website over https if True
+	useTLS = flag.Bool("tls", false, "")
+
+	// the certificate and key used to serve over https. a self-signed
+	// certificate is generated if not provided.
+	tlsCert = flag.String("tls-cert", "", "")
+	tlsKey  = flag.String("tls-key", "", "")
+
+	// renders posts in the _drafts folder if True
+	drafts = flag.Bool("drafts", false, "")
+
+	// publishes posts with a future date if True
+	future = flag.Bool("future", false, "")
+
+	// renders posts and pages marked as unpublished if True
+	unpublished = flag.Bool("unpublished", false, "")
+
+	// renders drafts, future posts and unpublished pages if True
+	preview = flag.Bool("preview", false, "")
+
+	// fails if any page or post has front-end matter that can't be parsed,
+	// rather than skipping it with a warning
+	strictFrontMatter = flag.Bool("strict-front-matter", false, "")
+
+	// fails if any page or post doesn't match the front_matter_schema,
+	// rather than printing a warning
+	validateFrontMatter = flag.Bool("validate-front-matter", false, "")
+
+	// only generates the given number of most recent posts, if set
+	limitPosts = flag.Int("limit_posts", 0, "")
+
+	// only generates the pages and files below the directory, if set
+	only = flag.String("only", "", "")
+
+	// checks the generated html, failing the build on any problem with
+	// --strict rather than printing a warning
+	validateHtml = flag.Bool("validate", false, "")
+	strictHtml   = flag.Bool("strict", false, "")
+
+	// number of files generated at once, the number of CPUs by default
+	jobs = flag.Int("jobs", 0, "")
+
+	// writes the dependencies of every page to the file, or - for stdout
+	trace = flag.String("trace", "", "")
+
+	// removes the files left over from the previous build, rather than
+	// clearing the destination directory
+	prune = flag.Bool("prune", false, "")
+
+	// stops at the first file that fails to build, rather than reporting
+	// every one of them
+	failFast = flag.Bool("fail-fast", false, "")
+
 	// serves the website from the specified base url
 	baseurl = flag.String("base-url", "", "")
 
@@ -46,6 +108,8 @@ func main() {
 	// Parse the input parameters
 	flag.BoolVar(help, "h", false, "")
 	flag.BoolVar(verbose, "v", false, "")
+	flag.StringVar(source, "src", "", "")
+	flag.StringVar(destination, "dest", "", "")
 	flag.Usage = usage
 	flag.Parse()
 
@@ -54,69 +118,180 @@ func main() {
 		os.Exit(0)
 	}
 
-	// User may specify the source as a non-flag variable
-	if flag.NArg() > 0 {
-		source = &flag.Args()[0]
+	// Scaffold a new site, rather than generating one
+	if flag.Arg(0) == "new" {
+		if err := runNew(flag.Args()[1:]); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		os.Exit(0)
 	}
 
-	// Convert the directory to an absolute path
-	src, _ := filepath.Abs(*source)
-	dest, _ := filepath.Abs(*destination)
+	// Render a single page or post, rather than generating the site. The
+	// file is relative to the working directory
+	var renderFile string
+	deploySite := flag.Arg(0) == "deploy"
+	if flag.Arg(0) == "render" {
+		if flag.NArg() != 2 {
+			fmt.Println("Usage: jkl render FILE")
+			os.Exit(1)
+		}
+		renderFile, _ = filepath.Abs(flag.Arg(1))
+	} else if deploySite {
+		// Publish the site once generated, with the source as an optional
+		// argument
+		if flag.NArg() > 2 {
+			fmt.Println("Usage: jkl [OPTION]... deploy [SOURCE]")
+			os.Exit(1)
+		}
+		if flag.NArg() == 2 {
+			source = &flag.Args()[1]
+		}
+	} else if flag.NArg() > 0 {
+		// User may specify the source as a non-flag variable
+		source = &flag.Args()[0]
+	}
 
-	// Change the working directory to the website's source directory
-	os.Chdir(src)
+	// Set any site variables that were overriden / provided in the cli args
+	// and must be known before the site is read
+	flags := Config{}
+	if *drafts || *preview {
+		flags.Set("show_drafts", true)
+	}
+	if *future || *preview {
+		flags.Set("future", true)
+	}
+	if *unpublished || *preview {
+		flags.Set("unpublished", true)
+	}
+	if *preview {
+		flags.Set("preview", true)
+	}
+	if *strictFrontMatter {
+		flags.Set("strict_front_matter", true)
+	}
+	if *limitPosts > 0 {
+		flags.Set("limit_posts", *limitPosts)
+	}
+	if *only != "" {
+		flags.Set("only", *only)
+	}
+	if *prune {
+		flags.Set("prune", true)
+	}
+	if *jobs > 0 {
+		flags.Set("jobs", *jobs)
+	}
+	if *strictHtml {
+		flags.Set("validate_html", "strict")
+	} else if *validateHtml {
+		flags.Set("validate_html", true)
+	}
+	if *validateFrontMatter {
+		flags.Set("validate_front_matter", true)
+	}
+	if *failFast {
+		flags.Set("fail_fast", true)
+	}
 
-	// Initialize the Jekyll website
-	site, err := NewSite(src, dest)
+	// Initialize the Jekyll website. The source and destination provided
+	// in the cli args, if any, take precedence over the _config.yml
+	site, err := NewSite(*source, *destination, flags)
 	if err != nil {
 		fmt.Println(err)
 		os.Exit(1)
 	}
 
+	// Change the working directory to the website's source directory
+	os.Chdir(site.Src)
+
 	// Set any site variables that were overriden / provided in the cli args
 	if *baseurl != "" || site.Conf.Get("baseurl") == nil {
 		site.Conf.Set("baseurl", *baseurl)
 	}
 
-	// Generate the static website
-	if err := site.Generate(); err != nil {
+	// Print the rendered page or post, without generating the site
+	if renderFile != "" {
+		b, err := site.RenderFile(renderFile)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		os.Stdout.Write(b)
+		os.Exit(0)
+	}
+
+	// The port of the server defaults to 4443 for https
+	if *useTLS && !isFlagSet("server_port") {
+		*port = ":4443"
+	}
+
+	// When serving the website, absolute urls point to the local server
+	// rather than the production site, unless requested otherwise
+	if *server && !*productionUrl {
+		site.Conf.Set("url", devUrl(*port, *useTLS))
+	}
+
+	// Serve the website as it was last built, such as a CI artifact,
+	// without generating it
+	if *noBuild {
+		if !*server || *auto {
+			fmt.Println("--no-build requires --server, and can't be used with --auto")
+			os.Exit(1)
+		}
+		if err := checkBuilt(site.Dest); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	} else if err := site.Generate(); err != nil {
 		fmt.Println(err)
 		os.Exit(1)
 	}
 
+	// Write the dependencies of the pages, for debugging the build
+	if *trace != "" {
+		if err := writeTraceFile(site, *trace); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	}
+
+	// Publish the generated site with the deployer in the _config.yml
+	if deploySite {
+		if err := site.Deploy(); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	}
+
 	// If the auto option is enabled, use fsnotify to watch
 	// and re-generate the site if files change.
 	if *auto {
+		// Reload the browser when the site is re-generated
+		if *server {
+			reloader = newLiveReloader()
+		}
 		fmt.Printf("Listening for changes to %s\n", site.Src)
 		go watch(site)
 	}
 
 	// If the server option is enabled, launch a webserver
 	if *server {
+		// Open the website in the browser once the server is up
+		if *openUrl {
+			base := strings.Trim(site.Conf.GetString("baseurl"), "/")
+			go openBrowser(*port, strings.TrimSuffix(devUrl(*port, *useTLS)+"/"+base, "/")+"/")
+		}
 
-		// Change the working directory to the _site directory
-		//os.Chdir(dest)
-
-		// Create the handler to serve from the filesystem
-		http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-			mu.RLock()
-			defer mu.RUnlock()
-
-			base := site.Conf.GetString("baseurl")
-			path := r.URL.Path
-			pathList := filepath.SplitList(path)
-			if len(pathList) > 0 && pathList[0] == base {
-				path = strings.Join(pathList[len(pathList):], "/")
-			}
-
-			path = filepath.Clean(path)
-			path = filepath.Join(dest, path)
-			http.ServeFile(w, r, path)
-		})
-
-		// Serve the website from the _site directory
-		fmt.Printf("Starting server on port %s\n", *port)
-		if err := http.ListenAndServe(*port, nil); err != nil {
+		// Serve the website from the _site directory, over https if
+		// requested
+		var err error
+		if *useTLS {
+			err = ServeTLS(site, *port, *tlsCert, *tlsKey)
+		} else {
+			err = Serve(site, *port)
+		}
+		if err != nil {
 			fmt.Println(err)
 			os.Exit(1)
 		}
@@ -125,73 +300,106 @@ func main() {
 	os.Exit(0)
 }
 
-func watch(site *Site) {
-
-	// Setup the inotify watcher
-	watcher, err := fsnotify.NewWatcher()
-	if err != nil {
-		fmt.Println(err)
-		return
-	}
-
-	// Get recursive list of directories to watch
-	for _, path := range dirs(site.Src) {
-		if err := watcher.Watch(path); err != nil {
-			fmt.Println(err)
-			return
+// Returns True if the flag was explicitly provided in the cli args.
+func isFlagSet(name string) (set bool) {
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
 		}
-	}
-
-	for {
-		select {
-		case ev := <-watcher.Event:
-			// Ignore changes to the _site directoy, hidden, or temp files
-			if !strings.HasPrefix(ev.Name, site.Dest) && !isHiddenOrTemp(ev.Name) {
-				fmt.Println("Event: ", ev.String())
-				recompile(site)
-			}
-		case err := <-watcher.Error:
-			fmt.Println("inotify error:", err)
-		}
-	}
+	})
+	return
 }
 
-func recompile(site *Site) {
-	mu.Lock()
-	defer mu.Unlock()
-
-	if err := site.Reload(); err != nil {
-		fmt.Println(err)
-		return
+// Helper function that writes the dependencies of the site's pages to the
+// file, or to stdout if the file is -.
+func writeTraceFile(site *Site, fn string) error {
+	if fn == "-" {
+		return site.writeTrace(os.Stdout)
 	}
-
-	if err := site.Generate(); err != nil {
-		fmt.Println(err)
-		return
+	f, err := os.Create(fn)
+	if err != nil {
+		return err
+	}
+	if err := site.writeTrace(f); err != nil {
+		f.Close()
+		return err
 	}
+	return f.Close()
 }
 
+// Logger used for verbose output. Each message is written as a single line,
+// even when logged from several goroutines at once.
+var logger = log.New(os.Stderr, "", 0)
+
+// Logs one of the Msg format strings, if running with verbose output.
 func logf(msg string, args ...interface{}) {
 	if *verbose {
-		println(fmt.Sprintf(msg, args...))
+		logger.Printf(msg, args...)
 	}
 }
 
 var usage = func() {
-	fmt.Println(`Usage: jkl [OPTION]... [SOURCE]
+	fmt.Print(`Usage: jkl [OPTION]... [SOURCE]
+       jkl new site [--force] DIR
+       jkl [OPTION]... render FILE
+       jkl [OPTION]... deploy [SOURCE]
 
       --auto           re-generates the site when files are modified
       --base-url       serve website from a given base URL
-      --source         changes the dir where Jekyll will look to transform files
-      --destination    changes the dir where Jekyll will write files to
+      --source, --src  changes the dir where Jekyll will look to transform files
+      --destination, --dest
+                       changes the dir where Jekyll will write files to
+      --drafts         renders posts in the _drafts folder
+      --future         publishes posts with a future date
+      --unpublished    renders posts and pages marked as unpublished
+      --preview        enables --drafts, --future and --unpublished
+      --limit_posts N  only generates the N most recent posts, for faster
+                       builds while working on the layouts
+      --only DIR       only generates the pages and files below DIR, e.g.
+                       docs/, leaving the rest of the site untouched
+      --prune          removes the files left over from the previous build,
+                       except keep_files, rather than clearing the site
+      --jobs N         generates up to N files at once, the number of CPUs
+                       by default
+      --validate       checks the generated html for unclosed tags, duplicate
+                       ids and images without alt text
+      --strict         enables --validate, failing on any problem found
+      --trace FILE     writes the layout, includes, data files and output of
+                       every page to FILE as JSON, or to stdout with -
+      --strict-front-matter
+                       fails if any page or post can't be parsed
+      --validate-front-matter
+                       fails if any page or post doesn't match the
+                       front_matter_schema in _config.yml
+      --fail-fast      stops at the first file that fails to build
       --server         starts a server that will host your _site directory
       --server-port    changes the port that the Jekyll server will run on
+      --production-url keeps the site url from _config.yml when serving
+      --open           opens the served website in the default browser
+      --no-build       serves the site as last built, without generating it
+      --tls            serves the website over https (port 4443 by default)
+      --tls-cert       certificate file for https, self-signed if omitted
+      --tls-key        private key file for https, self-signed if omitted
   -v, --verbose        runs Jekyll with verbose output
   -h, --help           display this help and exit
 
+Commands:
+  new site [--force] DIR
+                       scaffolds a new site in DIR, which must be empty
+                       unless --force is given
+  render FILE          prints FILE, a page or post, rendered through its
+                       layout without generating the site
+  deploy [SOURCE]      generates the site and publishes it with the deploy
+                       provider in _config.yml, by default force-pushing it
+                       to the gh-pages branch of deploy.remote
+
 Examples:
   jkl                 generates site from current working directory
   jkl --server        generates site and serves at localhost:4000
   jkl /path/to/site   generates site from source dir /path/to/site
+  jkl new site blog   scaffolds a new site in the blog directory
+  jkl render _posts/2013-02-14-my-post.md
+                      prints the rendered post
+
 `)
 }
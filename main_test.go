@@ -0,0 +1,43 @@
+package main
+
+import (
+	"bytes"
+	"log"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestLogfConcurrent(t *testing.T) {
+	var buf bytes.Buffer
+	stderr := logger
+	logger = log.New(&buf, "", 0)
+	*verbose = true
+	defer func() {
+		logger = stderr
+		*verbose = false
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				logf(MsgGenerateFile, strings.Repeat("x", 100))
+			}
+		}()
+	}
+	wg.Wait()
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != 1000 {
+		t.Fatalf("Expected 1000 log lines got %d", len(lines))
+	}
+	expected := "Generating Page: " + strings.Repeat("x", 100)
+	for _, line := range lines {
+		if line != expected {
+			t.Fatalf("Expected log line [%s] got [%s]", expected, line)
+		}
+	}
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"fmt"
+	"github.com/russross/blackfriday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer/html"
+	"regexp"
+	"strings"
+)
+
+// Name of the Markdown engine used unless another is selected with
+// markdown.engine in the _config.yml.
+const defaultMarkdownEngine = "blackfriday"
+
+// A MarkdownEngine renders Markdown to HTML.
+type MarkdownEngine interface {
+	Render([]byte) []byte
+}
+
+// Markdown engines by name. Each is created from the _config.yml, so that it
+// may read its own options from the markdown section.
+var markdownEngines = map[string]func(Config) MarkdownEngine{
+	"blackfriday": newBlackfridayEngine,
+	"commonmark":  newCommonMarkEngine,
+}
+
+// RegisterMarkdownEngine adds a Markdown engine that may be selected by name
+// with markdown.engine in the _config.yml, replacing any engine already
+// registered with the name.
+func RegisterMarkdownEngine(name string, fn func(Config) MarkdownEngine) {
+	markdownEngines[name] = fn
+}
+
+// Helper function that adds the Markdown engine selected in the _config.yml
+// to the table as the converter of .md and .markdown files, for example:
+//
+//	markdown:
+//	  engine: commonmark
+//
+// With math enabled, math is kept from the engine, see mathConverter.
+// Returns an error if no engine is registered with the name.
+func registerMarkdown(conf Config, table map[string]Converter) error {
+	name := conf.GetString("markdown.engine")
+	if name == "" {
+		name = defaultMarkdownEngine
+	}
+	fn, ok := markdownEngines[name]
+	if !ok {
+		return fmt.Errorf("Unknown markdown engine: %s", name)
+	}
+
+	engine := fn(conf)
+	var convert Converter = func(markup []byte) ([]byte, error) {
+		return engine.Render(markup), nil
+	}
+	if conf.GetBool("math", false) {
+		convert = mathConverter(convert)
+	}
+	addConverter(table, ".md", convert)
+	addConverter(table, ".markdown", convert)
+	return nil
+}
+
+// Converts a string of Markdown, such as a field of the front-end matter, to
+// html with the engine of the site, e.g. {{markdownify .page.blurb}}. Rendered
+// as inline, a single paragraph is not wrapped in <p> tags. The mode, inline
+// or block, overrides markdown.markdownify in the _config.yml.
+func (s *Site) markdownify(markup string, mode ...string) (string, error) {
+	out, err := convert(s.converters, ".md", []byte(markup))
+	if err != nil {
+		return "", fmt.Errorf("markdownify: %s", err)
+	}
+	content := strings.TrimSpace(string(out))
+
+	inline := s.markdownInline
+	if len(mode) > 0 {
+		switch mode[0] {
+		case "inline":
+			inline = true
+		case "block":
+			inline = false
+		default:
+			return "", fmt.Errorf("markdownify: unknown mode %s. Expecting inline or block", mode[0])
+		}
+	}
+	if inline && strings.HasPrefix(content, "<p>") && strings.HasSuffix(content, "</p>") && strings.Count(content, "<p>") == 1 {
+		content = content[len("<p>") : len(content)-len("</p>")]
+	}
+	return content, nil
+}
+
+// Html flags and extensions of the blackfriday engine, the same as those of
+// blackfriday.MarkdownCommon. Definition lists may be disabled with
+// markdown.definition_lists: false in the _config.yml.
+const (
+	blackfridayHtmlFlags = blackfriday.HTML_USE_XHTML |
+		blackfriday.HTML_USE_SMARTYPANTS |
+		blackfriday.HTML_SMARTYPANTS_FRACTIONS |
+		blackfriday.HTML_SMARTYPANTS_DASHES |
+		blackfriday.HTML_SMARTYPANTS_LATEX_DASHES
+
+	blackfridayExtensions = blackfriday.EXTENSION_NO_INTRA_EMPHASIS |
+		blackfriday.EXTENSION_TABLES |
+		blackfriday.EXTENSION_FENCED_CODE |
+		blackfriday.EXTENSION_AUTOLINK |
+		blackfriday.EXTENSION_STRIKETHROUGH |
+		blackfriday.EXTENSION_SPACE_HEADERS |
+		blackfriday.EXTENSION_HEADER_IDS |
+		blackfriday.EXTENSION_BACKSLASH_LINE_BREAK |
+		blackfriday.EXTENSION_DEFINITION_LISTS
+)
+
+// Markdown engine backed by blackfriday, with its common extensions, and
+// footnotes and heading ids if enabled with markdown.footnotes and
+// markdown.auto_heading_ids in the _config.yml. Definition lists are
+// enabled unless markdown.definition_lists is false.
+type blackfridayEngine struct {
+	flags      int
+	extensions int
+	footnotes  bool
+}
+
+func newBlackfridayEngine(conf Config) MarkdownEngine {
+	e := blackfridayEngine{
+		flags:      blackfridayHtmlFlags,
+		extensions: blackfridayExtensions,
+		footnotes:  conf.GetBool("markdown.footnotes", false),
+	}
+	if e.footnotes {
+		e.flags |= blackfriday.HTML_FOOTNOTE_RETURN_LINKS
+		e.extensions |= blackfriday.EXTENSION_FOOTNOTES
+	}
+	if !conf.GetBool("markdown.definition_lists", true) {
+		e.extensions &^= blackfriday.EXTENSION_DEFINITION_LISTS
+	}
+	if conf.GetBool("markdown.auto_heading_ids", false) {
+		e.extensions |= blackfriday.EXTENSION_AUTO_HEADER_IDS
+	}
+	return e
+}
+
+func (e blackfridayEngine) Render(markup []byte) []byte {
+	params := blackfriday.HtmlRendererParameters{}
+	if e.footnotes {
+		params.FootnoteAnchorPrefix = footnotePrefix(markup)
+	}
+	renderer := blackfriday.HtmlRendererWithParameters(e.flags, "", "", params)
+	return blackfriday.MarkdownOptions(markup, renderer, blackfriday.Options{
+		Extensions: e.extensions})
+}
+
+// CommonMark compliant Markdown engine backed by goldmark, with footnotes,
+// definition lists and heading ids if enabled the same as for blackfriday.
+// Raw HTML is passed through, as it is by blackfriday.
+type commonMarkEngine struct {
+	footnotes       bool
+	definitionLists bool
+	headingIds      bool
+}
+
+func newCommonMarkEngine(conf Config) MarkdownEngine {
+	return commonMarkEngine{
+		footnotes:       conf.GetBool("markdown.footnotes", false),
+		definitionLists: conf.GetBool("markdown.definition_lists", true),
+		headingIds:      conf.GetBool("markdown.auto_heading_ids", false),
+	}
+}
+
+func (e commonMarkEngine) Render(markup []byte) []byte {
+	opts := []goldmark.Option{goldmark.WithRendererOptions(html.WithUnsafe())}
+	if e.definitionLists {
+		opts = append(opts, goldmark.WithExtensions(extension.DefinitionList))
+	}
+	if e.headingIds {
+		opts = append(opts, goldmark.WithParserOptions(parser.WithAutoHeadingID()))
+	}
+	if e.footnotes {
+		footnote := extension.NewFootnote(extension.WithFootnoteIDPrefix(footnotePrefix(markup)))
+		opts = append(opts, goldmark.WithExtensions(footnote))
+	}
+
+	var buf bytes.Buffer
+	if err := goldmark.New(opts...).Convert(markup, &buf); err != nil {
+		// goldmark only fails to write to the buffer
+		return markup
+	}
+	return buf.Bytes()
+}
+
+// Helper function that returns the prefix of the footnote ids of a page,
+// derived from its markup. Footnotes are numbered from 1 on every page, so
+// without a prefix the ids would collide when several posts are shown on the
+// same page, such as the home page.
+func footnotePrefix(markup []byte) string {
+	return fmt.Sprintf("%x", sha1.Sum(markup))[:8] + "-"
+}
+
+// Matches the id of a heading, e.g. <h2 id="setup">
+var headingId = regexp.MustCompile(`(<h[1-6][^>]*\sid=")([^"]+)(")`)
+
+// Matches a link to an anchor on the same page, e.g. <a href="#setup">
+var anchorLink = regexp.MustCompile(`(href="#)([^"]+)(")`)
+
+// Helper function that prefixes the ids of the headings in the html, along
+// with the links to them on the page, such as those of a table of contents,
+// so that the ids are unique across pages shown together. Any :slug in the
+// prefix is replaced with the slug of the page, e.g. :slug- for about-setup
+func prefixHeadingIds(content, prefix, slug string) string {
+	prefix = strings.Replace(prefix, ":slug", slug, -1)
+
+	ids := map[string]bool{}
+	content = headingId.ReplaceAllStringFunc(content, func(m string) string {
+		sub := headingId.FindStringSubmatch(m)
+		ids[sub[2]] = true
+		return sub[1] + prefix + sub[2] + sub[3]
+	})
+	return anchorLink.ReplaceAllStringFunc(content, func(m string) string {
+		sub := anchorLink.FindStringSubmatch(m)
+		if !ids[sub[2]] {
+			return m
+		}
+		return sub[1] + prefix + sub[2] + sub[3]
+	})
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestRegisterMarkdown(t *testing.T) {
+	tests := map[string]string{
+		"":            "<p>&ldquo;quoted&rdquo;</p>\n",
+		"blackfriday": "<p>&ldquo;quoted&rdquo;</p>\n",
+		"commonmark":  "<p>&quot;quoted&quot;</p>\n",
+	}
+	for engine, expected := range tests {
+		conf := Config{}
+		if engine != "" {
+			conf.Set("markdown.engine", engine)
+		}
+		table := map[string]Converter{}
+		if err := registerMarkdown(conf, table); err != nil {
+			t.Fatal(err)
+		}
+		for _, fn := range []string{"post.md", "post.markdown"} {
+			out, err := convert(table, fn, []byte(`"quoted"`))
+			if err != nil || string(out) != expected {
+				t.Errorf("Expected engine [%s] to render [%s] as [%s] got [%s] %v", engine, fn, expected, out, err)
+			}
+		}
+	}
+
+	conf := Config{}
+	conf.Set("markdown.engine", "nope")
+	if err := registerMarkdown(conf, map[string]Converter{}); err == nil || !strings.Contains(err.Error(), "nope") {
+		t.Errorf("Expected error for an unknown markdown engine, got %v", err)
+	}
+}
+
+func TestCommonMarkRawHtml(t *testing.T) {
+	out := newCommonMarkEngine(Config{}).Render([]byte("<div class=\"note\">hi</div>\n\n*text*"))
+	expected := "<div class=\"note\">hi</div>\n<p><em>text</em></p>\n"
+	if string(out) != expected {
+		t.Errorf("Expected raw html to pass through as [%s] got [%s]", expected, out)
+	}
+}
+
+func TestMarkdownFootnotes(t *testing.T) {
+	first := []byte("First[^1].\n\n[^1]: A note.\n")
+	second := []byte("Second[^1].\n\n[^1]: Another note.\n")
+
+	prefix := footnotePrefix(first)
+	refs := map[string][]string{
+		"blackfriday": {`id="fnref:` + prefix + `1"`, `href="#fn:` + prefix + `1"`, `href="#fnref:` + prefix + `1"`},
+		"commonmark":  {`id="` + prefix + `fnref:1"`, `href="#` + prefix + `fn:1"`, `href="#` + prefix + `fnref:1"`},
+	}
+	for engine, expected := range refs {
+		conf := Config{}
+		conf.Set("markdown.engine", engine)
+		table := map[string]Converter{}
+		if err := registerMarkdown(conf, table); err != nil {
+			t.Fatal(err)
+		}
+		out, _ := convert(table, "post.md", first)
+		if strings.Contains(string(out), "<sup") {
+			t.Errorf("Expected footnotes disabled by default for [%s], got [%s]", engine, out)
+		}
+
+		conf.Set("markdown.footnotes", true)
+		table = map[string]Converter{}
+		if err := registerMarkdown(conf, table); err != nil {
+			t.Fatal(err)
+		}
+		a, _ := convert(table, "first.md", first)
+		b, _ := convert(table, "second.md", second)
+		for _, s := range expected {
+			if !strings.Contains(string(a), s) {
+				t.Errorf("Expected [%s] in footnotes of [%s], got [%s]", s, engine, a)
+			}
+		}
+
+		// posts listed on the same page don't share footnote ids
+		for _, id := range ids(a) {
+			for _, other := range ids(b) {
+				if id == other {
+					t.Errorf("Expected unique footnote ids for [%s], got [%s] twice", engine, id)
+				}
+			}
+		}
+	}
+}
+
+// Helper function that returns the id attributes in the html.
+func ids(html []byte) (ids []string) {
+	for _, m := range regexp.MustCompile(`id="([^"]*)"`).FindAllSubmatch(html, -1) {
+		ids = append(ids, string(m[1]))
+	}
+	return
+}
+
+func TestMarkdownDefinitionLists(t *testing.T) {
+	markup := []byte("Term\n: Definition\n")
+	for _, engine := range []string{"blackfriday", "commonmark"} {
+		conf := Config{}
+		conf.Set("markdown.engine", engine)
+		table := map[string]Converter{}
+		if err := registerMarkdown(conf, table); err != nil {
+			t.Fatal(err)
+		}
+		out, _ := convert(table, "doc.md", markup)
+		for _, tag := range []string{"<dl>", "<dt>Term</dt>", "<dd>Definition</dd>"} {
+			if !strings.Contains(string(out), tag) {
+				t.Errorf("Expected [%s] in definition list of [%s] by default, got [%s]", tag, engine, out)
+			}
+		}
+
+		conf.Set("markdown.definition_lists", false)
+		table = map[string]Converter{}
+		if err := registerMarkdown(conf, table); err != nil {
+			t.Fatal(err)
+		}
+		if out, _ := convert(table, "doc.md", markup); strings.Contains(string(out), "<dl>") {
+			t.Errorf("Expected definition lists disabled for [%s], got [%s]", engine, out)
+		}
+	}
+}
+
+func TestAutoHeadingIds(t *testing.T) {
+	for _, engine := range []string{"blackfriday", "commonmark"} {
+		conf := Config{}
+		conf.Set("markdown.engine", engine)
+		conf.Set("markdown.auto_heading_ids", true)
+		out := string(markdownEngines[engine](conf).Render([]byte("## Getting Started\n")))
+		if !strings.Contains(out, `id="getting-started"`) {
+			t.Errorf("Expected engine [%s] to add an id to headings, got [%s]", engine, out)
+		}
+
+		conf.Set("markdown.auto_heading_ids", false)
+		out = string(markdownEngines[engine](conf).Render([]byte("## Getting Started\n")))
+		if strings.Contains(out, "id=") {
+			t.Errorf("Expected engine [%s] to leave headings without ids by default, got [%s]", engine, out)
+		}
+	}
+}
+
+func TestPrefixHeadingIds(t *testing.T) {
+	html := `<ul><li><a href="#setup">Setup</a></li><li><a href="#fn:1">1</a></li></ul>` +
+		`<h2 id="setup">Setup</h2><h3 class="x" id="usage">Usage</h3><p id="setup">not a heading</p>`
+	expected := `<ul><li><a href="#guide-setup">Setup</a></li><li><a href="#fn:1">1</a></li></ul>` +
+		`<h2 id="guide-setup">Setup</h2><h3 class="x" id="guide-usage">Usage</h3><p id="setup">not a heading</p>`
+	if out := prefixHeadingIds(html, ":slug-", "guide"); out != expected {
+		t.Errorf("Expected heading ids prefixed [%s] got [%s]", expected, out)
+	}
+}
+
+func TestMarkdownify(t *testing.T) {
+	tests := []struct {
+		inline   bool
+		markup   string
+		mode     []string
+		expected string
+	}{
+		{false, "A *blurb*", nil, "<p>A <em>blurb</em></p>"},
+		{false, "A *blurb*", []string{"inline"}, "A <em>blurb</em>"},
+		{false, "One\n\nTwo", []string{"inline"}, "<p>One</p>\n\n<p>Two</p>"},
+		{true, "A *blurb*", nil, "A <em>blurb</em>"},
+		{true, "A *blurb*", []string{"block"}, "<p>A <em>blurb</em></p>"},
+	}
+	for _, test := range tests {
+		site := Site{converters: converters, markdownInline: test.inline}
+		out, err := site.markdownify(test.markup, test.mode...)
+		if err != nil || out != test.expected {
+			t.Errorf("Expected markdownify of [%s] %v to be [%s] got [%s] %v", test.markup, test.mode, test.expected, out, err)
+		}
+	}
+
+	site := Site{converters: converters}
+	if _, err := site.markdownify("text", "nope"); err == nil || !strings.Contains(err.Error(), "nope") {
+		t.Errorf("Expected error for an unknown markdownify mode, got %v", err)
+	}
+}
+
+func TestSiteMarkdownify(t *testing.T) {
+	page := "---\ntitle: \"*Quoted*\"\n---\n{{markdownify .page.title}}"
+	a, cleanup := newTestSite(t, map[string]string{
+		"_config.yml":           "markdown:\n  engine: commonmark\n  markdownify: inline\n",
+		"_layouts/default.html": "{{.content}}",
+		"index.html":            page})
+	defer cleanup()
+	b, cleanupB := newTestSite(t, map[string]string{
+		"_config.yml":           "",
+		"_layouts/default.html": "{{.content}}",
+		"index.html":            page})
+	defer cleanupB()
+
+	// the engine and mode of one site don't leak into the other
+	tests := []struct {
+		site     *Site
+		expected string
+	}{
+		{a, "<em>Quoted</em>"},
+		{b, "<p><em>Quoted</em></p>"},
+		{a, "<em>Quoted</em>"},
+	}
+	for _, test := range tests {
+		if err := test.site.Generate(); err != nil {
+			t.Fatal(err)
+		}
+		out, _ := ioutil.ReadFile(filepath.Join(test.site.Dest, "index.html"))
+		if string(out) != test.expected {
+			t.Errorf("Expected markdownify to render [%s] got [%s]", test.expected, out)
+		}
+	}
+
+	site := Site{converters: a.converters}
+	if out, _ := site.markdownify(`"quoted"`); out != "<p>&quot;quoted&quot;</p>" {
+		t.Errorf("Expected markdownify to use the engine of the site, got [%s]", out)
+	}
+	site = Site{converters: b.converters}
+	if out, _ := site.markdownify(`"quoted"`); out != "<p>&ldquo;quoted&rdquo;</p>" {
+		t.Errorf("Expected markdownify to use the engine of the site, got [%s]", out)
+	}
+}
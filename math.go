@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"regexp"
+)
+
+// Matches display math, $$...$$ or \[...\], and inline math, \(...\), along
+// with code spans, which are left alone.
+var mathSpan = regexp.MustCompile("(?s)`[^`]*`|\\$\\$.+?\\$\\$|\\\\\\[.+?\\\\\\]|\\\\\\(.+?\\\\\\)")
+
+// Helper function that returns a converter that keeps the math in the markup
+// from being mangled by Markdown, for MathJax or KaTeX to render in the
+// browser. Math spans are replaced by placeholders before the markup is
+// converted, and restored in the html afterwards, delimiters included. Math
+// in code blocks and code spans is left as is.
+func mathConverter(fn Converter) Converter {
+	return func(markup []byte) ([]byte, error) {
+		masked, spans := maskMath(markup)
+		out, err := fn(masked)
+		if err != nil || len(spans) == 0 {
+			return out, err
+		}
+		for i, span := range spans {
+			out = bytes.Replace(out, mathPlaceholder(i), []byte(html.EscapeString(string(span))), 1)
+		}
+		return out, nil
+	}
+}
+
+// Helper function that returns the placeholder of the nth math span, made of
+// letters and digits only so that Markdown leaves it as is.
+func mathPlaceholder(n int) []byte {
+	return []byte(fmt.Sprintf("JKLMATH%dX", n))
+}
+
+// Helper function that replaces the math spans outside of code blocks with
+// placeholders, returning the masked markup and the spans in order.
+func maskMath(markup []byte) ([]byte, [][]byte) {
+	var out bytes.Buffer
+	var text []byte
+	spans := [][]byte{}
+
+	mask := func() {
+		out.Write(mathSpan.ReplaceAllFunc(text, func(m []byte) []byte {
+			if m[0] == '`' {
+				return m
+			}
+			spans = append(spans, m)
+			return mathPlaceholder(len(spans) - 1)
+		}))
+		text = nil
+	}
+
+	fenced := false
+	for _, line := range bytes.SplitAfter(markup, []byte("\n")) {
+		trimmed := bytes.TrimSpace(line)
+		indented := bytes.HasPrefix(line, []byte("    ")) || bytes.HasPrefix(line, []byte("\t"))
+		switch {
+		case bytes.HasPrefix(trimmed, []byte("```")) || bytes.HasPrefix(trimmed, []byte("~~~")):
+			mask()
+			fenced = !fenced
+			out.Write(line)
+		case fenced || indented:
+			mask()
+			out.Write(line)
+		default:
+			text = append(text, line...)
+		}
+	}
+	mask()
+	return out.Bytes(), spans
+}
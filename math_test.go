@@ -0,0 +1,37 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestMathConverter(t *testing.T) {
+	table := map[string]Converter{}
+	if err := registerMarkdown(Config{"math": true}, table); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]string{
+		`Area \(a_i * b_i\) of *it*`:      "<p>Area \\(a_i * b_i\\) of <em>it</em></p>\n",
+		"$$\n\\sum_{i=1}^n x_i < y\n$$\n": "<p>$$\n\\sum_{i=1}^n x_i &lt; y\n$$</p>\n",
+		`\[a_1 \\ b_2\]`:                  "<p>\\[a_1 \\\\ b_2\\]</p>\n",
+		"Code `\\(a_i\\)` stays":          "<p>Code <code>\\(a_i\\)</code> stays</p>\n",
+		"```\n$$a_1 * b_2$$\n```\n":       "<pre><code>$$a_1 * b_2$$\n</code></pre>\n",
+		"Costs $5 and $10 *each*":         "<p>Costs $5 and $10 <em>each</em></p>\n",
+	}
+	for markup, expected := range tests {
+		out, err := convert(table, "post.md", []byte(markup))
+		if err != nil || string(out) != expected {
+			t.Errorf("Expected [%q] to render as [%q] got [%q] %v", markup, expected, out, err)
+		}
+	}
+}
+
+func TestMaskMath(t *testing.T) {
+	masked, spans := maskMath([]byte("a $$x$$ b\n\n    $$code$$\n\nc \\(y\\)\n"))
+	if string(masked) != "a JKLMATH0X b\n\n    $$code$$\n\nc JKLMATH1X\n" {
+		t.Errorf("Expected math outside of code to be masked, got [%q]", masked)
+	}
+	if len(spans) != 2 || string(spans[0]) != "$$x$$" || string(spans[1]) != `\(y\)` {
+		t.Errorf("Expected the math spans in order, got [%q]", spans)
+	}
+}
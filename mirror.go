@@ -0,0 +1,66 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+var MsgMirrorDir = "Mirroring Site: %s"
+
+// Helper function that resolves the mirrors in the _config.yml, additional
+// directories the generated site is written to, as absolute paths relative
+// to the base directory that contains the _config.yml.
+func resolveMirrors(base string, conf Config) ([]string, error) {
+	mirrors := []string{}
+	for _, dir := range conf.GetStringSlice("mirrors") {
+		dir, err := filepath.Abs(joinPath(base, dir))
+		if err != nil {
+			return nil, err
+		}
+		mirrors = append(mirrors, dir)
+	}
+	return mirrors, nil
+}
+
+// Helper function that returns the destination directory followed by any
+// mirrors.
+func (s *Site) dests() []string {
+	return append([]string{s.Dest}, s.Mirrors...)
+}
+
+// Returns True if the file is in the destination directory or one of the
+// mirrors, and so must not be read as part of the source.
+func (s *Site) isDestPath(fn string) bool {
+	for _, dir := range s.dests() {
+		if fn == dir || strings.HasPrefix(fn, dir+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Helper function that copies the generated site from the destination
+// directory to each mirror, see outputFiles. Pages are only rendered once,
+// each file is read once and written to every mirror.
+func (s *Site) writeMirrors() error {
+	if len(s.Mirrors) == 0 {
+		return nil
+	}
+	for _, dir := range s.Mirrors {
+		logf(MsgMirrorDir, dir)
+	}
+
+	files, err := s.outputFiles()
+	if err != nil {
+		return err
+	}
+	for _, fn := range files {
+		rel, _ := filepath.Rel(s.Dest, fn)
+		for _, dir := range s.Mirrors {
+			if err := s.copyOutput(fn, filepath.Join(dir, rel)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
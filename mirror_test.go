@@ -0,0 +1,65 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriteMirrors(t *testing.T) {
+	staging, err := ioutil.TempDir("", "jkl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(staging)
+
+	site, cleanup := newTestSite(t, map[string]string{
+		"_config.yml":           "mirrors: [preview, " + staging + "]\n",
+		"_layouts/default.html": "<html>{{.content}}</html>",
+		"index.html":            "---\n---\nhome",
+		"css/style.css":         "body {}"})
+	defer cleanup()
+
+	preview := filepath.Join(site.Src, "preview")
+	expected := []string{preview, staging}
+	if len(site.Mirrors) != 2 || site.Mirrors[0] != expected[0] || site.Mirrors[1] != expected[1] {
+		t.Fatalf("Expected mirrors %v got %v", expected, site.Mirrors)
+	}
+
+	// stale files in a mirror are removed, the same as in the destination
+	os.MkdirAll(preview, 0755)
+	ioutil.WriteFile(filepath.Join(preview, "stale.html"), []byte("stale"), 0644)
+
+	if err := site.Generate(); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"index.html":    "<html>home</html>",
+		"css/style.css": "body {}"}
+	for _, dir := range site.dests() {
+		for file, content := range files {
+			b, err := ioutil.ReadFile(filepath.Join(dir, file))
+			if err != nil || string(b) != content {
+				t.Errorf("Expected [%s] in [%s] as [%s] got [%s] %v", file, dir, content, b, err)
+			}
+		}
+	}
+	if _, err := os.Stat(filepath.Join(preview, "stale.html")); !os.IsNotExist(err) {
+		t.Errorf("Expected mirror to be cleared before it is written")
+	}
+
+	// a mirror inside the source is not read back into the site
+	if err := site.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range site.files {
+		if strings.HasPrefix(file, "preview") {
+			t.Errorf("Expected mirror not to be read as a static file, got [%s]", file)
+		}
+	}
+	if !site.isWatchIgnored(filepath.Join(preview, "index.html")) {
+		t.Errorf("Expected changes to the mirror to be ignored when watching")
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+)
+
+var MsgOpmlFile = "Generating Blogroll: %s"
+
+// Default path of the OPML blogroll, and the data file it is made of.
+const (
+	defaultOpmlPath = "blogroll.opml"
+	blogrollData    = "blogroll"
+)
+
+// OPML outline of the feeds of a blogroll, see opml.org
+type opml struct {
+	XMLName  xml.Name      `xml:"opml"`
+	Version  string        `xml:"version,attr"`
+	Title    string        `xml:"head>title"`
+	Outlines []opmlOutline `xml:"body>outline"`
+}
+
+type opmlOutline struct {
+	Type    string `xml:"type,attr"`
+	Text    string `xml:"text,attr"`
+	Title   string `xml:"title,attr"`
+	XmlUrl  string `xml:"xmlUrl,attr"`
+	HtmlUrl string `xml:"htmlUrl,attr"`
+}
+
+// Helper function that returns the path of the OPML blogroll, and whether it
+// is enabled. The blogroll is enabled in the _config.yml with either:
+//
+//	opml: true
+//
+//	opml:
+//	  path: feeds.opml
+//	  title: Blogs I read
+func opmlOptions(conf Config) (path string, ok bool) {
+	path = conf.GetString("opml.path")
+	if path == "" {
+		path = defaultOpmlPath
+	}
+
+	switch v := conf.Get("opml").(type) {
+	case bool:
+		ok = v
+	case map[interface{}]interface{}:
+		ok = true
+	}
+	return
+}
+
+// Helper function that writes an OPML file of the feeds listed in the
+// _data/blogroll.yml to the destination directory, when enabled in the
+// _config.yml. Each entry of the list needs a title, xmlUrl and htmlUrl:
+//
+//   - title: Example Blog
+//     xmlUrl: http://example.com/feed.xml
+//     htmlUrl: http://example.com/
+func (s *Site) writeOPML() error {
+	path, ok := opmlOptions(s.Conf)
+	if !ok || s.data[blogrollData] == nil {
+		return nil
+	}
+	list, ok := s.data[blogrollData].([]interface{})
+	if !ok {
+		return fmt.Errorf("opml: %s/%s.yml must be a list of feeds", dataDir, blogrollData)
+	}
+
+	title := s.Conf.GetString("opml.title")
+	if title == "" {
+		title = s.Conf.GetString("title")
+	}
+	m := opml{Version: "2.0", Title: title}
+	for i, item := range list {
+		entry := Config{}
+		if fields, ok := item.(map[interface{}]interface{}); ok {
+			for key, val := range fields {
+				entry[fmt.Sprint(key)] = val
+			}
+		}
+		for _, key := range []string{"title", "xmlUrl", "htmlUrl"} {
+			if entry.GetString(key) == "" {
+				return fmt.Errorf("opml: entry %d of %s/%s.yml has no %s", i+1, dataDir, blogrollData, key)
+			}
+		}
+		m.Outlines = append(m.Outlines, opmlOutline{
+			Type:    "rss",
+			Text:    entry.GetString("title"),
+			Title:   entry.GetString("title"),
+			XmlUrl:  entry.GetString("xmlUrl"),
+			HtmlUrl: entry.GetString("htmlUrl"),
+		})
+	}
+
+	logf(MsgOpmlFile, path)
+	if err := s.writeXml(path, m); err != nil {
+		return fmt.Errorf("opml: %s", err)
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriteOPML(t *testing.T) {
+	site, cleanup := newTestSite(t, map[string]string{
+		"_config.yml": "title: My Blog\nxml_compact: true\nopml:\n  path: feeds/blogroll.opml\n",
+		"_data/blogroll.yml": "- title: Example & Co\n  xmlUrl: http://example.com/feed.xml\n  htmlUrl: http://example.com/\n" +
+			"- title: Other\n  xmlUrl: http://other.org/atom.xml\n  htmlUrl: http://other.org/\n"})
+	defer cleanup()
+
+	if err := site.Generate(); err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadFile(filepath.Join(site.Dest, "feeds", "blogroll.opml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `<?xml version="1.0" encoding="UTF-8"?>` + "\n" +
+		`<opml version="2.0"><head><title>My Blog</title></head><body>` +
+		`<outline type="rss" text="Example &amp; Co" title="Example &amp; Co" xmlUrl="http://example.com/feed.xml" htmlUrl="http://example.com/"></outline>` +
+		`<outline type="rss" text="Other" title="Other" xmlUrl="http://other.org/atom.xml" htmlUrl="http://other.org/"></outline>` +
+		`</body></opml>`
+	if string(b) != expected {
+		t.Errorf("Expected blogroll [%s] got [%s]", expected, b)
+	}
+}
+
+func TestWriteOPMLInvalid(t *testing.T) {
+	site, cleanup := newTestSite(t, map[string]string{
+		"_config.yml":        "opml: true\n",
+		"_data/blogroll.yml": "- title: Example\n  xmlUrl: http://example.com/feed.xml\n"})
+	defer cleanup()
+
+	err := site.Generate()
+	if err == nil || !strings.Contains(err.Error(), "entry 1 of _data/blogroll.yml has no htmlUrl") {
+		t.Errorf("Expected an error for the missing htmlUrl, got %v", err)
+	}
+}
+
+func TestWriteOPMLDisabled(t *testing.T) {
+	site, cleanup := newTestSite(t, map[string]string{
+		"_config.yml":        "",
+		"_data/blogroll.yml": "- title: Example\n"})
+	defer cleanup()
+
+	if err := site.Generate(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ioutil.ReadFile(filepath.Join(site.Dest, defaultOpmlPath)); err == nil {
+		t.Errorf("Expected no blogroll unless enabled in the _config.yml")
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+var MsgPruneFile = "Removing: %s"
+
+// Returns True if files that are already up to date in the destination
+// directory are left untouched, with skip_unchanged in the _config.yml.
+func (s *Site) skipUnchanged() bool {
+	return s.Conf.GetBool("skip_unchanged", false)
+}
+
+// Helper function that writes a generated file. When skipping unchanged
+// files, a file that already has the same content is not written again, so
+// that its modification time is kept and tools such as rsync don't see it as
+// changed.
+func (s *Site) writeOutput(fn string, b []byte, perm os.FileMode) error {
+	s.markWritten(fn)
+	if s.skipUnchanged() {
+		if old, err := ioutil.ReadFile(fn); err == nil && bytes.Equal(old, b) {
+			return nil
+		}
+	}
+	return ioutil.WriteFile(fn, b, perm)
+}
+
+// Size in bytes of the buffer files are copied through, unless set with
+// copy_buffer_size in the _config.yml.
+const defaultCopyBufferSize = 32 * 1024
+
+// Helper function that copies a file, such as a static file, to the
+// destination, streaming it through a buffer of copy_buffer_size bytes so
+// that large files such as videos are never read into memory at once. When
+// skipping unchanged files, a file that already has the same content is not
+// copied again.
+func (s *Site) copyOutput(from, to string) error {
+	s.markWritten(to)
+	size := s.Conf.GetInt("copy_buffer_size", defaultCopyBufferSize)
+	if size <= 0 {
+		size = defaultCopyBufferSize
+	}
+	if s.skipUnchanged() && sameContent(from, to, size) {
+		return nil
+	}
+	return copyTo(from, to, make([]byte, size))
+}
+
+// Helper function that records a file written by other means, such as the
+// sass command, so that it isn't removed as left over from a previous build.
+func (s *Site) markWritten(fn string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.written != nil {
+		s.written[fn] = true
+	}
+}
+
+// Helper function that returns the files of the generated site in the
+// destination directory: when pruning, only those written by the current
+// build or matching keep_files, so that files left over from a previous
+// build aren't compressed or mirrored before they are removed.
+func (s *Site) outputFiles() ([]string, error) {
+	keep := s.Conf.GetStringSlice("keep_files")
+	files := []string{}
+	err := filepath.Walk(s.Dest, func(fn string, fi os.FileInfo, err error) error {
+		if err != nil || fi.IsDir() {
+			return err
+		}
+		rel, _ := filepath.Rel(s.Dest, fn)
+		if s.written == nil || s.written[fn] || matchAny(keep, rel) {
+			files = append(files, fn)
+		}
+		return nil
+	})
+	return files, err
+}
+
+// Helper function that removes the files in the directory that were not
+// generated by the current build, such as pages since deleted, or every file
+// when clearing the site, along with any directories left empty. The
+// directory isn't cleared beforehand when skipping unchanged files, or with
+// prune in the _config.yml. Files matching keep_files, such as a .git
+// directory, are never removed:
+//
+//	keep_files: [.git, CNAME, "downloads/*.zip"]
+func (s *Site) prune(dir string) error {
+	keep := s.Conf.GetStringSlice("keep_files")
+	dirs := []string{}
+	err := filepath.Walk(dir, func(fn string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(dir, fn)
+		switch {
+		case rel != "." && matchAny(keep, rel):
+			if fi.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		case fi.IsDir():
+			dirs = append(dirs, fn)
+			return nil
+		case s.written[fn]:
+			return nil
+		}
+		logf(MsgPruneFile, fn)
+		return os.Remove(fn)
+	})
+	if err != nil {
+		return err
+	}
+
+	// remove the deepest directories first, leaving those that aren't empty
+	sort.Sort(sort.Reverse(sort.StringSlice(dirs)))
+	for _, d := range dirs[:len(dirs)-1] {
+		os.Remove(d)
+	}
+	return nil
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestGenerateSkipUnchanged(t *testing.T) {
+	site, cleanup := newTestSite(t, map[string]string{
+		"_config.yml": "skip_unchanged: true\n",
+		"index.html":  "home",
+		"about.html":  "about",
+		"old.html":    "old",
+		"img/a.png":   "\x89PNG"})
+	defer cleanup()
+
+	if err := site.Generate(); err != nil {
+		t.Fatal(err)
+	}
+
+	// age the generated files, to tell whether they are written again
+	past := time.Now().Add(-time.Hour).Truncate(time.Second)
+	for _, file := range []string{"index.html", "about.html", "img/a.png"} {
+		if err := os.Chtimes(filepath.Join(site.Dest, file), past, past); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ioutil.WriteFile(filepath.Join(site.Src, "about.html"), []byte("about us"), 0644)
+	os.Remove(filepath.Join(site.Src, "old.html"))
+	if err := site.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if err := site.Generate(); err != nil {
+		t.Fatal(err)
+	}
+
+	for file, unchanged := range map[string]bool{"index.html": true, "img/a.png": true, "about.html": false} {
+		fi, err := os.Stat(filepath.Join(site.Dest, file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fi.ModTime().Equal(past) != unchanged {
+			t.Errorf("Expected [%s] unchanged to be %v, got modified at %s", file, unchanged, fi.ModTime())
+		}
+	}
+	if b, _ := ioutil.ReadFile(filepath.Join(site.Dest, "about.html")); string(b) != "about us" {
+		t.Errorf("Expected the changed page to be written, got [%s]", b)
+	}
+	if _, err := os.Stat(filepath.Join(site.Dest, "old.html")); err == nil {
+		t.Errorf("Expected the deleted page to be removed from the site")
+	}
+}
+
+func TestGeneratePrune(t *testing.T) {
+	site, cleanup := newTestSite(t, map[string]string{
+		"_config.yml": "prune: true\nkeep_files: [.git, \"downloads/*.zip\"]\n",
+		"index.html":  "home",
+		"old.html":    "old"})
+	defer cleanup()
+
+	if err := site.Generate(); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range map[string]string{
+		".git/HEAD":          "ref",
+		"downloads/v1.zip":   "zip",
+		"downloads/stale.md": "stale",
+	} {
+		fn := filepath.Join(site.Dest, name)
+		os.MkdirAll(filepath.Dir(fn), 0755)
+		ioutil.WriteFile(fn, []byte(content), 0644)
+	}
+
+	os.Remove(filepath.Join(site.Src, "old.html"))
+	if err := site.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if err := site.Generate(); err != nil {
+		t.Fatal(err)
+	}
+
+	for file, exists := range map[string]bool{
+		"index.html":         true,
+		".git/HEAD":          true,
+		"downloads/v1.zip":   true,
+		"old.html":           false,
+		"downloads/stale.md": false,
+	} {
+		if _, err := os.Stat(filepath.Join(site.Dest, file)); (err == nil) != exists {
+			t.Errorf("Expected [%s] to exist %v, got %v", file, exists, err)
+		}
+	}
+}
+
+func TestGeneratePruneGzipMirrors(t *testing.T) {
+	site, cleanup := newTestSite(t, map[string]string{
+		"_config.yml": "prune: true\ngzip: true\ngzip_min_size: 0\nmirrors: [mirror]\n",
+		"index.html":  "home",
+		"old.html":    "old"})
+	defer cleanup()
+
+	if err := site.Generate(); err != nil {
+		t.Fatal(err)
+	}
+	os.Remove(filepath.Join(site.Src, "old.html"))
+	if err := site.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if err := site.Generate(); err != nil {
+		t.Fatal(err)
+	}
+
+	mirror := filepath.Join(site.Src, "mirror")
+	for fn, exists := range map[string]bool{
+		filepath.Join(site.Dest, "index.html.gz"): true,
+		filepath.Join(mirror, "index.html.gz"):    true,
+		filepath.Join(site.Dest, "old.html"):      false,
+		filepath.Join(site.Dest, "old.html.gz"):   false,
+		filepath.Join(mirror, "old.html"):         false,
+		filepath.Join(mirror, "old.html.gz"):      false,
+	} {
+		if _, err := os.Stat(fn); (err == nil) != exists {
+			t.Errorf("Expected [%s] to exist %v, got %v", fn, exists, err)
+		}
+	}
+}
+
+func TestClearKeepFiles(t *testing.T) {
+	site, cleanup := newTestSite(t, map[string]string{
+		"_config.yml":           "keep_files: [CNAME, .git]\n",
+		"_layouts/default.html": "{{.content}}",
+		"index.html":            "---\n---\nHome"})
+	defer cleanup()
+
+	if err := site.Generate(); err != nil {
+		t.Fatal(err)
+	}
+	ioutil.WriteFile(filepath.Join(site.Dest, "CNAME"), []byte("www.example.com\n"), 0644)
+	os.MkdirAll(filepath.Join(site.Dest, ".git"), 0755)
+	ioutil.WriteFile(filepath.Join(site.Dest, ".git", "HEAD"), []byte("ref: refs/heads/gh-pages\n"), 0644)
+	os.MkdirAll(filepath.Join(site.Dest, "old"), 0755)
+	ioutil.WriteFile(filepath.Join(site.Dest, "old", "page.html"), []byte("old"), 0644)
+
+	if err := site.Generate(); err != nil {
+		t.Fatal(err)
+	}
+	for _, fn := range []string{"CNAME", ".git/HEAD", "index.html"} {
+		if _, err := os.Stat(filepath.Join(site.Dest, fn)); err != nil {
+			t.Errorf("Expected %s kept when the site is cleared, got %v", fn, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(site.Dest, "old")); !os.IsNotExist(err) {
+		t.Errorf("Expected the files left over cleared, got %v", err)
+	}
+}
@@ -2,14 +2,45 @@ package main
 
 import (
 	"bytes"
-	"github.com/russross/blackfriday"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
 	"io"
 	"io/ioutil"
 	"launchpad.net/goyaml"
 	"path/filepath"
+	"regexp"
 	"strings"
+	"time"
+	"unicode"
 )
 
+// Built-in permalink styles that may be used in place of a pattern.
+// The source style mirrors the path of the source file, only swapping its
+// extension, e.g. docs/guide.md to docs/guide.html.
+var permalinkStyles = map[string]string{
+	"date":   "/:categories/:year/:month/:day/:title.html",
+	"pretty": "/:categories/:year/:month/:day/:title/",
+	"source": "/:path:output_ext",
+}
+
+// Separates the short description of a page or post from the rest of it.
+const moreSeparator = "<!--more-->"
+
+// Matches html tags, which are removed from plain text.
+var htmlTag = regexp.MustCompile(`<[^>]*>`)
+
+// Front matter delimiters. YAML front matter is fenced by --- lines, while
+// JSON front matter is fenced by ;;; lines or is a bare { } object at the
+// very top of the file.
+const (
+	yamlDelim = "---"
+	jsonDelim = ";;;"
+)
+
+var ErrJSONObject = errors.New("JSON error: front-end matter must be followed by a blank line or ;;;")
+
 // A Page represents the key-value pairs in a page or posts front-end YAML as
 // well as the markup in the body.
 type Page map[string]interface{}
@@ -21,45 +52,74 @@ func ParsePage(fn string) (Page, error) {
 	if err != nil {
 		return nil, err
 	}
-	return parsePage(fn, c)
+	return parsePage(fn, c, converters)
 }
 
 // Helper function that creates a new Page from a byte array, parsing the
-// front-end YAML and the markup, and pre-calculating all page-level variables.
-func parsePage(fn string, c []byte) (Page, error) {
+// front-end YAML and the markup, converted with the table of converters,
+// and pre-calculating all page-level variables.
+func parsePage(fn string, c []byte, table map[string]Converter) (Page, error) {
 
-	page, err := parseMatter(c) //map[string] interface{} { }
+	page, raw, err := parseFrontMatter(c)
 	if err != nil {
-		return nil, err
+		return nil, &BuildError{fn, err}
 	}
 
 	ext := filepath.Ext(fn)
 	ext_output := ext
-	markdown := isMarkdown(fn)
+	converted := isConverted(table, fn)
 
-	// if markdown, change the output extension to html
-	if markdown {
+	// if converted, such as markdown, change the output extension to html
+	if converted {
 		ext_output = ".html"
 	}
 
+	// raw pages show their markup as is, e.g. to document markdown itself
+	if page.IsRaw() {
+		ext_output = ".html"
+		converted = false
+	}
+
+	// the id is used internally, so keep an id from the front-end matter
+	// as the page's guid
+	if id, ok := page["id"]; ok && page["guid"] == nil {
+		page["guid"] = fmt.Sprint(id)
+	}
+
 	page["ext"] = ext
+	page["path"] = filepath.ToSlash(fn)
 	page["output_ext"] = ext_output
 	page["id"] = removeExt(fn)
 	page["url"] = replaceExt(fn, ext_output)
 	page["pretty_url"] = prettyUrl(replaceExt(fn, ext_output))
 
-	// if markdown, convert to html
-	raw := parseContent(c)
-	if markdown {
-		page["content"] = string(blackfriday.MarkdownCommon(raw))
-	} else {
-		page["content"] = string(raw)
+	// the slug defaults to the file name, without the extension
+	if page.GetSlug() == "" {
+		page["slug"] = removeExt(filepath.Base(fn))
 	}
 
-	if page["layout"] == nil {
-		page["layout"] = "default"
+	// if markdown, or any other registered markup, convert to html
+	if page.IsRaw() {
+		page["content"] = "<pre>" + html.EscapeString(string(raw)) + "</pre>\n"
+		page["short_description"] = page["content"]
+	} else {
+		content, err := convert(table, fn, raw)
+		if err != nil {
+			return nil, &BuildError{fn, err}
+		}
+		page["content"] = string(content)
 	}
 
+	// the short description of converted markup is the markup up to the
+	// <!--more--> separator, converted on its own so that its html is
+	// always well-balanced
+	if converted {
+		desc, err := convert(table, fn, excerptMarkup(raw))
+		if err != nil {
+			return nil, &BuildError{fn, err}
+		}
+		page["short_description"] = string(desc)
+	}
 	page["short_description"] = page.GetShortDescription()
 
 	// according to spec, Jekyll allows user to enter either category or
@@ -69,19 +129,180 @@ func parsePage(fn string, c []byte) (Page, error) {
 		delete(page, "category")
 	}
 
+	// a permalink in the front-end matter overrides the url
+	if permalink := page.GetString("permalink"); permalink != "" {
+		if err := page.setPermalink(permalink); err != nil {
+			return nil, &BuildError{fn, err}
+		}
+	}
+
 	return page, nil
 }
 
+// Helper function that sets the url of the page from a permalink, expanding
+// any :tokens in the pattern. A permalink ending in a slash is written to the
+// index.html in that directory, while one with a file name, such as
+// /humans.txt, is written verbatim. Returns ErrBadPermalink if the resulting
+// url would fall outside of the destination directory.
+func (p Page) setPermalink(permalink string) error {
+	if style, ok := permalinkStyles[permalink]; ok {
+		permalink = style
+	}
+
+	return p.setUrl(permalinkUrl(expandPermalink(permalink, p)))
+}
+
+// Helper function that sets the url of the page, the path of the generated
+// file relative to the destination directory. Returns ErrBadPermalink if the
+// url would fall outside of the destination directory.
+func (p Page) setUrl(url string) error {
+	if err := checkUrl(url); err != nil {
+		return err
+	}
+	p["url"] = url
+	p["pretty_url"] = prettyUrl(url)
+	p["output_ext"] = filepath.Ext(url)
+	return nil
+}
+
+// Helper function that replaces the :tokens in a permalink pattern with the
+// values of the page. The :categories token expands to all of the page's
+// categories, slugified and separated by slashes, in the order they were
+// declared. The :path token expands to the path of the source file, without
+// its extension, and :output_ext to the extension of the output file. For
+// documents of a collection, :collection expands to the collection's name
+// and :path is relative to its directory. Empty
+// tokens collapse without leaving a stray slash.
+func expandPermalink(pattern string, p Page) string {
+	// the path of a document is relative to its collection's directory
+	path := p.GetString("relative_path")
+	if path == "" {
+		path = p.GetPath()
+	}
+
+	var year, month, day string
+	if date, ok := p["date"].(time.Time); ok {
+		year = fmt.Sprintf("%04d", date.Year())
+		month = fmt.Sprintf("%02d", date.Month())
+		day = fmt.Sprintf("%02d", date.Day())
+	}
+
+	categories := []string{}
+	for _, category := range p.GetCategories() {
+		categories = append(categories, slugify(category))
+	}
+
+	r := strings.NewReplacer(
+		":categories", strings.Join(categories, "/"),
+		":year", year,
+		":month", month,
+		":day", day,
+		":title", p.GetSlug(),
+		":slug", p.GetSlug(),
+		":collection", p.GetString("collection"),
+		":path", removeExt(path),
+		":output_ext", p.GetString("output_ext"),
+	)
+	return collapseSlashes(r.Replace(pattern))
+}
+
+// Helper function that detects the format of the front-end matter, YAML or
+// JSON, based on its delimiter and returns the parsed key-value pairs along
+// with the markup (content) that follows it.
+func parseFrontMatter(content []byte) (Page, []byte, error) {
+	trimmed := bytes.TrimLeft(content, " \t\r\n")
+	switch {
+	case bytes.HasPrefix(trimmed, []byte(jsonDelim)):
+		page, err := parseJSONMatter(parseMatterBlock(content, jsonDelim))
+		return page, parseContent(content, jsonDelim), err
+	case bytes.HasPrefix(trimmed, []byte("{")):
+		return parseJSONObject(trimmed)
+	}
+	page, err := parseMatter(content)
+	return page, parseContent(content, yamlDelim), err
+}
+
 // Helper function to parse the front-end yaml matter.
 func parseMatter(content []byte) (Page, error) {
 	page := map[string]interface{}{}
 	err := goyaml.Unmarshal(content, &page)
+	if page == nil {
+		// empty front-end matter
+		page = map[string]interface{}{}
+	}
 	return page, err
 }
 
-// Helper function that separates the front-end yaml from the markup, and
+// Helper function to parse the front-end json matter.
+func parseJSONMatter(content []byte) (Page, error) {
+	page := map[string]interface{}{}
+	if err := json.Unmarshal(content, &page); err != nil {
+		return nil, fmt.Errorf("JSON error: %s", err)
+	}
+	return page, nil
+}
+
+// Helper function to parse an undelimited json object at the top of the
+// file. The object must be followed by a blank line, or a ;;; line, so that
+// markup that merely starts with a brace isn't taken for front-end matter.
+// Everything after it is returned as the markup.
+func parseJSONObject(content []byte) (Page, []byte, error) {
+	page := map[string]interface{}{}
+	r := bytes.NewReader(content)
+	dec := json.NewDecoder(r)
+	if err := dec.Decode(&page); err != nil {
+		return nil, nil, fmt.Errorf("JSON error: %s", err)
+	}
+
+	rest, err := ioutil.ReadAll(io.MultiReader(dec.Buffered(), r))
+	if err != nil {
+		return nil, nil, err
+	}
+	rest = bytes.TrimLeft(rest, " \t\r")
+	if len(rest) == 0 {
+		return page, rest, nil
+	}
+	if rest[0] == '\n' {
+		line := bytes.TrimLeft(rest[1:], " \t\r")
+		switch {
+		case len(line) == 0 || line[0] == '\n':
+			return page, bytes.TrimLeft(line, " \t\r\n"), nil
+		case bytes.HasPrefix(line, []byte(jsonDelim)):
+			if i := bytes.IndexByte(line, '\n'); i >= 0 {
+				return page, line[i+1:], nil
+			}
+			return page, nil, nil
+		}
+	}
+	return nil, nil, ErrJSONObject
+}
+
+// Helper function that returns only the front-end matter found between the
+// first two delimiter lines as a byte array.
+func parseMatterBlock(content []byte, delim string) []byte {
+	b := bytes.NewBuffer(content)
+	m := new(bytes.Buffer)
+	streams := 0
+
+	for streams < 2 {
+		line, err := b.ReadString('\n')
+		switch {
+		case strings.HasPrefix(line, delim):
+			streams++
+		case streams == 1:
+			m.WriteString(line)
+		}
+		if err != nil {
+			break
+		}
+	}
+
+	return m.Bytes()
+}
+
+// Helper function that separates the front-end matter from the markup, and
 // and returns only the markup (content) as a byte array.
-func parseContent(content []byte) []byte {
+func parseContent(content []byte, delim string) []byte {
 	//now we need to parse out the markdown section create
 	//buffered reader
 	b := bytes.NewBuffer(content)
@@ -103,7 +324,7 @@ parse:
 			return nil
 		case streams >= 2:
 			m.WriteString(line)
-		case strings.HasPrefix(line, "---"):
+		case strings.HasPrefix(line, delim):
 			streams++
 		}
 	}
@@ -140,6 +361,8 @@ func (p Page) GetStrings(key string) (strs []string) {
 			for _, s := range v.([]interface{}) {
 				strs = append(strs, s.(string))
 			}
+		case []string:
+			strs = v.([]string)
 		case string:
 			for _, s := range strings.Split(v.(string), ",") {
 				if x := strings.TrimSpace(s); len(x) > 0 {
@@ -159,9 +382,17 @@ func (p Page) GetBytes(key string) (b []byte) {
 	return
 }
 
-// Gets the layout file to use, without the extension.
+// Gets the layout file to use, without the extension. If none is specified
+// the default layout is used, unless the page isn't written as markup, such
+// as a humans.txt or manifest.webmanifest.
 // Layout files must be placed in the _layouts directory.
 func (p Page) GetLayout() string {
+	if p["layout"] == nil {
+		if url := p.GetUrl(); url != "" && !isHtml(url) {
+			return "nil"
+		}
+		return "default"
+	}
 	return p.GetString("layout")
 }
 
@@ -170,12 +401,54 @@ func (p Page) GetTitle() string {
 	return p.GetString("title")
 }
 
+// Gets the slug of the Page, which is the file name without the extension
+// (and without the date for posts) unless specified in the front-end yaml.
+// e.g. my-post
+func (p Page) GetSlug() string {
+	return p.GetString("slug")
+}
+
+// Gets the language of the Page, e.g. en or pt-BR. Pages added to a site
+// default to the site's language.
+func (p Page) GetLang() string {
+	return p.GetString("lang")
+}
+
+// Gets the date of the Page, or the zero time if it has none.
+func (p Page) GetDate() (date time.Time) {
+	date, _ = p["date"].(time.Time)
+	return
+}
+
 // Gets the URL / relative path of the Page.
 // e.g. /2008/12/14/my-post.html
 func (p Page) GetUrl() string {
 	return p.GetString("url")
 }
 
+// Gets the public url of the Page, relative to the root of the site. A page
+// written to the index.html of a directory is linked to by the directory.
+// e.g. /2008/12/14/my-post/ or /humans.txt
+//
+// Feeds, sitemaps, redirects and canonical links all use the permalink, so
+// they agree with each other and with the file written by OutputPath.
+func (p Page) Permalink() string {
+	return "/" + prettyUrl(p.GetUrl())
+}
+
+// Gets the path of the file the Page is written to, relative to the
+// destination directory.
+// e.g. 2008/12/14/my-post/index.html
+func (p Page) OutputPath() string {
+	return filepath.FromSlash(p.GetUrl())
+}
+
+// Gets the path of the Page's source file, relative to the source directory.
+// e.g. _posts/2008-12-14-my-post.md
+func (p Page) GetPath() string {
+	return p.GetString("path")
+}
+
 // Gets the Extension of the File (.html, .md, etc)
 func (p Page) GetExt() string {
 	return p.GetString("ext")
@@ -192,7 +465,10 @@ func (p Page) GetContent() (c string) {
 // Gets short description of post
 // i.e. text until hitting <!-more->
 func (p Page) GetShortDescription() string {
-	index := strings.Index(p.GetContent(), "<!--more-->")
+	if desc, ok := p["short_description"].(string); ok {
+		return desc
+	}
+	index := strings.Index(p.GetContent(), moreSeparator)
 	if index < 0 {
 		return p.GetContent()
 	}
@@ -200,6 +476,136 @@ func (p Page) GetShortDescription() string {
 	return p.GetContent()[:index]
 }
 
+// Helper function that returns the markup of the short description of a
+// page: the markup up to the <!--more--> separator or, without one, the
+// first block of the markup, up to the first blank line. A separator in a
+// fenced or indented code block is ignored, as are blank lines in a fenced
+// code block.
+func excerptMarkup(markup []byte) []byte {
+	fenced, started := false, false
+	offset := 0
+	for _, line := range bytes.SplitAfter(markup, []byte("\n")) {
+		trimmed := bytes.TrimSpace(line)
+		indented := bytes.HasPrefix(line, []byte("    ")) || bytes.HasPrefix(line, []byte("\t"))
+		switch {
+		case bytes.HasPrefix(trimmed, []byte("```")) || bytes.HasPrefix(trimmed, []byte("~~~")):
+			fenced = !fenced
+		case !fenced && !indented:
+			if i := bytes.Index(line, []byte(moreSeparator)); i >= 0 {
+				return markup[:offset+i]
+			}
+		}
+		offset += len(line)
+	}
+
+	// no separator, so fall back to the first block
+	offset = 0
+	fenced = false
+	for _, line := range bytes.SplitAfter(markup, []byte("\n")) {
+		trimmed := bytes.TrimSpace(line)
+		switch {
+		case bytes.HasPrefix(trimmed, []byte("```")) || bytes.HasPrefix(trimmed, []byte("~~~")):
+			fenced = !fenced
+		case len(trimmed) == 0 && started && !fenced:
+			return markup[:offset]
+		}
+		if len(trimmed) > 0 {
+			started = true
+		}
+		offset += len(line)
+	}
+	return markup
+}
+
+// Gets a plain text excerpt of the Page, for search indexes and feeds. This
+// is the short description without any markup, truncated to the given
+// number of words, and ends in an ellipsis only if it was truncated.
+func (p Page) GetExcerpt(words int) string {
+	fields := strings.Fields(plainText(p.GetShortDescription()))
+	if words <= 0 || len(fields) <= words {
+		return strings.Join(fields, " ")
+	}
+	return strings.Join(fields[:words], " ") + "…"
+}
+
+// Gets the number of minutes it takes to read the Page, at the given number
+// of words per minute, rounded up. Pages with any content take at least a
+// minute to read.
+func (p Page) ReadingTime(wpm int) int {
+	if wpm <= 0 {
+		wpm = defaultWordsPerMinute
+	}
+	return (p.GetWordCount() + wpm - 1) / wpm
+}
+
+// Gets the identifier of the Page's comment thread, e.g. for Disqus. It is
+// the comment_id of the front-end matter, if any, or else the slug, so that
+// threads aren't lost when the permalinks change. Sites whose threads are
+// already keyed by url may derive it from the permalink instead.
+func (p Page) CommentId(fromUrl bool) string {
+	if id := p.GetString("comment_id"); id != "" {
+		return id
+	}
+	if fromUrl {
+		return p.Permalink()
+	}
+	return p.GetSlug()
+}
+
+// Gets the number of words in the content of the Page. This is the
+// word_count counted once the page is read, if any, since the words of the
+// content don't change once its urls are rewritten.
+func (p Page) GetWordCount() int {
+	if n, ok := p["word_count"].(int); ok {
+		return n
+	}
+	return countWords(p.GetPlainText())
+}
+
+// Gets the content of the Page without any markup.
+func (p Page) GetPlainText() string {
+	return plainText(p.GetContent())
+}
+
+// Helper function that returns the text of the html, without any tags or
+// entities, for excerpts and word counts.
+func plainText(s string) string {
+	return html.UnescapeString(htmlTag.ReplaceAllString(s, " "))
+}
+
+// Helper function that counts the words in the text. Words are separated by
+// spaces, except in scripts written without them, such as Chinese and
+// Japanese, where each character counts as a word. Punctuation on its own
+// isn't counted.
+func countWords(text string) (n int) {
+	inWord := false
+	for _, r := range text {
+		switch {
+		case unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana):
+			n++
+			inWord = false
+		case unicode.IsSpace(r):
+			inWord = false
+		case !inWord && (unicode.IsLetter(r) || unicode.IsNumber(r)):
+			n++
+			inWord = true
+		}
+	}
+	return
+}
+
+// Returns True if the markup of the Page is shown as is, with render: raw in
+// its front matter, rather than converted or executed as a template.
+func (p Page) IsRaw() bool {
+	return p.GetString("render") == "raw"
+}
+
+// Returns True if the Page is a post read from the _drafts directory.
+func (p Page) IsDraft() bool {
+	draft, _ := p["draft"].(bool)
+	return draft
+}
+
 // Gets the list of tags to which this Post belongs.
 func (p Page) GetTags() []string {
 	return p.GetStrings("tags")
@@ -1,7 +1,10 @@
 package main
 
 import (
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestGetShortDescription(t *testing.T) {
@@ -18,3 +21,329 @@ func TestGetShortDescription(t *testing.T) {
 		t.Errorf("Expected fooblah foobar got [%s]", resp)
 	}
 }
+
+func TestParsePageYAML(t *testing.T) {
+	page, err := parsePage("about.md", []byte("---\ntitle: About\n---\nhello"), converters)
+	if err != nil {
+		t.Fatalf("Expected YAML front matter to parse, got %s", err)
+	}
+	if title := page.GetTitle(); title != "About" {
+		t.Errorf("Expected title [About] got [%s]", title)
+	}
+	if content := page.GetContent(); content != "<p>hello</p>\n" {
+		t.Errorf("Expected content [<p>hello</p>] got [%s]", content)
+	}
+}
+
+func TestParsePageEmptyYAML(t *testing.T) {
+	page, err := parsePage("about.md", []byte("---\n---\nhello"), converters)
+	if err != nil {
+		t.Fatalf("Expected empty YAML front matter to parse, got %s", err)
+	}
+	if url := page.GetUrl(); url != "about.html" {
+		t.Errorf("Expected url [about.html] got [%s]", url)
+	}
+	if content := page.GetContent(); content != "<p>hello</p>\n" {
+		t.Errorf("Expected content [<p>hello</p>] got [%s]", content)
+	}
+}
+
+func TestParsePageJSON(t *testing.T) {
+	tests := map[string]string{
+		"delimited": ";;;\n{\"title\": \"About\"}\n;;;\nhello",
+		"object":    "{\n  \"title\": \"About\"\n}\n\nhello",
+		"fenced":    "{\"title\": \"About\"}\n;;;\nhello",
+	}
+
+	for name, src := range tests {
+		page, err := parsePage("about.md", []byte(src), converters)
+		if err != nil {
+			t.Errorf("Expected %s JSON front matter to parse, got %s", name, err)
+			continue
+		}
+		if title := page.GetTitle(); title != "About" {
+			t.Errorf("Expected %s title [About] got [%s]", name, title)
+		}
+		if content := page.GetContent(); content != "<p>hello</p>\n" {
+			t.Errorf("Expected %s content [<p>hello</p>] got [%s]", name, content)
+		}
+	}
+}
+
+func TestParsePageMalformed(t *testing.T) {
+	tests := []string{
+		"---\ntitle: [About\n---\nhello",
+		";;;\n{\"title\": About}\n;;;\nhello",
+		"{\"title\": \"About\"\nhello",
+		"{\"title\": \"About\"}\nhello",
+	}
+
+	for _, src := range tests {
+		_, err := parsePage("about.md", []byte(src), converters)
+		if err == nil {
+			t.Errorf("Expected error parsing malformed front matter [%s]", src)
+			continue
+		}
+		if !strings.HasPrefix(err.Error(), "about.md: ") {
+			t.Errorf("Expected error to name the file, got [%s]", err)
+		}
+	}
+}
+
+func TestParsePagePermalink(t *testing.T) {
+	tests := map[string]string{
+		"/about/":          "about/index.html",
+		"/about/me.html":   "about/me.html",
+		"contact/":         "contact/index.html",
+		"/":                "index.html",
+		"/humans.txt":      "humans.txt",
+		"/a/b/../c/":       "",
+		"../../etc/passwd": "",
+		"/../outside/":     "",
+		"..\\outside.html": ""}
+
+	for permalink, url := range tests {
+		src := "---\npermalink: \"" + strings.Replace(permalink, "\\", "\\\\", -1) + "\"\n---\nhello"
+		page, err := parsePage("about.html", []byte(src), converters)
+		switch {
+		case url == "" && err == nil:
+			t.Errorf("Expected permalink [%s] to be rejected, got url [%s]", permalink, page.GetUrl())
+		case url == "" && !strings.HasPrefix(err.Error(), "about.html: "):
+			t.Errorf("Expected error to name the page, got [%s]", err)
+		case url != "" && err != nil:
+			t.Errorf("Expected permalink [%s] to parse, got %s", permalink, err)
+		case url != "" && page.GetUrl() != url:
+			t.Errorf("Expected permalink [%s] to resolve to [%s] got [%s]", permalink, url, page.GetUrl())
+		}
+	}
+}
+
+func TestParsePageSlug(t *testing.T) {
+	tests := map[string]string{
+		"---\ntitle: About\n---\n":              "about-me",
+		"---\ntitle: About\nslug: about\n---\n": "about"}
+
+	for src, slug := range tests {
+		page, err := parsePage("pages/about-me.md", []byte(src), converters)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if page.GetSlug() != slug {
+			t.Errorf("Expected page slug [%s] got [%s]", slug, page.GetSlug())
+		}
+	}
+}
+
+func TestExpandPermalink(t *testing.T) {
+	date := time.Date(2013, 2, 14, 0, 0, 0, 0, time.UTC)
+	post := Page{"slug": "my-post", "date": date, "categories": []string{"Tech", "Go Lang"}}
+	none := Page{"slug": "my-post", "date": date}
+	doc := Page{"slug": "guide", "path": "docs/guide.md", "output_ext": ".html"}
+
+	tests := []struct {
+		pattern string
+		page    Page
+		url     string
+	}{
+		{"/:categories/:year/:month/:day/:title/", post, "/tech/go-lang/2013/02/14/my-post/"},
+		{"/:categories/:year/:month/:day/:title/", none, "/2013/02/14/my-post/"},
+		{"/:categories/:slug.html", none, "/my-post.html"},
+		{"/blog/:categories/:slug/", post, "/blog/tech/go-lang/my-post/"},
+		{"/:path:output_ext", doc, "/docs/guide.html"},
+		{"/:path/", doc, "/docs/guide/"}}
+
+	for _, test := range tests {
+		if url := expandPermalink(test.pattern, test.page); url != test.url {
+			t.Errorf("Expected permalink [%s] to expand to [%s] got [%s]", test.pattern, test.url, url)
+		}
+	}
+}
+
+func TestParsePageSourcePermalink(t *testing.T) {
+	tests := map[string]string{
+		"docs/guide.md":     "docs/guide.html",
+		"docs/api/index.md": "docs/api/index.html",
+		"humans.txt":        "humans.txt"}
+
+	for fn, url := range tests {
+		page, err := parsePage(fn, []byte("---\npermalink: source\n---\nhello"), converters)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if page.GetUrl() != url {
+			t.Errorf("Expected [%s] to be written to [%s] got [%s]", fn, url, page.GetUrl())
+		}
+	}
+}
+
+func TestPermalinkOutputPath(t *testing.T) {
+	tests := []struct {
+		url, permalink, output string
+	}{
+		{"2013/02/14/my-post/index.html", "/2013/02/14/my-post/", "2013/02/14/my-post/index.html"},
+		{"about.html", "/about.html", "about.html"},
+		{"humans.txt", "/humans.txt", "humans.txt"},
+		{"index.html", "/", "index.html"},
+	}
+	for _, test := range tests {
+		page := Page{"url": test.url}
+		if page.Permalink() != test.permalink {
+			t.Errorf("Expected permalink [%s] got [%s] for url [%s]", test.permalink, page.Permalink(), test.url)
+		}
+		if page.OutputPath() != filepath.FromSlash(test.output) {
+			t.Errorf("Expected output path [%s] got [%s] for url [%s]", test.output, page.OutputPath(), test.url)
+		}
+	}
+}
+
+func TestExcerptMarkup(t *testing.T) {
+	tests := map[string]string{
+		"Intro.\n\n<!--more-->\n\nRest.":                    "Intro.\n\n",
+		"Intro. <!--more--> Rest.":                          "Intro. ",
+		"```\n<!--more-->\n```\nAfter.\n<!--more-->\nRest.": "```\n<!--more-->\n```\nAfter.\n",
+		"    <!--more-->\n\nText <!--more--> rest":          "    <!--more-->\n\nText ",
+		"First para\nline two.\n\nSecond para.":             "First para\nline two.\n",
+		"\n\nFirst.\n\nSecond.":                             "\n\nFirst.\n",
+		"```\ncode\n\nmore code\n```\n\nText.":              "```\ncode\n\nmore code\n```\n",
+		"Only one block.":                                   "Only one block.",
+	}
+	for markup, expected := range tests {
+		if excerpt := string(excerptMarkup([]byte(markup))); excerpt != expected {
+			t.Errorf("Expected excerpt markup [%q] got [%q] for [%q]", expected, excerpt, markup)
+		}
+	}
+}
+
+func TestParsePageShortDescription(t *testing.T) {
+	tests := map[string]string{
+		// the separator in the middle of a list doesn't leave it open
+		"* one\n* two <!--more-->\n* three\n": "<ul>\n<li>one</li>\n<li>two</li>\n</ul>\n",
+		"First.\n\nSecond.\n":                 "<p>First.</p>\n",
+	}
+	for markup, expected := range tests {
+		page, err := parsePage("post.md", []byte("---\n---\n"+markup), converters)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if desc := page.GetShortDescription(); desc != expected {
+			t.Errorf("Expected short description [%q] got [%q]", expected, desc)
+		}
+	}
+
+	// pages that are not converted are cut at the separator, as is
+	page, err := parsePage("page.html", []byte("---\n---\n<p>one</p>\n\n<p>two<!--more--></p>"), converters)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if desc := page.GetShortDescription(); desc != "<p>one</p>\n\n<p>two" {
+		t.Errorf("Expected html cut at the separator, got [%q]", desc)
+	}
+}
+
+func TestGetExcerpt(t *testing.T) {
+	page := Page{"content": "<p>Crème <em>brûlée</em> &amp; café\nau lait</p>\n<!--more--><p>rest</p>"}
+	tests := map[int]string{
+		0:  "Crème brûlée & café au lait",
+		5:  "Crème brûlée & café au…",
+		6:  "Crème brûlée & café au lait",
+		10: "Crème brûlée & café au lait",
+		2:  "Crème brûlée…",
+		4:  "Crème brûlée & café…",
+	}
+	for words, expected := range tests {
+		if excerpt := page.GetExcerpt(words); excerpt != expected {
+			t.Errorf("Expected excerpt [%s] got [%s] for %d words", expected, excerpt, words)
+		}
+	}
+}
+
+func TestReadingTime(t *testing.T) {
+	tests := []struct {
+		words, wpm, minutes int
+	}{
+		{0, 200, 0},
+		{1, 200, 1},
+		{200, 200, 1},
+		{201, 200, 2},
+		{450, 0, 3},
+		{450, 100, 5},
+	}
+	for _, test := range tests {
+		page := Page{"content": "<p>" + strings.Repeat("<em>word</em>&nbsp;", test.words) + "</p>"}
+		if minutes := page.ReadingTime(test.wpm); minutes != test.minutes {
+			t.Errorf("Expected %d words to take %d minutes at %d wpm, got %d", test.words, test.minutes, test.wpm, minutes)
+		}
+	}
+}
+
+func TestCommentId(t *testing.T) {
+	post := Page{"slug": "my-post", "url": "2013/02/14/my-post/index.html"}
+	custom := Page{"slug": "my-post", "url": "my-post/index.html", "comment_id": "thread-42"}
+
+	tests := []struct {
+		page    Page
+		fromUrl bool
+		id      string
+	}{
+		{post, false, "my-post"},
+		{post, true, "/2013/02/14/my-post/"},
+		{custom, false, "thread-42"},
+		{custom, true, "thread-42"},
+	}
+	for _, test := range tests {
+		if id := test.page.CommentId(test.fromUrl); id != test.id {
+			t.Errorf("Expected comment id [%s] from url %v, got [%s]", test.id, test.fromUrl, id)
+		}
+	}
+}
+
+func TestCountWords(t *testing.T) {
+	tests := map[string]int{
+		"":                        0,
+		"one two  three\nfour":    4,
+		"don't stop — it's 2013!": 4,
+		"日本語の文章":                  6,
+		"Go は 楽しい":                5,
+		"Crème brûlée & café":     3,
+		"   \t ":                  0,
+		"한국어 문장":                  2,
+	}
+	for text, expected := range tests {
+		if n := countWords(text); n != expected {
+			t.Errorf("Expected %d words got %d for [%s]", expected, n, text)
+		}
+	}
+}
+
+func TestGetPlainText(t *testing.T) {
+	page := Page{"content": "<p>Crème <em>brûlée</em> &amp; café</p>"}
+	if text := page.GetPlainText(); strings.Join(strings.Fields(text), " ") != "Crème brûlée & café" {
+		t.Errorf("Expected the content without markup, got [%s]", text)
+	}
+	if n := page.GetWordCount(); n != 3 {
+		t.Errorf("Expected 3 words got %d", n)
+	}
+
+	if len(page) != 1 {
+		t.Errorf("Expected the page to be left as is, got %v", page)
+	}
+
+	// the words counted once the page is read are used from then on
+	page["word_count"] = 2
+	if n := page.GetWordCount(); n != 2 {
+		t.Errorf("Expected the word_count of the page, got %d", n)
+	}
+}
+
+func TestParsePageRaw(t *testing.T) {
+	page, err := parsePage("syntax.md", []byte("---\nrender: raw\n---\n# Title <b>\n\n* item\n"), converters)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if content := page.GetContent(); content != "<pre># Title &lt;b&gt;\n\n* item\n</pre>\n" {
+		t.Errorf("Expected the markdown escaped in a pre, got [%s]", content)
+	}
+	if page.GetUrl() != "syntax.html" {
+		t.Errorf("Expected a raw page to be written to [syntax.html] got [%s]", page.GetUrl())
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"sort"
+)
+
+// Default number of posts on each page of a paginated listing.
+const defaultPerPage = 10
+
+// Helper function that expands a listing page with pagination enabled in its
+// front matter into one page per group of posts, for example:
+//
+//	pagination:
+//	  enabled: true
+//	  category: news
+//	  per_page: 5
+//
+// The posts, newest first, may be limited to a category or a tag. The first
+// page is written to the page's own url, and the following ones to
+// page/2/index.html and so on, under the page's directory (or under news/
+// for news.html). Each page gets a paginator with its posts and links to the
+// previous and next pages. Pages without pagination are returned as is.
+func (s *Site) paginate(page Page) []Page {
+	opts := Config(page)
+	if !opts.GetBool("pagination.enabled", false) {
+		return []Page{page}
+	}
+	perPage := opts.GetInt("pagination.per_page", defaultPerPage)
+	if perPage <= 0 {
+		perPage = defaultPerPage
+	}
+	category := opts.GetString("pagination.category")
+	tag := opts.GetString("pagination.tag")
+
+	posts := []Page{}
+	for _, post := range s.posts {
+		if category != "" && !contains(post.GetCategories(), category) {
+			continue
+		}
+		if tag != "" && !contains(post.GetTags(), tag) {
+			continue
+		}
+		posts = append(posts, post)
+	}
+	sort.Stable(byDate(posts))
+
+	total := (len(posts) + perPage - 1) / perPage
+	if total == 0 {
+		total = 1
+	}
+
+	// the url of each page, numbered from 1
+	dir := removeExt(page.GetUrl())
+	if path.Base(page.GetUrl()) == "index.html" {
+		dir = path.Dir(page.GetUrl())
+	}
+	pageUrl := func(num int) string {
+		if num == 1 {
+			return page.GetUrl()
+		}
+		return path.Join(dir, "page", fmt.Sprint(num), "index.html")
+	}
+	permalink := func(num int) string {
+		if num < 1 || num > total {
+			return ""
+		}
+		return Page{"url": pageUrl(num)}.Permalink()
+	}
+
+	pages := []Page{}
+	for num := 1; num <= total; num++ {
+		end := num * perPage
+		if end > len(posts) {
+			end = len(posts)
+		}
+		paginator := map[string]interface{}{
+			"posts":              posts[(num-1)*perPage : end],
+			"page":               num,
+			"per_page":           perPage,
+			"total_pages":        total,
+			"total_posts":        len(posts),
+			"previous_page":      0,
+			"next_page":          0,
+			"previous_page_path": permalink(num - 1),
+			"next_page_path":     permalink(num + 1),
+		}
+		if num > 1 {
+			paginator["previous_page"] = num - 1
+		}
+		if num < total {
+			paginator["next_page"] = num + 1
+		}
+
+		// only the first page is redirected to, from the page's redirect_from
+		p := Page{}
+		for k, v := range page {
+			if num > 1 && k == "redirect_from" {
+				continue
+			}
+			p[k] = v
+		}
+		p["url"] = pageUrl(num)
+		p["pretty_url"] = prettyUrl(p.GetUrl())
+		p["paginator"] = paginator
+		pages = append(pages, p)
+	}
+	return pages
+}
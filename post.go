@@ -3,37 +3,88 @@ package main
 import (
 	"errors"
 	"fmt"
+	"io/ioutil"
+	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
 	"time"
 )
 
 var (
-	ErrBadPostName = errors.New("Invalid post name. Expecting format YYYY-MM-DD-name-of-post.markdown")
+	ErrBadPostName  = errors.New("Invalid post name. Expecting format YYYY-MM-DD-name-of-post.markdown")
+	ErrBadPermalink = errors.New("Invalid permalink. Output must stay within the destination directory")
 )
 
 // ParseParse will parse a file with front-end YAML and markup content, and
 // return a key-value Post structure.
 func ParsePost(fn string) (Page, error) {
-	post, err := ParsePage(fn)
+	c, err := ioutil.ReadFile(fn)
 	if err != nil {
 		return nil, err
 	}
+	_, f := filepath.Split(fn)
+	return parsePost(fn, f, c, converters, false)
+}
 
-	// parse the Date and Title from the post's file name
+// ParseDraft will parse a file in the _drafts directory with front-end YAML
+// and markup content, and return a key-value Post structure. Drafts have no
+// date in their file name, so the time of the last modification is used.
+func ParseDraft(fn string) (Page, error) {
+	fi, err := os.Stat(fn)
+	if err != nil {
+		return nil, err
+	}
+	c, err := ioutil.ReadFile(fn)
+	if err != nil {
+		return nil, err
+	}
+	return parseDraft(fn, fi.ModTime(), c, converters)
+}
+
+// Helper function that creates a new Post from the byte array of a draft,
+// dated by the time it was last modified. Drafts have page.draft set, so
+// that themes may show a banner on them.
+func parseDraft(fn string, mod time.Time, c []byte, table map[string]Converter) (Page, error) {
 	_, f := filepath.Split(fn)
-	t, d, err := parsePostName(f)
+	post, err := parsePost(fn, mod.Format("2006-01-02-")+f, c, table, false)
+	if err != nil {
+		return nil, err
+	}
+	post["draft"] = true
+	return post, nil
+}
+
+// Helper function that creates a new Post from a byte array, taking the
+// date and title from the name, which is in the format
+// YYYY-MM-DD-name-of-post.markdown, followed by the time of the post if
+// withTime, see parsePostName.
+func parsePost(fn, f string, c []byte, table map[string]Converter, withTime bool) (Page, error) {
+	post, err := parsePage(fn, c, table)
 	if err != nil {
 		return nil, err
 	}
 
-	// set the post's date and title
+	// parse the Date and Title from the post's file name
+	t, slug, d, err := parsePostName(f, withTime)
+	if err != nil {
+		return nil, &BuildError{fn, err}
+	}
+
+	// set the post's date, title and slug
 	// ignore the title if the user specified in the front-end yaml
 	post["date"] = d
+	post["draft"] = false
 	if post.GetTitle() == "" {
 		post["title"] = t
 	}
 
+	// the slug of a page defaults to its file name which, for a post, still
+	// includes the date. ignore the slug if specified in the front-end yaml
+	if post.GetSlug() == removeExt(f) {
+		post["slug"] = slug
+	}
+
 	// figoure out the Posts permalink
 	mon := fmt.Sprintf("%02d", d.Month())
 	day := fmt.Sprintf("%02d", d.Day())
@@ -41,19 +92,38 @@ func ParsePost(fn string) (Page, error) {
 	//name := replaceExt(f, ".html")
 	post["id"] = filepath.Join(year, mon, day, f) // TODO try to remember why I need this field
 	//post["url"]= filepath.Join(year, mon, day, name[11:])
-	post["url"] = filepath.Join(post.GetCategories()[0], post.GetString("slug"), "index.html")
-	post["pretty_url"] = prettyUrl(filepath.Join(post.GetCategories()[0], post.GetString("slug"), "index.html"))
+	permalink := post.GetString("permalink")
+	if permalink == "" {
+		permalink = "/:slug/"
+		if categories := post.GetCategories(); len(categories) > 0 {
+			permalink = "/" + categories[0] + permalink
+		}
+	}
+	if err := post.setPermalink(permalink); err != nil {
+		return nil, &BuildError{fn, err}
+	}
 	post["short_description"] = post.GetShortDescription()
 
 	return post, nil
 }
 
+// Matches the optional time of a post after the date in its file name, in
+// the format HH-MM or HH-MM-SS.
+var postTime = regexp.MustCompile(`^([01][0-9]|2[0-3])-([0-5][0-9])(-[0-5][0-9])?-`)
+
 // Helper function to parse a blog posts filename, which is in the following
 // format: YYYY-MM-DD-name-of-post.markdown
 //
+// if withTime, enabled with post_time: true in the _config.yml, the date may
+// be followed by the time of the post, in the format HH-MM or HH-MM-SS, e.g.
+// 2013-02-14-12-30-name-of-post.markdown, so that posts of the same day are
+// ordered. Otherwise a name such as 2013-02-14-10-20-things.markdown keeps
+// its slug, 10-20-things. Posts without a time are dated at midnight.
+//
 // the name of the post will be separated from the time of the post, both of
-// which are returned by this function
-func parsePostName(fn string) (name string, date time.Time, err error) {
+// which are returned by this function along with the slug, the name of the
+// post as it appears in the file name.
+func parsePostName(fn string, withTime bool) (name, slug string, date time.Time, err error) {
 	if len(fn) < 12 {
 		err = ErrBadPostName
 		return
@@ -62,10 +132,38 @@ func parsePostName(fn string) (name string, date time.Time, err error) {
 	if err != nil {
 		return
 	}
-	name = fn[11:]
-	name = removeExt(name)
+	rest := fn[11:]
+
+	if m := postTime.FindStringSubmatch(rest); withTime && m != nil {
+		clock := strings.TrimSuffix(m[0], "-")
+		if m[3] == "" {
+			clock += "-00"
+		}
+		var t time.Time
+		if t, err = time.Parse("15-04-05", clock); err != nil {
+			return
+		}
+		date = time.Date(date.Year(), date.Month(), date.Day(),
+			t.Hour(), t.Minute(), t.Second(), 0, time.UTC)
+		rest = rest[len(m[0]):]
+	}
+
+	slug = removeExt(rest)
+	name = slug
 
 	name = strings.Replace(name, "-", " ", -1)
 	name = strings.ToTitle(name)
 	return
 }
+
+// Helper function that interprets the date of a post, parsed from its file
+// name at the same wall clock time in UTC, in the named timezone, e.g.
+// Europe/Berlin.
+func inTimezone(date time.Time, tz string) (time.Time, error) {
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		return date, fmt.Errorf("Invalid timezone %q: %s", tz, err)
+	}
+	return time.Date(date.Year(), date.Month(), date.Day(),
+		date.Hour(), date.Minute(), date.Second(), date.Nanosecond(), loc), nil
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParsePostName(t *testing.T) {
+	name, slug, date, err := parsePostName("2013-02-14-my-first-post.md", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name != "MY FIRST POST" {
+		t.Errorf("Expected post name [MY FIRST POST] got [%s]", name)
+	}
+	if slug != "my-first-post" {
+		t.Errorf("Expected post slug [my-first-post] got [%s]", slug)
+	}
+	if !date.Equal(time.Date(2013, 2, 14, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected post date [2013-02-14] got [%s]", date)
+	}
+
+	if _, _, _, err := parsePostName("my-first-post.md", false); err == nil {
+		t.Errorf("Expected error parsing post name without a date")
+	}
+}
+
+func TestParsePostNameTime(t *testing.T) {
+	tests := []struct {
+		fn, slug string
+		date     time.Time
+	}{
+		{"2020-01-01-12-30-title.md", "title", time.Date(2020, 1, 1, 12, 30, 0, 0, time.UTC)},
+		{"2020-01-01-23-59-59-title.md", "title", time.Date(2020, 1, 1, 23, 59, 59, 0, time.UTC)},
+		{"2020-01-01-10-tips.md", "10-tips", time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"2020-01-01-25-30-hours.md", "25-30-hours", time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, test := range tests {
+		_, slug, date, err := parsePostName(test.fn, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if slug != test.slug || !date.Equal(test.date) {
+			t.Errorf("Expected [%s %s] got [%s %s] for [%s]", test.slug, test.date, slug, date, test.fn)
+		}
+	}
+
+	// without post_time, the numbers are part of the slug
+	_, slug, date, err := parsePostName("2019-05-01-10-20-things.md", false)
+	if err != nil || slug != "10-20-things" || !date.Equal(time.Date(2019, 5, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected [10-20-things] at midnight got [%s %s] %v", slug, date, err)
+	}
+}
+
+func TestReadPostTimezone(t *testing.T) {
+	site, cleanup := newTestSite(t, map[string]string{
+		"_config.yml":                        "timezone: America/New_York\npost_time: true\n",
+		"_layouts/default.html":              "{{.content}}",
+		"_posts/2020-01-01-09-00-morning.md": "---\n---\nmorning",
+		"_posts/2020-01-01-18-30-evening.md": "---\ntimezone: Europe/Berlin\n---\nevening",
+		"_posts/2020-01-02-next-day.md":      "---\n---\nnext"})
+	defer cleanup()
+
+	expected := map[string]string{
+		"morning":  "2020-01-01T09:00:00-05:00",
+		"evening":  "2020-01-01T18:30:00+01:00",
+		"next-day": "2020-01-02T00:00:00-05:00"}
+	for _, post := range site.posts {
+		date := post.GetDate().Format(time.RFC3339)
+		if date != expected[post.GetSlug()] {
+			t.Errorf("Expected post [%s] dated [%s] got [%s]", post.GetSlug(), expected[post.GetSlug()], date)
+		}
+	}
+	if len(site.posts) != 3 {
+		t.Errorf("Expected 3 posts got %d", len(site.posts))
+	}
+
+	if _, err := inTimezone(time.Now(), "Nowhere/Special"); err == nil {
+		t.Errorf("Expected error for an unknown timezone")
+	}
+}
+
+func TestReadPostNameNumbers(t *testing.T) {
+	site, cleanup := newTestSite(t, map[string]string{
+		"_config.yml":                       "",
+		"_posts/2019-05-01-10-20-things.md": "---\n---\nthings"})
+	defer cleanup()
+
+	if len(site.posts) != 1 {
+		t.Fatalf("Expected 1 post got %d", len(site.posts))
+	}
+	if url := site.posts[0].GetUrl(); url != "10-20-things/index.html" {
+		t.Errorf("Expected the post to keep its url [10-20-things/index.html] got [%s]", url)
+	}
+}
+
+func TestParsePostSlug(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jkl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tests := map[string]string{
+		"category: go\n":                    "my-first-post",
+		"category: go\nslug: hello-world\n": "hello-world"}
+
+	for matter, slug := range tests {
+		fn := filepath.Join(dir, "2013-02-14-my-first-post.md")
+		if err := ioutil.WriteFile(fn, []byte("---\n"+matter+"---\nhello"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		post, err := ParsePost(fn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if post.GetSlug() != slug {
+			t.Errorf("Expected post slug [%s] got [%s]", slug, post.GetSlug())
+		}
+		if url := filepath.Join("go", slug, "index.html"); post.GetUrl() != url {
+			t.Errorf("Expected post url [%s] got [%s]", url, post.GetUrl())
+		}
+	}
+}
+
+func TestParsePostPath(t *testing.T) {
+	post, err := parsePost("_posts/2013-02-14-my-first-post.md", "2013-02-14-my-first-post.md", []byte("---\ntitle: First\n---\nhello"), converters, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if post.GetPath() != "_posts/2013-02-14-my-first-post.md" {
+		t.Errorf("Expected post path [_posts/2013-02-14-my-first-post.md] got [%s]", post.GetPath())
+	}
+	if post.GetString("pretty_url") != "my-first-post/" {
+		t.Errorf("Expected post url [my-first-post/] got [%s]", post.GetString("pretty_url"))
+	}
+}
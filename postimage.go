@@ -0,0 +1,103 @@
+package main
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+var MsgCopyImage = "Copying Image: %s"
+
+// A postImage is the image of a post, copied from the source to the post's
+// own directory in the site.
+type postImage struct {
+	post string // Path of the post, relative to the source directory
+	src  string // Path of the image file
+	url  string // Url of the copy, relative to the destination directory
+}
+
+// Helper function that moves the image of each post into the post's own
+// directory in the site, when enabled with post_images: true in the
+// _config.yml, so that everything of a post lives together. The image is
+// found next to the post's source, or else in the post_images_dir:
+//
+//	---
+//	image: hero.jpg
+//	---
+//
+// The image of _posts/2013-02-14-first.md is copied to /first/hero.jpg and
+// the post's image is rewritten to it. Only posts with pretty permalinks,
+// written to a directory of their own, and relative images are moved, and
+// only from within the source directory or the post_images_dir.
+func (s *Site) movePostImages() {
+	s.postImages = nil
+	if !s.Conf.GetBool("post_images", false) {
+		return
+	}
+
+	dirs := []string{}
+	if dir := s.Conf.GetString("post_images_dir"); dir != "" {
+		dirs = append(dirs, joinPath(s.Src, dir))
+	}
+	allowed := append([]string{s.Src}, dirs...)
+	for _, post := range s.posts {
+		image := post.GetString("image")
+		if image == "" || strings.HasPrefix(image, "/") || strings.Contains(image, "://") {
+			continue
+		}
+		if path.Base(post.GetUrl()) != "index.html" {
+			continue
+		}
+
+		candidates := append([]string{filepath.Join(s.Src, filepath.Dir(post.GetPath()))}, dirs...)
+		for _, dir := range candidates {
+			fn := filepath.Join(dir, filepath.FromSlash(image))
+			if !inDirs(fn, allowed) {
+				continue
+			}
+			if fi, err := os.Stat(fn); err != nil || fi.IsDir() {
+				continue
+			}
+			url := path.Join(path.Dir(post.GetUrl()), path.Base(image))
+			if checkUrl(url) != nil {
+				break
+			}
+			s.postImages = append(s.postImages, postImage{post.GetPath(), fn, url})
+			post["image"] = "/" + url
+			break
+		}
+	}
+}
+
+// Helper function that returns true if the file is within one of the
+// directories.
+func inDirs(fn string, dirs []string) bool {
+	for _, dir := range dirs {
+		if rel, err := filepath.Rel(dir, fn); err == nil && !strings.HasPrefix(rel, "..") {
+			return true
+		}
+	}
+	return false
+}
+
+// Helper function that writes the images of the posts to the destination
+// directory.
+func (s *Site) writePostImages() error {
+	for _, img := range s.postImages {
+		if !s.isGenerated(img.post) {
+			continue
+		}
+		f, err := s.destPath(img.url)
+		if err != nil {
+			return err
+		}
+		logf(MsgCopyImage, img.url)
+		if err := s.copyOutput(img.src, f); err != nil {
+			if err := s.buildError(img.post, err); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
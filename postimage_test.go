@@ -0,0 +1,76 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPostImages(t *testing.T) {
+	site, cleanup := newTestSite(t, map[string]string{
+		"_config.yml":                  "post_images: true\npost_images_dir: assets\n",
+		"_layouts/default.html":        "<img src=\"{{.page.image}}\" alt=\"\">{{.content}}",
+		"_posts/hero.jpg":              "hero",
+		"_posts/2013-02-14-first.md":   "---\nimage: hero.jpg\n---\nfirst",
+		"_posts/2013-02-15-second.md":  "---\nimage: covers/second.png\n---\nsecond",
+		"_posts/2013-02-16-third.md":   "---\nimage: hero.jpg\npermalink: /:title.html\n---\nthird",
+		"_posts/2013-02-17-fourth.md":  "---\nimage: /assets/covers/second.png\n---\nfourth",
+		"_posts/2013-02-18-missing.md": "---\nimage: missing.jpg\n---\nmissing",
+		"assets/covers/second.png":     "second"})
+	defer cleanup()
+
+	if err := site.Generate(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]string{
+		"first":   "/first/hero.jpg",
+		"second":  "/second/second.png",
+		"third":   "hero.jpg",
+		"fourth":  "/assets/covers/second.png",
+		"missing": "missing.jpg",
+	}
+	for _, post := range site.posts {
+		if image := post.GetString("image"); image != tests[post.GetSlug()] {
+			t.Errorf("Expected the image of %s to be [%s] got [%s]", post.GetSlug(), tests[post.GetSlug()], image)
+		}
+	}
+	for fn, expected := range map[string]string{
+		"first/hero.jpg":    "hero",
+		"second/second.png": "second",
+		"first/index.html":  "<img src=\"/first/hero.jpg\" alt=\"\"><p>first</p>\n",
+	} {
+		if b, _ := ioutil.ReadFile(filepath.Join(site.Dest, fn)); string(b) != expected {
+			t.Errorf("Expected %s to be [%s] got [%s]", fn, expected, b)
+		}
+	}
+}
+
+func TestPostImagesOutside(t *testing.T) {
+	// a file next to the source directory of the test site
+	f, err := ioutil.TempFile("", "jkl-outside")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	defer os.Remove(f.Name())
+	outside := "../../" + filepath.Base(f.Name())
+
+	site, cleanup := newTestSite(t, map[string]string{
+		"_config.yml":                "post_images: true\npost_images_dir: assets\n",
+		"_layouts/default.html":      "{{.content}}",
+		"_posts/2013-02-14-first.md": "---\nimage: " + outside + "\n---\nfirst",
+		"assets/hero.jpg":            "hero"})
+	defer cleanup()
+
+	if err := site.Generate(); err != nil {
+		t.Fatal(err)
+	}
+	if image := site.posts[0].GetString("image"); image != outside {
+		t.Errorf("Expected the image outside of the source to be left as [%s] got [%s]", outside, image)
+	}
+	if len(site.postImages) != 0 {
+		t.Errorf("Expected no image to be copied, got %v", site.postImages)
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var MsgProcessFile = "Processing File: %s"
+
+// A Processor transforms a static file, such as CoffeeScript or TypeScript,
+// when it is written to the destination directory, and writes the result
+// with a new extension, e.g. ".js".
+type Processor struct {
+	Ext string    // Extension of the output file
+	Fn  Converter // Transforms the content of the file
+}
+
+// Processors registered by file extension. Static files with no registered
+// processor are copied verbatim. Each site processes its files with its own
+// copy of the table, along with the processors in its _config.yml, see
+// newProcessors.
+var processors = map[string]*Processor{}
+
+// RegisterProcessor adds a processor for static files with the given
+// extension, e.g. ".coffee", written with the output extension, e.g. ".js".
+// Any processor already registered for the extension is replaced. It
+// applies to the sites created afterwards.
+func RegisterProcessor(ext, outExt string, fn Converter) {
+	addProcessor(processors, ext, outExt, fn)
+}
+
+// Helper function that adds a processor to the table by extension, with or
+// without their leading dots.
+func addProcessor(table map[string]*Processor, ext, outExt string, fn Converter) {
+	if !strings.HasPrefix(ext, ".") {
+		ext = "." + ext
+	}
+	if !strings.HasPrefix(outExt, ".") {
+		outExt = "." + outExt
+	}
+	table[ext] = &Processor{outExt, fn}
+}
+
+// Helper function that returns the processors of a site: those registered
+// with RegisterProcessor, along with the external processors listed in its
+// _config.yml, which replace them by extension.
+func newProcessors(conf Config) (map[string]*Processor, error) {
+	table := map[string]*Processor{}
+	for ext, p := range processors {
+		table[ext] = p
+	}
+	if err := registerProcessors(conf, table); err != nil {
+		return nil, err
+	}
+	return table, nil
+}
+
+// Helper function that adds the external processors listed in the
+// _config.yml to the table by extension. The command reads the file from its
+// standard input and writes the result to its standard output, for example:
+//
+//	processors:
+//	  coffee:
+//	    command: coffee -c -p -s
+//	    ext: js
+func registerProcessors(conf Config, table map[string]*Processor) error {
+	m, ok := conf.Get("processors").(map[interface{}]interface{})
+	if !ok {
+		return nil
+	}
+	for ext := range m {
+		key := fmt.Sprintf("processors.%v", ext)
+		fields := strings.Fields(conf.GetString(key + ".command"))
+		if len(fields) == 0 {
+			return fmt.Errorf("No command defined for processor: %v", ext)
+		}
+		outExt := conf.GetString(key + ".ext")
+		if outExt == "" {
+			return fmt.Errorf("No output extension defined for processor: %v", ext)
+		}
+		addProcessor(table, fmt.Sprint(ext), outExt, ExternalConverter(fields[0], fields[1:]...))
+	}
+	return nil
+}
+
+// Helper function that runs a static file through its processor, writing
+// the result to the destination directory with the processor's extension.
+func (s *Site) processFile(file string, p *Processor) error {
+	b, err := s.readFile(filepath.Join(s.Src, file))
+	if err != nil {
+		return err
+	}
+	out, err := p.Fn(b)
+	if err != nil {
+		return err
+	}
+
+	to := filepath.Join(s.Dest, replaceExt(file, p.Ext))
+	if err := os.MkdirAll(filepath.Dir(to), 0755); err != nil {
+		return err
+	}
+	return s.writeOutput(to, out, 0644)
+}
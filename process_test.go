@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRegisterProcessors(t *testing.T) {
+	conf, err := parseConfig([]byte("processors:\n  up:\n    command: tr a-z A-Z\n    ext: txt\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	table, err := newProcessors(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p, ok := table[".up"]
+	if !ok || p.Ext != ".txt" {
+		t.Fatalf("Expected processor for .up writing .txt, got %v", p)
+	}
+	if _, ok := processors[".up"]; ok {
+		t.Errorf("Expected the processors of a site to be kept out of the registered processors")
+	}
+
+	for _, src := range []string{
+		"processors:\n  up:\n    ext: txt\n",
+		"processors:\n  up:\n    command: tr a-z A-Z\n"} {
+		conf, _ := parseConfig([]byte(src))
+		if err := registerProcessors(conf, map[string]*Processor{}); err == nil {
+			t.Errorf("Expected error registering incomplete processor [%s]", src)
+		}
+	}
+}
+
+func TestWriteProcessedFiles(t *testing.T) {
+	RegisterProcessor("coffee2", "js", func(b []byte) ([]byte, error) {
+		return bytes.ToUpper(b), nil
+	})
+	defer delete(processors, ".coffee2")
+
+	site, cleanup := newTestSite(t, map[string]string{
+		"_config.yml":    "",
+		"js/app.coffee2": "alert 'hi'",
+		"js/vendor.js":   "var x;"})
+	defer cleanup()
+
+	if err := site.Generate(); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{
+		"js/app.js":    "ALERT 'HI'",
+		"js/vendor.js": "var x;"}
+	for file, content := range expected {
+		b, err := ioutil.ReadFile(filepath.Join(site.Dest, file))
+		if err != nil || string(b) != content {
+			t.Errorf("Expected [%s] as [%s] got [%s] %v", file, content, b, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(site.Dest, "js/app.coffee2")); !os.IsNotExist(err) {
+		t.Errorf("Expected processed file not to be copied verbatim")
+	}
+}
+
+func TestSiteProcessors(t *testing.T) {
+	first, cleanup := newTestSite(t, map[string]string{
+		"_config.yml": "processors:\n  up:\n    command: tr a-z A-Z\n    ext: txt\n",
+		"notes.up":    "hello"})
+	defer cleanup()
+	if _, ok := first.processors[".up"]; !ok {
+		t.Fatalf("Expected the site to have the processor in its _config.yml")
+	}
+
+	second, cleanup2 := newTestSite(t, map[string]string{
+		"_config.yml": "",
+		"notes.up":    "hello"})
+	defer cleanup2()
+	if err := second.Generate(); err != nil {
+		t.Fatal(err)
+	}
+	if b, err := ioutil.ReadFile(filepath.Join(second.Dest, "notes.up")); err != nil || string(b) != "hello" {
+		t.Errorf("Expected the processors of one site not to apply to another, got [%s] %v", b, err)
+	}
+}
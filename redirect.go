@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var MsgRedirectFile = "Generating Redirect: %s"
+
+// Template of the stub written at each of a page's redirect_from paths,
+// sending both browsers and search engines to the page's real url.
+var redirectTemplate = template.Must(template.New("redirect").Parse(`<!DOCTYPE html>
+<html lang="{{.Lang}}">
+<head>
+<meta charset="utf-8">
+<title>Redirecting&hellip;</title>
+<link rel="canonical" href="{{.Url}}">
+<meta http-equiv="refresh" content="0; url={{.Url}}">
+<meta name="robots" content="noindex">
+</head>
+<body>
+<h1>Redirecting&hellip;</h1>
+<a href="{{.Url}}">Click here if you are not redirected.</a>
+<script>location="{{.Url}}"</script>
+</body>
+</html>
+`))
+
+// Helper function that writes a redirect stub at each of the old paths
+// listed in the page's redirect_from front-end matter, pointing to the
+// page's url. The url is absolute when the site's url is configured.
+func (s *Site) writeRedirects(page Page) error {
+	from := page.GetStrings("redirect_from")
+	if len(from) == 0 {
+		return nil
+	}
+
+	data := struct{ Url, Lang string }{s.pageUrl(page), page.GetLang()}
+	var buf bytes.Buffer
+	if err := redirectTemplate.Execute(&buf, data); err != nil {
+		return err
+	}
+
+	for _, path := range from {
+		url := permalinkUrl(path)
+		f, err := s.destPath(url)
+		if err != nil {
+			return fmt.Errorf("redirect_from %s: %s", path, err)
+		}
+		if err := os.MkdirAll(filepath.Dir(f), 0755); err != nil {
+			return err
+		}
+
+		logf(MsgRedirectFile, url)
+		if err := s.writeOutput(f, buf.Bytes(), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Helper function that returns the public url of the page, its permalink
+// prefixed with the site's url and baseurl.
+func (s *Site) pageUrl(page Page) string {
+	return s.siteUrl(page.Permalink())
+}
+
+// Helper function that prefixes a path relative to the root of the site
+// with the site's url and baseurl.
+func (s *Site) siteUrl(path string) string {
+	base := strings.TrimRight(s.Conf.GetString("url"), "/") +
+		strings.TrimRight(s.Conf.GetString("baseurl"), "/")
+	return base + "/" + strings.TrimLeft(path, "/")
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriteRedirects(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jkl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	site := Site{Dest: dir, Conf: Config{"url": "http://example.com", "baseurl": "/blog/"}}
+	page := Page{
+		"id":            "about",
+		"url":           "about/index.html",
+		"redirect_from": []interface{}{"/old/about/", "/about-us.html"}}
+	if err := site.writeRedirects(page); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, file := range []string{"old/about/index.html", "about-us.html"} {
+		b, err := ioutil.ReadFile(filepath.Join(dir, file))
+		if err != nil {
+			t.Errorf("Expected redirect stub at [%s]", file)
+			continue
+		}
+		if !strings.Contains(string(b), `<meta http-equiv="refresh" content="0; url=http://example.com/blog/about/">`) ||
+			!strings.Contains(string(b), `<link rel="canonical" href="http://example.com/blog/about/">`) {
+			t.Errorf("Expected redirect to the page url, got [%s]", b)
+		}
+	}
+
+	page["redirect_from"] = []interface{}{"/../../outside/"}
+	if err := site.writeRedirects(page); err == nil {
+		t.Errorf("Expected redirect outside the destination to be rejected")
+	}
+}
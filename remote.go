@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+var MsgFetchFile = "Fetching Include: %s"
+
+// Default time, in seconds, to wait for a remote include.
+const defaultRemoteTimeout = 10
+
+// A remote include fetched during the build, or the error fetching it.
+type remoteInclude struct {
+	content string
+	err     error
+}
+
+// Helper function that returns the options of remote includes, enabled with
+// include_remote: true in the _config.yml. The timeout, in seconds, may be
+// set with:
+//
+//	include_remote:
+//	  timeout: 5
+func remoteOptions(conf Config) (timeout time.Duration, ok bool) {
+	secs := conf.GetInt("include_remote.timeout", defaultRemoteTimeout)
+	if secs <= 0 {
+		secs = defaultRemoteTimeout
+	}
+	timeout = time.Duration(secs) * time.Second
+
+	switch v := conf.Get("include_remote").(type) {
+	case bool:
+		ok = v
+	case map[interface{}]interface{}:
+		ok = true
+	}
+	return
+}
+
+// Helper function that fetches the url of a remote include, such as a
+// header shared by several sites, and returns its content. Each url is only
+// fetched once per build. A response other than 200 OK fails the page.
+func (s *Site) includeRemote(url string) (string, error) {
+	if inc, ok := s.remotes[url]; ok {
+		return inc.content, inc.err
+	}
+	if s.remotes == nil {
+		s.remotes = map[string]*remoteInclude{}
+	}
+
+	logf(MsgFetchFile, url)
+	content, err := fetchRemote(url, s.remoteTimeout)
+	if err != nil {
+		err = fmt.Errorf("include_remote: %s", err)
+	}
+	s.remotes[url] = &remoteInclude{content, err}
+	return content, err
+}
+
+// Helper function that fetches the body of the url, failing on a response
+// other than 200 OK or once the timeout expires.
+func fetchRemote(url string, timeout time.Duration) (string, error) {
+	client := http.Client{Timeout: timeout}
+	resp, err := client.Get(url)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%s: %s", url, resp.Status)
+	}
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
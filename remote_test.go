@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRemoteOptions(t *testing.T) {
+	tests := []struct {
+		conf    string
+		timeout time.Duration
+		ok      bool
+	}{
+		{"", 10 * time.Second, false},
+		{"include_remote: false", 10 * time.Second, false},
+		{"include_remote: true", 10 * time.Second, true},
+		{"include_remote:\n  timeout: 3", 3 * time.Second, true},
+	}
+	for _, test := range tests {
+		conf, err := parseConfig([]byte(test.conf))
+		if err != nil {
+			t.Fatal(err)
+		}
+		timeout, ok := remoteOptions(conf)
+		if timeout != test.timeout || ok != test.ok {
+			t.Errorf("Expected [%v %v] got [%v %v] for [%s]", test.timeout, test.ok, timeout, ok, test.conf)
+		}
+	}
+}
+
+func TestIncludeRemote(t *testing.T) {
+	hits := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/nav.html" {
+			http.NotFound(w, r)
+			return
+		}
+		hits++
+		fmt.Fprint(w, "<nav>shared</nav>")
+	}))
+	defer ts.Close()
+
+	layout := fmt.Sprintf(`{{include_remote "%s/nav.html"}}{{.content}}`, ts.URL)
+	site, cleanup := newTestSite(t, map[string]string{
+		"_config.yml":           "include_remote: true\n",
+		"_layouts/default.html": layout,
+		"index.html":            "---\n---\nhome",
+		"about.html":            "---\n---\nabout"})
+	defer cleanup()
+
+	if err := site.Generate(); err != nil {
+		t.Fatal(err)
+	}
+	for file, content := range map[string]string{"index.html": "home", "about.html": "about"} {
+		b, _ := ioutil.ReadFile(filepath.Join(site.Dest, file))
+		if string(b) != "<nav>shared</nav>"+content {
+			t.Errorf("Expected remote include in [%s] got [%s]", file, b)
+		}
+	}
+	if hits != 1 {
+		t.Errorf("Expected remote include to be fetched once per build, got %d", hits)
+	}
+
+	// a page fails to build if the include can't be fetched
+	broken, cleanup2 := newTestSite(t, map[string]string{
+		"_config.yml":           "include_remote: true\n",
+		"_layouts/default.html": fmt.Sprintf(`{{include_remote "%s/missing.html"}}`, ts.URL),
+		"index.html":            "---\n---\nhome"})
+	defer cleanup2()
+	if err := broken.Generate(); err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("Expected build error for a missing remote include, got %v", err)
+	}
+}
+
+func TestIncludeRemoteDisabled(t *testing.T) {
+	site, cleanup := newTestSite(t, map[string]string{
+		"_config.yml":           "",
+		"_layouts/default.html": `{{include_remote "http://example.com/nav.html"}}`,
+		"index.html":            "---\n---\nhome"})
+	defer cleanup()
+	if err := site.Generate(); err == nil || !strings.Contains(err.Error(), "not enabled") {
+		t.Errorf("Expected include_remote to fail unless enabled, got %v", err)
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+// Sass output styles supported by the sass compiler.
+var sassStyles = map[string]bool{
+	"expanded":   true,
+	"compressed": true,
+}
+
+// Helper function that builds the command line arguments used to compile a
+// Sass stylesheet. The output style and load paths are read from the sass
+// section of the _config.yml file, for example:
+//
+//	sass:
+//	  style: compressed
+//	  load_paths: [_sass, _vendor/bootstrap]
+//
+// Load paths are relative to the source directory and default to _sass.
+func sassArgs(src string, conf Config, from, to string) ([]string, error) {
+	style := "expanded"
+	if v := conf.GetString("sass.style"); v != "" {
+		if !sassStyles[v] {
+			return nil, fmt.Errorf("Invalid sass style %q. Expecting expanded or compressed", v)
+		}
+		style = v
+	}
+
+	paths := conf.GetStringSlice("sass.load_paths")
+	if paths == nil {
+		paths = []string{"_sass"}
+	}
+
+	args := []string{"--no-source-map", "--style=" + style}
+	for _, path := range paths {
+		args = append(args, "--load-path="+filepath.Join(src, path))
+	}
+	return append(args, from, to), nil
+}
+
+// Helper function that compiles a Sass stylesheet in the source directory
+// to a CSS file of the same name in the destination directory. Partials are
+// resolved via @import from the configured load paths.
+func (s *Site) compileSass(file string) error {
+	from := filepath.Join(s.Src, file)
+	to := filepath.Join(s.Dest, replaceExt(file, ".css"))
+	args, err := sassArgs(s.Src, s.Conf, from, to)
+	if err != nil {
+		return err
+	}
+
+	// the sass command always writes the stylesheet, without a source map
+	s.markWritten(to)
+
+	os.MkdirAll(filepath.Dir(to), 0755)
+	out, err := exec.Command("sass", args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s: %s\n%s", file, err, bytes.TrimSpace(out))
+	}
+	return nil
+}
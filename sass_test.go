@@ -0,0 +1,46 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSassArgs(t *testing.T) {
+	conf, err := parseConfig([]byte("sass:\n  style: compressed\n  load_paths: [_sass, _vendor]\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	args, err := sassArgs("/src", conf, "/src/css/main.scss", "/dest/css/main.css")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"--no-source-map",
+		"--style=compressed",
+		"--load-path=/src/_sass",
+		"--load-path=/src/_vendor",
+		"/src/css/main.scss",
+		"/dest/css/main.css"}
+	if !reflect.DeepEqual(args, expected) {
+		t.Errorf("Expected sass args %v got %v", expected, args)
+	}
+}
+
+func TestSassArgsDefaults(t *testing.T) {
+	args, err := sassArgs("/src", Config{}, "main.scss", "main.css")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"--no-source-map", "--style=expanded", "--load-path=/src/_sass", "main.scss", "main.css"}
+	if !reflect.DeepEqual(args, expected) {
+		t.Errorf("Expected sass args %v got %v", expected, args)
+	}
+}
+
+func TestSassArgsBadStyle(t *testing.T) {
+	conf, _ := parseConfig([]byte("sass:\n  style: nested\n"))
+	if _, err := sassArgs("/src", conf, "main.scss", "main.css"); err == nil {
+		t.Errorf("Expected error for unsupported sass style")
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+var MsgCreateFile = "Creating File: %s"
+
+var ErrNewUsage = errors.New("Usage: jkl new site [--force] DIR")
+
+// Files of a new site, by path relative to the site's directory. The :date
+// in the name of the sample post is replaced with the current date.
+var scaffoldFiles = map[string]string{
+	"_config.yml": `title: My Site
+baseurl: ""
+`,
+	"_layouts/default.html": `<!DOCTYPE html>
+<html lang="{{.page.lang}}">
+<head>
+  <meta charset="utf-8">
+  <title>{{if .page.title}}{{.page.title}} | {{end}}{{.site.title}}</title>
+</head>
+<body>
+  <header><a href="{{.site.baseurl}}/">{{.site.title}}</a></header>
+  {{.content}}
+  <ul>
+  {{range .site.posts}}<li><a href="{{$.site.baseurl}}/{{.pretty_url}}">{{.title}}</a></li>
+  {{end}}</ul>
+</body>
+</html>
+`,
+	"index.md": `---
+title: Home
+---
+Welcome to your new site. Edit index.md to change this page, and add posts
+to the _posts directory.
+`,
+	"_posts/:date-welcome.md": `---
+title: Welcome
+---
+This is your first post. Posts are named after their date and slug, e.g.
+_posts/2013-02-14-my-post.md.
+`,
+	".gitignore": `_site
+`,
+}
+
+// Helper function that runs the new subcommand, e.g. jkl new site DIR.
+func runNew(args []string) error {
+	if len(args) == 0 || args[0] != "site" {
+		return ErrNewUsage
+	}
+
+	flags := flag.NewFlagSet("new", flag.ContinueOnError)
+	force := flags.Bool("force", false, "")
+	flags.Usage = func() {}
+	if err := flags.Parse(args[1:]); err != nil || flags.NArg() != 1 {
+		return ErrNewUsage
+	}
+
+	dir := flags.Arg(0)
+	if err := scaffoldSite(dir, *force); err != nil {
+		return err
+	}
+	fmt.Printf("New site created in %s\n", dir)
+	return nil
+}
+
+// Helper function that creates a minimal working site in the directory,
+// with a _config.yml, a default layout, an index.md page, a sample post and
+// a .gitignore for the generated site. Refuses to write into a directory
+// that is not empty, unless forced, in which case existing files with the
+// same names are overwritten.
+func scaffoldSite(dir string, force bool) error {
+	if entries, err := ioutil.ReadDir(dir); err == nil && len(entries) > 0 && !force {
+		return fmt.Errorf("%s: directory is not empty, use --force to scaffold anyway", dir)
+	}
+
+	date := time.Now().Format("2006-01-02")
+	for name, content := range scaffoldFiles {
+		fn := filepath.Join(dir, filepath.FromSlash(strings.Replace(name, ":date", date, 1)))
+		logf(MsgCreateFile, fn)
+		if err := os.MkdirAll(filepath.Dir(fn), 0755); err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(fn, []byte(content), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestScaffoldSite(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jkl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := scaffoldSite(dir, false); err != nil {
+		t.Fatal(err)
+	}
+	post := filepath.Join(dir, "_posts", time.Now().Format("2006-01-02")+"-welcome.md")
+	if _, err := os.Stat(post); err != nil {
+		t.Errorf("Expected a sample post dated today, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "index.md")); err != nil {
+		t.Errorf("Expected an index.md page, got %v", err)
+	}
+
+	// the new site builds as is
+	site, err := NewSite(dir, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	site.Dest = filepath.Join(dir, "_site")
+	if err := site.Generate(); err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadFile(filepath.Join(site.Dest, "index.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), "<title>Home | My Site</title>") || !strings.Contains(string(b), "<p>Welcome to your new site.") || !strings.Contains(string(b), ">Welcome</a>") {
+		t.Errorf("Expected index page rendered from markdown listing the sample post, got [%s]", b)
+	}
+
+	// a non-empty directory is only scaffolded if forced
+	if err := scaffoldSite(dir, false); err == nil {
+		t.Errorf("Expected error scaffolding a non-empty directory")
+	}
+	if err := scaffoldSite(dir, true); err != nil {
+		t.Errorf("Expected forced scaffold of a non-empty directory, got %v", err)
+	}
+}
+
+func TestRunNewUsage(t *testing.T) {
+	for _, args := range [][]string{{}, {"page", "x"}, {"site"}, {"site", "a", "b"}} {
+		if err := runNew(args); err != ErrNewUsage {
+			t.Errorf("Expected usage error for %v, got %v", args, err)
+		}
+	}
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+var MsgInvalidFile = "Warning: %s"
+
+// A frontMatterSchema is an entry in the front_matter_schema list of the
+// _config.yml file, describing the front-end matter expected of all documents
+// in its scope, the same as the scope of defaults:
+//
+//	front_matter_schema:
+//	  - scope:
+//	      type: posts
+//	    required: [title, layout]
+//	    types:
+//	      title: string
+//	      tags: list
+//	    values:
+//	      categories: [news, tech]
+//
+// Types are one of string, int, number, bool, date, list or map. A value
+// that is a list, such as categories, must only contain allowed values.
+type frontMatterSchema struct {
+	scope    frontMatterDefault
+	required []string
+	types    []schemaRule
+	values   []schemaRule
+}
+
+// A schemaRule is the type, or the allowed values, of a front-end matter key.
+type schemaRule struct {
+	key    string
+	values []string
+}
+
+// Checks if a front-end matter value is of the named type.
+var schemaTypes = map[string]func(interface{}) bool{
+	"string": func(v interface{}) bool {
+		_, ok := v.(string)
+		return ok
+	},
+	"int": func(v interface{}) bool {
+		switch n := v.(type) {
+		case int, int64:
+			return true
+		case float64:
+			return n == float64(int(n))
+		}
+		return false
+	},
+	"number": func(v interface{}) bool {
+		switch v.(type) {
+		case int, int64, float64:
+			return true
+		}
+		return false
+	},
+	"bool": func(v interface{}) bool {
+		_, ok := v.(bool)
+		return ok
+	},
+	"date": func(v interface{}) bool {
+		switch d := v.(type) {
+		case time.Time:
+			return true
+		case string:
+			for _, layout := range []string{"2006-01-02", "2006-01-02 15:04:05", time.RFC3339} {
+				if _, err := time.Parse(layout, d); err == nil {
+					return true
+				}
+			}
+		}
+		return false
+	},
+	"list": func(v interface{}) bool {
+		switch v.(type) {
+		case []interface{}, []string:
+			return true
+		}
+		return false
+	},
+	"map": func(v interface{}) bool {
+		switch v.(type) {
+		case map[interface{}]interface{}, map[string]interface{}:
+			return true
+		}
+		return false
+	},
+}
+
+// Helper function that parses the front_matter_schema list from the
+// _config.yml file. Unknown types are reported at once, rather than for
+// every document.
+func parseSchema(conf Config) ([]*frontMatterSchema, error) {
+	list, ok := conf.Get("front_matter_schema").([]interface{})
+	if !ok {
+		return nil, nil
+	}
+
+	schema := []*frontMatterSchema{}
+	for i, item := range list {
+		entry, ok := item.(map[interface{}]interface{})
+		if !ok {
+			return nil, fmt.Errorf("Invalid front_matter_schema entry %d. Expecting scope and rules", i)
+		}
+		sc := frontMatterSchema{}
+		if scope, ok := entry["scope"].(map[interface{}]interface{}); ok {
+			sc.scope.path, _ = scope["path"].(string)
+			sc.scope.typ, _ = scope["type"].(string)
+		}
+		rules := Config{}
+		for key, val := range entry {
+			rules[fmt.Sprint(key)] = val
+		}
+		sc.required = rules.GetStringSlice("required")
+		if types, ok := entry["types"].(map[interface{}]interface{}); ok {
+			for key, typ := range types {
+				if _, ok := schemaTypes[fmt.Sprint(typ)]; !ok {
+					return nil, fmt.Errorf("Unknown type %v for %v in front_matter_schema entry %d", typ, key, i)
+				}
+				sc.types = append(sc.types, schemaRule{fmt.Sprint(key), []string{fmt.Sprint(typ)}})
+			}
+		}
+		if values, ok := entry["values"].(map[interface{}]interface{}); ok {
+			for key := range values {
+				sc.values = append(sc.values, schemaRule{fmt.Sprint(key), rules.GetStringSlice(fmt.Sprintf("values.%v", key))})
+			}
+		}
+		sort.Sort(byKey(sc.types))
+		sort.Sort(byKey(sc.values))
+		schema = append(schema, &sc)
+	}
+	return schema, nil
+}
+
+// Helper function that returns every way in which the page doesn't match the
+// schema, if any.
+func (sc *frontMatterSchema) validate(page Page) []string {
+	violations := []string{}
+	for _, key := range sc.required {
+		if v, ok := page[key]; !ok || v == nil || v == "" {
+			violations = append(violations, fmt.Sprintf("missing required key %s", key))
+		}
+	}
+	for _, rule := range sc.types {
+		typ := rule.values[0]
+		if v, ok := page[rule.key]; ok && v != nil && !schemaTypes[typ](v) {
+			violations = append(violations, fmt.Sprintf("%s must be of type %s, got %v", rule.key, typ, v))
+		}
+	}
+	for _, rule := range sc.values {
+		for _, v := range Config(page).GetStringSlice(rule.key) {
+			if !contains(rule.values, v) {
+				violations = append(violations, fmt.Sprintf("%s must be one of %s, got %s", rule.key, strings.Join(rule.values, ", "), v))
+			}
+		}
+	}
+	return violations
+}
+
+// Helper function that validates the front-end matter of all pages, posts
+// and documents against the schemas in scope. Every violation is printed as a
+// warning, unless validate_front_matter is enabled, in which case the build
+// fails listing every file that doesn't match.
+func (s *Site) validateFrontMatter() error {
+	if len(s.schema) == 0 {
+		return nil
+	}
+
+	var errs BuildErrors
+	types := map[string][]Page{"pages": s.pages, "posts": s.posts}
+	for _, c := range s.collections {
+		types[c.name] = c.docs
+	}
+	for typ, pages := range types {
+		for _, page := range pages {
+			violations := []string{}
+			for _, sc := range s.schema {
+				if sc.scope.matches(page.GetPath(), typ) {
+					violations = append(violations, sc.validate(page)...)
+				}
+			}
+			if len(violations) > 0 {
+				errs = append(errs, newBuildError(page.GetPath(), errors.New(strings.Join(violations, "; "))))
+			}
+		}
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+	sort.Sort(byPath(errs))
+	if s.Conf.GetBool("validate_front_matter", false) {
+		return errs
+	}
+	for _, err := range errs {
+		fmt.Printf(MsgInvalidFile+"\n", err)
+	}
+	return nil
+}
+
+// byPath sorts build errors by the path of their file.
+type byPath BuildErrors
+
+func (e byPath) Len() int           { return len(e) }
+func (e byPath) Swap(i, j int)      { e[i], e[j] = e[j], e[i] }
+func (e byPath) Less(i, j int) bool { return e[i].Path < e[j].Path }
+
+// byKey sorts schema rules by their key.
+type byKey []schemaRule
+
+func (r byKey) Len() int           { return len(r) }
+func (r byKey) Swap(i, j int)      { r[i], r[j] = r[j], r[i] }
+func (r byKey) Less(i, j int) bool { return r[i].key < r[j].key }
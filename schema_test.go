@@ -0,0 +1,71 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testSchema = `front_matter_schema:
+  - scope:
+      type: posts
+    required: [description]
+    types:
+      tags: list
+      weight: int
+    values:
+      categories: [news, tech]
+`
+
+func TestValidateFrontMatter(t *testing.T) {
+	files := map[string]string{
+		"_config.yml":                    testSchema,
+		"about.html":                     "---\n---\nabout",
+		"_posts/2013-02-14-good.md":      "---\ntitle: Good\ndescription: Fine\ntags: [go]\ncategories: [news]\nweight: 2\n---\ngood",
+		"_posts/2013-02-15-untitled.md":  "---\ncategory: sports\n---\nuntitled",
+		"_posts/2013-02-16-bad-types.md": "---\ntitle: Bad\ndescription: Wrong types\ntags: go\nweight: 1.5\n---\nbad",
+	}
+
+	// without validate_front_matter, violations are only warnings
+	site, cleanup := newTestSite(t, files)
+	if len(site.posts) != 3 {
+		t.Errorf("Expected posts to be read despite violations, got %d", len(site.posts))
+	}
+	cleanup()
+
+	dir, _ := ioutil.TempDir("", "jkl")
+	defer os.RemoveAll(dir)
+	for name, content := range files {
+		os.MkdirAll(filepath.Join(dir, filepath.Dir(name)), 0755)
+		ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644)
+	}
+	_, err := NewSite(dir, filepath.Join(dir, "_site"), Config{"validate_front_matter": true})
+	errs, ok := err.(BuildErrors)
+	if !ok {
+		t.Fatalf("Expected build errors for the violations, got %v", err)
+	}
+	expected := []string{
+		"_posts/2013-02-15-untitled.md: missing required key description; categories must be one of news, tech, got sports",
+		"_posts/2013-02-16-bad-types.md: tags must be of type list, got go; weight must be of type int, got 1.5",
+	}
+	if len(errs) != len(expected) {
+		t.Fatalf("Expected %d files to fail, got %s", len(expected), errs)
+	}
+	for i, err := range errs {
+		if err.Error() != expected[i] {
+			t.Errorf("Expected error [%s] got [%s]", expected[i], err)
+		}
+	}
+}
+
+func TestParseSchemaUnknownType(t *testing.T) {
+	conf, err := parseConfig([]byte("front_matter_schema:\n  - types:\n      title: text\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := parseSchema(conf); err == nil || !strings.Contains(err.Error(), "Unknown type text") {
+		t.Errorf("Expected unknown types to be reported, got %v", err)
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"strings"
+)
+
+// Helper function that returns the Open Graph and Twitter card meta tags of
+// the page, for the {{seo}} template function. The title, description and
+// image are taken from the front-end matter, falling back to those of the
+// site, and relative urls are made absolute with the site's url. The image
+// of the site is its default_image, or else its image. Without an image the
+// image tags are left out. The canonical link is the page's canonical_url,
+// if any, such as that of the post an AMP version is a copy of.
+func (s *Site) seo(page Page) string {
+	title := page.GetTitle()
+	siteTitle := s.Conf.GetString("title")
+	if title == "" {
+		title = siteTitle
+	}
+	description := page.GetString("description")
+	if description == "" {
+		description = s.Conf.GetString("description")
+	}
+	image := page.GetString("image")
+	if image == "" {
+		image = s.Conf.GetString("default_image")
+	}
+	if image == "" {
+		image = s.Conf.GetString("image")
+	}
+
+	typ := "website"
+	if _, ok := page["date"]; ok {
+		typ = "article"
+	}
+	card := "summary"
+	if image != "" {
+		card = "summary_large_image"
+	}
+
+	var buf bytes.Buffer
+	meta := func(attr, name, content string) {
+		if content != "" {
+			fmt.Fprintf(&buf, "<meta %s=\"%s\" content=\"%s\">\n", attr, name, html.EscapeString(content))
+		}
+	}
+	if title != "" {
+		fmt.Fprintf(&buf, "<title>%s</title>\n", html.EscapeString(title))
+	}
+	meta("name", "description", description)
+	canonical := page.GetString("canonical_url")
+	if canonical == "" {
+		canonical = s.pageUrl(page)
+	}
+	fmt.Fprintf(&buf, "<link rel=\"canonical\" href=\"%s\">\n", html.EscapeString(canonical))
+	meta("property", "og:title", title)
+	meta("property", "og:description", description)
+	meta("property", "og:url", s.pageUrl(page))
+	meta("property", "og:type", typ)
+	meta("property", "og:site_name", siteTitle)
+	meta("property", "og:locale", strings.Replace(page.GetLang(), "-", "_", -1))
+	if image != "" {
+		meta("property", "og:image", s.absUrl(image))
+	}
+	meta("name", "twitter:card", card)
+	if twitter := s.Conf.GetString("twitter"); twitter != "" {
+		meta("name", "twitter:site", "@"+strings.TrimPrefix(twitter, "@"))
+	}
+	meta("name", "twitter:title", title)
+	meta("name", "twitter:description", description)
+	if image != "" {
+		meta("name", "twitter:image", s.absUrl(image))
+	}
+	return buf.String()
+}
+
+// Helper function that makes a url relative to the site absolute, prefixing
+// it with the site's url and baseurl. Absolute urls are returned unchanged.
+func (s *Site) absUrl(url string) string {
+	if strings.Contains(url, "://") || strings.HasPrefix(url, "//") {
+		return url
+	}
+	return s.siteUrl(url)
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSeo(t *testing.T) {
+	site := Site{Conf: Config{
+		"title":       "Blog",
+		"description": "A blog about <Go>",
+		"image":       "/img/default.png",
+		"twitter":     "jkl",
+		"url":         "http://example.com"}}
+
+	post := Page{
+		"title": `Tom & "Jerry"`,
+		"image": "img/post.png",
+		"lang":  "pt-BR",
+		"date":  time.Now(),
+		"url":   "2013/02/14/tom/index.html"}
+	tags := site.seo(post)
+	expected := []string{
+		"<title>Tom &amp; &#34;Jerry&#34;</title>\n",
+		`<meta name="description" content="A blog about &lt;Go&gt;">`,
+		`<link rel="canonical" href="http://example.com/2013/02/14/tom/">`,
+		`<meta property="og:title" content="Tom &amp; &#34;Jerry&#34;">`,
+		`<meta property="og:type" content="article">`,
+		`<meta property="og:locale" content="pt_BR">`,
+		`<meta property="og:image" content="http://example.com/img/post.png">`,
+		`<meta name="twitter:card" content="summary_large_image">`,
+		`<meta name="twitter:site" content="@jkl">`,
+	}
+	for _, tag := range expected {
+		if !strings.Contains(tags, tag) {
+			t.Errorf("Expected [%s] in seo tags, got [%s]", tag, tags)
+		}
+	}
+
+	page := Page{"url": "about/index.html"}
+	site.Conf["image"] = "https://cdn.example.com/card.png"
+	tags = site.seo(page)
+	expected = []string{
+		"<title>Blog</title>\n",
+		`<meta property="og:type" content="website">`,
+		`<meta property="og:image" content="https://cdn.example.com/card.png">`,
+	}
+	for _, tag := range expected {
+		if !strings.Contains(tags, tag) {
+			t.Errorf("Expected [%s] in seo tags, got [%s]", tag, tags)
+		}
+	}
+
+	// the default_image of the site takes precedence over its image
+	site.Conf["default_image"] = "/img/default.png"
+	tags = site.seo(page)
+	for _, tag := range []string{
+		`<meta property="og:image" content="http://example.com/img/default.png">`,
+		`<meta name="twitter:image" content="http://example.com/img/default.png">`,
+	} {
+		if !strings.Contains(tags, tag) {
+			t.Errorf("Expected [%s] in seo tags, got [%s]", tag, tags)
+		}
+	}
+
+	// without any image the image tags are left out
+	delete(site.Conf, "default_image")
+	delete(site.Conf, "image")
+	tags = site.seo(page)
+	if strings.Contains(tags, "image\"") || !strings.Contains(tags, `<meta name="twitter:card" content="summary">`) {
+		t.Errorf("Expected no image tags without an image, got [%s]", tags)
+	}
+}
@@ -0,0 +1,319 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"mime"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// Serve starts a web server at the given address that hosts the site's
+// destination directory over HTTP.
+func Serve(site *Site, addr string) error {
+	fmt.Printf("Starting server on port %s\n", addr)
+	return http.ListenAndServe(addr, siteHandler(site))
+}
+
+var ErrTLSPair = errors.New("Expecting both a certificate and a key file for https, or neither")
+
+// ServeTLS starts a web server at the given address that hosts the site's
+// destination directory over HTTPS. If neither a certificate nor a key file
+// is provided a self-signed certificate for localhost is generated and cached
+// in the user's cache directory, so the browser's trust prompt is not
+// triggered every time the server is started.
+func ServeTLS(site *Site, addr, certFile, keyFile string) error {
+	if (certFile == "") != (keyFile == "") {
+		return ErrTLSPair
+	}
+	if certFile == "" {
+		cache, err := os.UserCacheDir()
+		if err != nil {
+			return err
+		}
+		certFile, keyFile, err = selfSignedCert(filepath.Join(cache, "jkl"))
+		if err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("Starting server on port %s (https)\n", addr)
+	return http.ListenAndServeTLS(addr, certFile, keyFile, siteHandler(site))
+}
+
+var MsgOpenBrowser = "Could not open the browser: %s"
+
+// Helper function that checks that the site was already built to the
+// destination directory, to serve it as is.
+func checkBuilt(dest string) error {
+	fi, err := os.Stat(dest)
+	switch {
+	case os.IsNotExist(err):
+		return fmt.Errorf("%s does not exist. Build the site first, by running jkl without --no-build", dest)
+	case err != nil:
+		return err
+	case !fi.IsDir():
+		return fmt.Errorf("%s is not a directory", dest)
+	}
+	return nil
+}
+
+// Helper function that returns the command that opens the url in the default
+// browser of the operating system.
+func browserCommand(goos, url string) *exec.Cmd {
+	switch goos {
+	case "darwin":
+		return exec.Command("open", url)
+	case "windows":
+		return exec.Command("cmd", "/c", "start", "", url)
+	}
+	return exec.Command("xdg-open", url)
+}
+
+// Helper function that opens the url in the default browser, once the
+// server listening on the address accepts connections. A browser that can't
+// be opened is only reported, the site is still served.
+func openBrowser(addr, url string) {
+	host, port, err := net.SplitHostPort(addr)
+	if err == nil && (host == "" || host == "0.0.0.0" || host == "::") {
+		addr = net.JoinHostPort("localhost", port)
+	}
+	for i := 0; i < 50; i++ {
+		if conn, err := net.Dial("tcp", addr); err == nil {
+			conn.Close()
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	if err := browserCommand(runtime.GOOS, url).Start(); err != nil {
+		fmt.Printf(MsgOpenBrowser+"\n", err)
+	}
+}
+
+// Helper function that returns the url of the local server listening on the
+// address, e.g. http://localhost:4000 for :4000, used as the site's url when
+// previewing so that absolute links point to the preview.
+func devUrl(addr string, tls bool) string {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		host, port = addr, ""
+	}
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		host = "localhost"
+	}
+	scheme := "http"
+	if tls {
+		scheme = "https"
+	}
+	if port == "" {
+		return scheme + "://" + host
+	}
+	return scheme + "://" + net.JoinHostPort(host, port)
+}
+
+// Helper function that creates the handler used to serve the site's
+// destination directory from the filesystem. When live reload is enabled
+// the reload script is injected into every html page.
+func siteHandler(site *Site) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if reloader != nil && r.URL.Path == liveReloadPath {
+			reloader.ServeHTTP(w, r)
+			return
+		}
+
+		mu.RLock()
+		defer mu.RUnlock()
+
+		base := site.Conf.GetString("baseurl")
+		path := r.URL.Path
+		pathList := filepath.SplitList(path)
+		if len(pathList) > 0 && pathList[0] == base {
+			path = strings.Join(pathList[len(pathList):], "/")
+		}
+
+		path = filepath.Clean(path)
+		path = filepath.Join(site.Dest, path)
+
+		// Respond with the site's 404 page, if it has one, when the
+		// requested file does not exist
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			if html, err := ioutil.ReadFile(filepath.Join(site.Dest, "404.html")); err == nil {
+				if reloader != nil {
+					html = injectScript(html, liveReloadScript)
+				}
+				w.Header().Set("Content-Type", "text/html; charset=utf-8")
+				w.WriteHeader(http.StatusNotFound)
+				w.Write(html)
+				return
+			}
+		}
+
+		if reloader != nil {
+			if html, ok := readHtml(path, r.URL.Path, site.mimeType); ok {
+				html = injectScript(html, liveReloadScript)
+				w.Header().Set("Content-Type", "text/html; charset=utf-8")
+				w.Header().Set("ETag", fmt.Sprintf(`"%x"`, sha1.Sum(html)))
+				http.ServeContent(w, r, path, time.Time{}, bytes.NewReader(html))
+				return
+			}
+		}
+		serveFile(w, r, path, site.mimeType)
+	})
+}
+
+// Helper function that returns the content type of a file by its extension.
+// The mime_types in the _config.yml take precedence over the types known to
+// the system, which may be missing or wrong for extensions such as .wasm or
+// .avif, for example:
+//
+//	mime_types:
+//	  .webmanifest: application/manifest+json
+//	  avif: image/avif
+//
+// Files of an unknown type are served as application/octet-stream.
+func (s *Site) mimeType(fn string) string {
+	ext := strings.ToLower(filepath.Ext(fn))
+	if types, ok := s.Conf.Get("mime_types").(map[interface{}]interface{}); ok {
+		for key, typ := range types {
+			if "."+strings.TrimPrefix(strings.ToLower(fmt.Sprint(key)), ".") == ext {
+				return fmt.Sprint(typ)
+			}
+		}
+	}
+	if typ := mime.TypeByExtension(ext); typ != "" {
+		return typ
+	}
+	return "application/octet-stream"
+}
+
+// Helper function that serves a file with an ETag derived from its size and
+// modification time, so that a browser re-requesting an unchanged file, for
+// example after a live reload, gets a 304 Not Modified. Directories are
+// resolved to their index.html file, and anything else, such as redirecting
+// to the directory with a trailing slash, is left to http.ServeFile. Files
+// are served with the content type returned by typ, given their path.
+func serveFile(w http.ResponseWriter, r *http.Request, path string, typ func(string) string) {
+	if fi, err := os.Stat(path); err == nil && fi.IsDir() && strings.HasSuffix(r.URL.Path, "/") {
+		if _, err := os.Stat(filepath.Join(path, "index.html")); err == nil {
+			path = filepath.Join(path, "index.html")
+		}
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		http.ServeFile(w, r, path)
+		return
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil || fi.IsDir() {
+		http.ServeFile(w, r, path)
+		return
+	}
+	w.Header().Set("Content-Type", typ(path))
+	w.Header().Set("ETag", fmt.Sprintf(`"%x-%x"`, fi.ModTime().UnixNano(), fi.Size()))
+	http.ServeContent(w, r, fi.Name(), fi.ModTime(), f)
+}
+
+// Helper function that reads the html page served for the file path,
+// resolving directories to their index.html file. The file is an html page
+// if its content type, as returned by typ, is text/html. Returns false if
+// the file is not an html page, or if the request should be redirected to
+// the directory with a trailing slash.
+func readHtml(path, urlPath string, typ func(string) string) ([]byte, bool) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, false
+	}
+	if fi.IsDir() {
+		if !strings.HasSuffix(urlPath, "/") {
+			return nil, false
+		}
+		path = filepath.Join(path, "index.html")
+	}
+	if !strings.HasPrefix(typ(path), "text/html") {
+		return nil, false
+	}
+
+	html, err := ioutil.ReadFile(path)
+	return html, err == nil
+}
+
+// Helper function that returns the paths of a self-signed certificate and
+// key for localhost stored in the given directory. The certificate is only
+// generated if it does not exist yet, or has expired.
+func selfSignedCert(dir string) (certFile, keyFile string, err error) {
+	certFile = filepath.Join(dir, "localhost.crt")
+	keyFile = filepath.Join(dir, "localhost.key")
+
+	// re-use the cached certificate while it is still valid
+	if pair, err := tls.LoadX509KeyPair(certFile, keyFile); err == nil {
+		if cert, err := x509.ParseCertificate(pair.Certificate[0]); err == nil && time.Now().Before(cert.NotAfter) {
+			return certFile, keyFile, nil
+		}
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return
+	}
+	tmpl := x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{Organization: []string{"jkl development server"}},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().AddDate(1, 0, 0),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		DNSNames:              []string{"localhost"},
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &tmpl, &tmpl, &key.PublicKey, key)
+	if err != nil {
+		return
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return
+	}
+
+	if err = os.MkdirAll(dir, 0700); err != nil {
+		return
+	}
+	if err = writePem(certFile, "CERTIFICATE", der, 0644); err != nil {
+		return
+	}
+	err = writePem(keyFile, "EC PRIVATE KEY", keyDer, 0600)
+	return
+}
+
+// Helper function that writes a PEM encoded block to a file.
+func writePem(fn, typ string, b []byte, perm os.FileMode) error {
+	f, err := os.OpenFile(fn, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return pem.Encode(f, &pem.Block{Type: typ, Bytes: b})
+}
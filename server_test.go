@@ -0,0 +1,240 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSelfSignedCert(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jkl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	certFile, keyFile, err := selfSignedCert(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := ioutil.ReadFile(certFile)
+	if !bytes.HasPrefix(cert, []byte("-----BEGIN CERTIFICATE-----")) {
+		t.Errorf("Expected PEM encoded certificate in [%s]", certFile)
+	}
+
+	// the cached certificate should be re-used on subsequent runs
+	certFile2, keyFile2, err := selfSignedCert(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert2, _ := ioutil.ReadFile(certFile2)
+	if certFile != certFile2 || keyFile != keyFile2 || !bytes.Equal(cert, cert2) {
+		t.Errorf("Expected cached certificate to be re-used")
+	}
+}
+
+func TestServeTLSPair(t *testing.T) {
+	site := &Site{Conf: Config{}}
+	for _, files := range [][2]string{{"cert.pem", ""}, {"", "key.pem"}} {
+		if err := ServeTLS(site, "localhost:0", files[0], files[1]); err != ErrTLSPair {
+			t.Errorf("Expected ErrTLSPair for %v got %v", files, err)
+		}
+	}
+}
+
+func TestSiteHandler(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jkl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ioutil.WriteFile(filepath.Join(dir, "index.html"), []byte("hello"), 0644)
+
+	site := &Site{Dest: dir, Conf: Config{}}
+	w := httptest.NewRecorder()
+	siteHandler(site).ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if w.Code != http.StatusOK || w.Body.String() != "hello" {
+		t.Errorf("Expected index.html to be served, got %d [%s]", w.Code, w.Body.String())
+	}
+}
+
+func TestSiteHandlerETag(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jkl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ioutil.WriteFile(filepath.Join(dir, "index.html"), []byte("<body>hello</body>"), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "style.css"), []byte("body {}"), 0644)
+
+	site := &Site{Dest: dir, Conf: Config{}}
+	get := func(path, etag string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", path, nil)
+		if etag != "" {
+			r.Header.Set("If-None-Match", etag)
+		}
+		w := httptest.NewRecorder()
+		siteHandler(site).ServeHTTP(w, r)
+		return w
+	}
+
+	// static files, and html with the live reload script injected
+	for _, live := range []bool{false, true} {
+		if live {
+			reloader = newLiveReloader()
+		}
+		for _, path := range []string{"/style.css", "/"} {
+			w := get(path, "")
+			etag := w.Header().Get("ETag")
+			if w.Code != http.StatusOK || etag == "" {
+				t.Fatalf("Expected 200 with an ETag for %s, got %d [%s]", path, w.Code, etag)
+			}
+			if w = get(path, etag); w.Code != http.StatusNotModified {
+				t.Errorf("Expected 304 for unchanged %s, got %d", path, w.Code)
+			}
+		}
+		reloader = nil
+	}
+
+	// the injected html gets a new ETag when the page changes
+	reloader = newLiveReloader()
+	defer func() { reloader = nil }()
+	etag := get("/", "").Header().Get("ETag")
+	ioutil.WriteFile(filepath.Join(dir, "index.html"), []byte("<body>changed</body>"), 0644)
+	w := get("/", etag)
+	if w.Code != http.StatusOK || w.Header().Get("ETag") == etag {
+		t.Errorf("Expected changed page to be served with a new ETag, got %d", w.Code)
+	}
+	if !strings.Contains(w.Body.String(), "changed") || !strings.Contains(w.Body.String(), liveReloadPath) {
+		t.Errorf("Expected changed page with live reload script, got [%s]", w.Body.String())
+	}
+}
+
+func TestSiteHandlerNotFound(t *testing.T) {
+	site, cleanup := newTestSite(t, map[string]string{
+		"_config.yml":           "",
+		"_layouts/default.html": "<html>{{.content}}</html>",
+		"404.html":              "---\ntitle: Not Found\npermalink: /404.html\n---\n<h1>{{.page.title}}</h1>"})
+	defer cleanup()
+	if err := site.Generate(); err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	siteHandler(site).ServeHTTP(w, httptest.NewRequest("GET", "/missing/page.html", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 got %d", w.Code)
+	}
+	if body := w.Body.String(); body != "<html><h1>Not Found</h1></html>" {
+		t.Errorf("Expected 404 page rendered through its layout, got [%s]", body)
+	}
+
+	// without a 404 page the default response is used
+	os.Remove(filepath.Join(site.Dest, "404.html"))
+	w = httptest.NewRecorder()
+	siteHandler(site).ServeHTTP(w, httptest.NewRequest("GET", "/missing/page.html", nil))
+	if w.Code != http.StatusNotFound || !strings.Contains(w.Body.String(), "404 page not found") {
+		t.Errorf("Expected default 404 response, got %d [%s]", w.Code, w.Body.String())
+	}
+}
+
+func TestDevUrl(t *testing.T) {
+	tests := []struct {
+		addr     string
+		tls      bool
+		expected string
+	}{
+		{":4000", false, "http://localhost:4000"},
+		{":4443", true, "https://localhost:4443"},
+		{"0.0.0.0:8080", false, "http://localhost:8080"},
+		{"192.168.1.5:4000", false, "http://192.168.1.5:4000"},
+		{"[::1]:4000", false, "http://[::1]:4000"},
+	}
+	for _, test := range tests {
+		if url := devUrl(test.addr, test.tls); url != test.expected {
+			t.Errorf("Expected dev url [%s] got [%s] for [%s]", test.expected, url, test.addr)
+		}
+	}
+}
+
+func TestSiteHandlerMimeTypes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jkl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, name := range []string{"app.webmanifest", "module.wasm", "photo.avif", "style.css", "data.jkl-unknown"} {
+		ioutil.WriteFile(filepath.Join(dir, name), []byte("x"), 0644)
+	}
+
+	conf, err := parseConfig([]byte("mime_types:\n  .webmanifest: application/manifest+json\n  avif: image/avif\n  WASM: application/wasm\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	site := &Site{Dest: dir, Conf: conf}
+	tests := map[string]string{
+		"/app.webmanifest":  "application/manifest+json",
+		"/module.wasm":      "application/wasm",
+		"/photo.avif":       "image/avif",
+		"/style.css":        "text/css; charset=utf-8",
+		"/data.jkl-unknown": "application/octet-stream",
+	}
+	for path, typ := range tests {
+		w := httptest.NewRecorder()
+		siteHandler(site).ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		if ct := w.Header().Get("Content-Type"); ct != typ {
+			t.Errorf("Expected content type [%s] for [%s] got [%s]", typ, path, ct)
+		}
+	}
+
+	// the live reload script is injected into pages served as html
+	ioutil.WriteFile(filepath.Join(dir, "page.jkl-html"), []byte("<body>hello</body>"), 0644)
+	site.Conf["mime_types"].(map[interface{}]interface{})["jkl-html"] = "text/html"
+	reloader = newLiveReloader()
+	defer func() { reloader = nil }()
+	w := httptest.NewRecorder()
+	siteHandler(site).ServeHTTP(w, httptest.NewRequest("GET", "/page.jkl-html", nil))
+	if body := w.Body.String(); !strings.Contains(body, liveReloadPath) {
+		t.Errorf("Expected live reload script in [/page.jkl-html] got [%s]", body)
+	}
+}
+
+func TestBrowserCommand(t *testing.T) {
+	tests := map[string][]string{
+		"linux":   {"xdg-open", "http://localhost:4000/"},
+		"freebsd": {"xdg-open", "http://localhost:4000/"},
+		"darwin":  {"open", "http://localhost:4000/"},
+		"windows": {"cmd", "/c", "start", "", "http://localhost:4000/"},
+	}
+	for goos, args := range tests {
+		if cmd := browserCommand(goos, "http://localhost:4000/"); !reflect.DeepEqual(cmd.Args, args) {
+			t.Errorf("Expected %s to open the browser with %q got %q", goos, args, cmd.Args)
+		}
+	}
+}
+
+func TestCheckBuilt(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jkl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ioutil.WriteFile(filepath.Join(dir, "file"), nil, 0644)
+
+	if err := checkBuilt(dir); err != nil {
+		t.Errorf("Expected a built site to be served, got %s", err)
+	}
+	err = checkBuilt(filepath.Join(dir, "_site"))
+	if err == nil || !strings.Contains(err.Error(), "Build the site first") {
+		t.Errorf("Expected a missing site to be reported, got %v", err)
+	}
+	if err := checkBuilt(filepath.Join(dir, "file")); err == nil {
+		t.Errorf("Expected a file not to be served as the site")
+	}
+}
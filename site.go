@@ -2,17 +2,40 @@ package main
 
 import (
 	"bytes"
+	"encoding/xml"
 	"fmt"
+	"golang.org/x/text/encoding"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
 	"text/template"
 	"time"
 )
 
+// Default directory where Jekyll will write files to, relative to the
+// working directory.
+const defaultDest = "_site"
+
+// Default number of words in the plain text excerpt of pages and posts.
+const defaultExcerptLength = 50
+
+// Default reading speed, in words per minute, of the reading time of pages
+// and posts.
+const defaultWordsPerMinute = 200
+
+// Default language of the site, used for the lang attribute of pages.
+const defaultLang = "en"
+
 var (
+	MsgCompileFile  = "Compiling Sass: %s"
 	MsgCopyingFile  = "Copying File: %s"
 	MsgGenerateFile = "Generating Page: %s"
+	MsgGzipFile     = "Compressing: %s"
+	MsgLimitPosts   = "Warning: only generating the %d most recent of %d posts, don't deploy this site"
+	MsgSkipFile     = "Warning: skipping %s"
 	MsgUploadFile   = "Uploading: %s"
 	MsgUsingConfig  = "Loading Config: %s"
 )
@@ -22,26 +45,114 @@ type Site struct {
 	Dest string // Directory where Jekyll will write files to
 	Conf Config // Configuration date from the _config.yml file
 
+	Mirrors []string // Additional directories the site is written to
+
 	posts []Page             // Posts thet need to be generated
 	pages []Page             // Pages that need to be generated
 	files []string           // Static files to get copied to the destination
 	templ *template.Template // Compiled templates
+
+	layouts []string         // Templates (_layouts, _includes) to compile
+	funcs   template.FuncMap // Template functions added with RegisterFunc
+	liquid  map[string]bool  // Names of the template functions, with jekyll_compat
+
+	urlGenerator func(Page) (string, error) // Permalinks set with SetUrlGenerator
+	postImages   []postImage                // Images moved to the directory of their post
+
+	limit chan struct{} // Files being generated at once, see jobs
+	mu    sync.Mutex    // Guards written and errs while generating in parallel
+
+	remotes       map[string]*remoteInclude // Remote includes fetched during the build
+	remoteTimeout time.Duration             // Time to wait for a remote include
+	written       map[string]bool           // Files written by the build, when pruning the files left over
+
+	markdownInline bool // True if markdownify renders inline by default
+
+	enc           encoding.Encoding                 // Encoding of source files, nil for UTF-8
+	converters    map[string]Converter              // Converters of pages by extension, see newConverters
+	processors    map[string]*Processor             // Processors of static files by extension, see newProcessors
+	defaults      []*frontMatterDefault             // Front-end matter defaults
+	schema        []*frontMatterSchema              // Front-end matter expected of pages and posts
+	collections   []*collection                     // Collections of documents, such as _docs
+	preprocessors []func(*Page) error               // Transform pages before rendering
+	locales       map[string]map[string]interface{} // Translation tables by language
+	data          map[string]interface{}            // Data files by name
+	parseErrs     BuildErrors                       // Pages and posts that could not be parsed
+	errs          BuildErrors                       // Files that could not be generated
 }
 
-func NewSite(src, dest string) (*Site, error) {
+// NewSite parses the _config.yml file in the source directory and reads all
+// posts, pages, templates and static files. Any flags, such as those provided
+// in the cli args, take precedence over the values in the _config.yml file.
+//
+// The source and destination directories are resolved in the same order: a
+// non-empty src or dest argument wins over the source and destination in the
+// _config.yml, which in turn win over the defaults. See resolvePaths.
+func NewSite(src, dest string, flags Config) (*Site, error) {
 
-	// Parse the _config.yml file
-	path := filepath.Join(src, "_config.yml")
+	// Parse the _config.yml file, found in the source directory if provided
+	// or else the working directory
+	base := src
+	if base == "" {
+		base = "."
+	}
+	path := filepath.Join(base, "_config.yml")
 	conf, err := ParseConfig(path)
 	logf(MsgUsingConfig, path)
 	if err != nil {
 		return nil, err
 	}
+	for key, val := range flags {
+		conf.Set(key, val)
+	}
+
+	src, dest, err = resolvePaths(base, src, dest, conf)
+	if err != nil {
+		return nil, err
+	}
+	conf.Set("source", src)
+	conf.Set("destination", dest)
+
+	// Additional directories to write the generated site to
+	mirrors, err := resolveMirrors(base, conf)
+	if err != nil {
+		return nil, err
+	}
+
+	// The language of the site, which pages may override
+	if conf.GetString("lang") == "" {
+		conf.Set("lang", defaultLang)
+	}
+
+	// Register the Markdown engine, followed by any external converters
+	// which may replace it, by extension
+	conv, err := newConverters(conf)
+	if err != nil {
+		return nil, err
+	}
+
+	// Register any external processors of static files, by extension
+	procs, err := newProcessors(conf)
+	if err != nil {
+		return nil, err
+	}
+
+	// Source files are decoded from the configured encoding, if not UTF-8
+	enc, err := parseEncoding(conf.GetString("encoding"))
+	if err != nil {
+		return nil, err
+	}
 
 	site := Site{
-		Src:  src,
-		Dest: dest,
-		Conf: conf,
+		Src:     src,
+		Dest:    dest,
+		Conf:    conf,
+		Mirrors: mirrors,
+		enc:     enc,
+
+		converters:     conv,
+		processors:     procs,
+		markdownInline: conf.GetString("markdown.markdownify") == "inline",
 	}
 
 	// Recursively process all files in the source directory
@@ -53,37 +164,214 @@ func NewSite(src, dest string) (*Site, error) {
 	return &site, nil
 }
 
+// Helper function that resolves the effective source and destination
+// directories as absolute paths. An explicit src or dest is used as is,
+// otherwise the source and destination in the _config.yml are used, relative
+// to the base directory that contains it. The source defaults to the base
+// directory and the destination to _site in the working directory.
+func resolvePaths(base, src, dest string, conf Config) (string, string, error) {
+	if src == "" {
+		src = base
+		if dir := conf.GetString("source"); dir != "" {
+			src = joinPath(base, dir)
+		}
+	}
+	if dest == "" {
+		dest = defaultDest
+		if dir := conf.GetString("destination"); dir != "" {
+			dest = joinPath(base, dir)
+		}
+	}
+
+	src, err := filepath.Abs(src)
+	if err != nil {
+		return "", "", err
+	}
+	dest, err = filepath.Abs(dest)
+	if err != nil {
+		return "", "", err
+	}
+	return src, dest, nil
+}
+
+// AddPreprocessor registers a function that may transform the front-end
+// matter of every page and post after it is parsed, for example to derive a
+// description or normalize tags, and before tags and categories are counted.
+// An error aborts the build.
+//
+// Preprocessors run each time the site is read, so those added after
+// NewSite take effect on the next call to Reload.
+func (s *Site) AddPreprocessor(fn func(*Page) error) {
+	s.preprocessors = append(s.preprocessors, fn)
+}
+
+// SetUrlGenerator registers a function that returns the permalink of every
+// page, post and document, e.g. /p/x9Ab/, overriding the permalink patterns
+// for sites with a url scheme of their own, such as hashids. Returning an
+// empty permalink keeps the page's url as is. An error, or a permalink that
+// would fall outside of the destination directory, aborts the build.
+//
+// The generator runs each time the site is read, so one set after NewSite
+// takes effect on the next call to Reload.
+func (s *Site) SetUrlGenerator(fn func(Page) (string, error)) {
+	s.urlGenerator = fn
+}
+
+// RegisterFunc adds a function, under the given name, to those available in
+// templates, so that a program embedding jkl can extend it without changes
+// to the funcMap. It takes precedence over a built-in function of the same
+// name.
+//
+// Templates are compiled when the site is first generated, so functions must
+// be registered before calling Generate. Registering a function afterwards,
+// or one that is not a valid template function, returns an error.
+func (s *Site) RegisterFunc(name string, fn interface{}) (err error) {
+	if s.templ != nil {
+		return fmt.Errorf("%s: templates are already compiled", name)
+	}
+
+	// template.Funcs panics if the name or function is not valid
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%s: %v", name, r)
+		}
+	}()
+	template.New(name).Funcs(template.FuncMap{name: fn})
+
+	if s.funcs == nil {
+		s.funcs = template.FuncMap{}
+	}
+	s.funcs[name] = fn
+	return nil
+}
+
+// Posts returns the posts of the site, as read from the source directory,
+// most recent first. The posts are copies, so changing them doesn't change
+// the site.
+func (s *Site) Posts() []Page {
+	return copyPages(s.posts)
+}
+
+// Pages returns the pages of the site, as read from the source directory.
+// The pages are copies, so changing them doesn't change the site.
+func (s *Site) Pages() []Page {
+	return copyPages(s.pages)
+}
+
+// StaticFiles returns the static files of the site, relative to the source
+// directory, which are copied to the destination directory as is.
+func (s *Site) StaticFiles() []string {
+	return append([]string{}, s.files...)
+}
+
+// Helper function that returns a copy of the pages, copying the key-value
+// pairs of each page.
+func copyPages(pages []Page) []Page {
+	copies := make([]Page, len(pages))
+	for i, page := range pages {
+		copies[i] = Page{}
+		for k, v := range page {
+			copies[i][k] = v
+		}
+	}
+	return copies
+}
+
 // Reloads the site into memory
 func (s *Site) Reload() error {
 	s.posts = []Page{}
 	s.pages = []Page{}
 	s.files = []string{}
 	s.templ = nil
+	s.liquid = nil
+	s.layouts = nil
+	s.locales = nil
+	s.data = nil
 	return s.read()
 }
 
-// Prepares the source directory for site generation
+// Prepares the destination directory, and any mirrors, for site generation
 func (s *Site) Prep() error {
-	return os.MkdirAll(s.Dest, 0755)
+	for _, dir := range s.dests() {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-// Removes the existing site (typically in _site).
+// Removes the existing site (typically in _site), and any mirrors. Files
+// matching keep_files in the _config.yml, such as a CNAME added once the
+// site was generated, are kept.
 func (s *Site) Clear() error {
-	return os.RemoveAll(s.Dest)
+	keep := len(s.Conf.GetStringSlice("keep_files")) > 0
+	for _, dir := range s.dests() {
+		if _, err := os.Stat(dir); keep && err == nil {
+			if err := s.prune(dir); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := os.RemoveAll(dir); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Returns True if only part of the site is generated, with only in the
+// _config.yml, or the --only flag.
+func (s *Site) isPartial() bool {
+	return s.Conf.GetString("only") != ""
+}
+
+// Returns True if the file, relative to the source directory, is generated.
+// In a partial build, only files below the only directory are, e.g. docs/
+func (s *Site) isGenerated(rel string) bool {
+	only := s.Conf.GetString("only")
+	if only == "" {
+		return true
+	}
+	only = filepath.Clean(filepath.FromSlash(strings.Trim(only, "/")))
+	return rel == only || strings.HasPrefix(rel, only+string(filepath.Separator))
 }
 
-// Generates a static website based on Jekyll standard layout.
+// Generates a static website based on Jekyll standard layout. With only in
+// the _config.yml, the whole site is read but only the pages, posts and
+// static files below the directory are written, leaving the rest of the
+// destination directory untouched.
 func (s *Site) Generate() error {
 
 	// Remove previously generated site, and then (re)create the
-	// destination directory
-	if err := s.Clear(); err != nil {
-		return err
+	// destination directory. When skipping unchanged files, or pruning, the
+	// files left over are removed once the site is generated instead.
+	// A partial build leaves the rest of the site as is.
+	s.written = nil
+	if s.skipUnchanged() || s.Conf.GetBool("prune", false) {
+		s.written = map[string]bool{}
+	} else if !s.isPartial() {
+		if err := s.Clear(); err != nil {
+			return err
+		}
 	}
 	if err := s.Prep(); err != nil {
 		return err
 	}
 
+	s.errs = nil
+	s.remotes = nil
+	s.limit = make(chan struct{}, s.jobs())
+
+	// Compile the templates, once all functions are registered
+	if err := s.compile(); err != nil {
+		return err
+	}
+
+	// Make relative links in posts independent of where they are displayed
+	if s.Conf.GetBool("rewrite_relative_urls", false) {
+		s.rewriteRelativeUrls()
+	}
+
 	// Generate all Pages and Posts and static files
 	if err := s.writePages(); err != nil {
 		return err
@@ -91,57 +379,205 @@ func (s *Site) Generate() error {
 	if err := s.writeStatic(); err != nil {
 		return err
 	}
+	if err := s.writePostImages(); err != nil {
+		return err
+	}
+	if !s.isPartial() {
+		if err := s.writeFeed(); err != nil {
+			return err
+		}
+		if err := s.writeSitemap(); err != nil {
+			return err
+		}
+		if err := s.writeOPML(); err != nil {
+			return err
+		}
+		if err := s.writeTaxonomyJson(); err != nil {
+			return err
+		}
+	}
 
-	return nil
+	// Fail on any file that could not be generated
+	if len(s.errs) > 0 {
+		return s.errs
+	}
+
+	// Check the generated html, if enabled
+	if err := s.validateHtml(); err != nil {
+		return err
+	}
+
+	// Pre-compress the generated files, if enabled
+	if s.Conf.GetBool("gzip", false) {
+		if err := s.writeGzip(); err != nil {
+			return err
+		}
+	}
+
+	// Write the generated site to any mirrors
+	if err := s.writeMirrors(); err != nil {
+		return err
+	}
+
+	// Remove the files left over from the previous build
+	if s.written != nil && !s.isPartial() {
+		for _, dir := range s.dests() {
+			if err := s.prune(dir); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Run the commands that post-process the generated site, if any
+	return s.runHooks("after_build", s.Dest)
 }
 
 // Helper function to traverse the source directory and identify all posts,
 // projects, templates, etc and parse.
 func (s *Site) read() error {
 
+	// Run the commands that prepare the source, if any, so that the files
+	// they write are part of the build
+	if err := s.runHooks("before_build", s.Src); err != nil {
+		return err
+	}
+
 	// Lists of templates (_layouts, _includes) that we find that
 	// will need to be compiled
 	layouts := []string{}
 
+	// Front-end matter defaults from the _config.yml
+	defaults, err := parseDefaults(s.Conf)
+	if err != nil {
+		return err
+	}
+	s.defaults = defaults
+
+	// Front-end matter schema from the _config.yml
+	schema, err := parseSchema(s.Conf)
+	if err != nil {
+		return err
+	}
+	s.schema = schema
+
+	// Collections of documents from the _config.yml
+	collections, err := parseCollections(s.Conf)
+	if err != nil {
+		return err
+	}
+	s.collections = collections
+
+	s.locales = map[string]map[string]interface{}{}
+	s.data = map[string]interface{}{}
+
+	s.parseErrs = nil
+
 	// func to walk the jekyll directory structure
-	walker := func(fn string, fi os.FileInfo, err error) error {
+	var walker filepath.WalkFunc
+	walker = func(fn string, fi os.FileInfo, err error) error {
 		rel, _ := filepath.Rel(s.Src, fn)
+		converted := isConverted(s.converters, rel)
 		switch {
 		case err != nil:
 			return nil
 
-		case fi.IsDir() && isHiddenOrTemp(fn):
+		// Ignore the generated site, if written inside the source
+		case fi.IsDir() && rel != "." && s.isDestPath(fn):
+			return filepath.SkipDir
+
+		// Ignore files and directories excluded in the _config.yml
+		case fi.IsDir() && s.isExcluded(rel):
+			return filepath.SkipDir
+		case s.isExcluded(rel):
+			return nil
+
+		// Skip hidden directories, unless they contain included files
+		// such as .well-known/security.txt
+		case fi.IsDir() && isHiddenOrTemp(fn) && !s.isIncludedDir(rel):
 			return filepath.SkipDir
 
+		// Ignore the bulk_posts index, read once the walk is done
+		case !fi.IsDir() && rel == filepath.Clean(s.Conf.GetString("bulk_posts")):
+			return nil
+
+		// Walk symlinked directories, if enabled
+		case isSymlinkedDir(fn, fi) && !isHiddenOrTemp(fn):
+			return s.walkSymlink(fn, walker)
+
 		// Ignore directories
 		case fi.IsDir():
 			return nil
 
-		// Ignore Hidden or Temp files
-		// (starting with . or ending with ~)
-		case isHiddenOrTemp(rel):
+		// Ignore Hidden or Temp files (starting with . or ending with ~)
+		// unless they are explicitly included in the _config.yml
+		case isHiddenOrTemp(rel) && !s.isIncluded(rel):
 			return nil
 
 		// Parse Templates
 		case isTemplate(rel):
 			layouts = append(layouts, fn)
 
+		// Parse translation tables
+		case isLocale(rel):
+			return s.addLocale(fn, rel)
+
+		// Parse data files
+		case isData(rel):
+			return s.addData(fn, rel)
+
+		// Parse documents of collections, skipping any other file in
+		// their directories
+		case s.collectionOf(rel) != nil && hasMatter(fn, converted):
+			c, err := s.readFile(fn)
+			if err != nil {
+				return err
+			}
+			doc, err := parsePage(rel, c, s.converters)
+			if err != nil {
+				return s.parseError(rel, err)
+			}
+			return s.addDocument(s.collectionOf(rel), rel, doc)
+		case s.collectionOf(rel) != nil:
+			return nil
+
 		// Parse Posts
-		case isPost(rel):
-			post, err := ParsePost(rel)
+		case isPost(rel, converted) && hasMatter(fn, converted):
+			c, err := s.readFile(fn)
 			if err != nil {
 				return err
 			}
-			// TODO: this is a hack to get the posts in rev chronological order
-			s.posts = append([]Page{post}, s.posts...) //s.posts, post)
+			post, err := parsePost(rel, filepath.Base(rel), c, s.converters, s.Conf.GetBool("post_time", false))
+			if err != nil {
+				return s.parseError(rel, err)
+			}
+			return s.addPost(rel, post)
+
+		// Parse Drafts, if enabled
+		case isDraft(rel, converted) && hasMatter(fn, converted):
+			if !s.Conf.GetBool("show_drafts", false) {
+				return nil
+			}
+			c, err := s.readFile(fn)
+			if err != nil {
+				return err
+			}
+			post, err := parseDraft(rel, fi.ModTime(), c, s.converters)
+			if err != nil {
+				return s.parseError(rel, err)
+			}
+			return s.addPost(rel, post)
 
 		// Parse Pages
-		case isPage(rel):
-			page, err := ParsePage(rel)
+		case isPage(rel, converted) && hasMatter(fn, converted):
+			c, err := s.readFile(fn)
 			if err != nil {
 				return err
 			}
-			s.pages = append(s.pages, page)
+			page, err := parsePage(rel, c, s.converters)
+			if err != nil {
+				return s.parseError(rel, err)
+			}
+			return s.addPage(rel, page)
 
 		// Move static files, no processing required
 		case isStatic(rel):
@@ -152,29 +588,326 @@ func (s *Site) read() error {
 
 	// Walk the diretory recursively to get a list of all posts,
 	// pages, templates and static files.
-	err := filepath.Walk(s.Src, walker)
+	err = filepath.Walk(s.Src, walker)
 	if err != nil {
 		return err
 	}
 
-	// Compile all templates found, if any
-	if len(layouts) > 0 {
-		s.templ, err = template.New("layouts").Funcs(funcMap).ParseFiles(layouts...)
-		if err != nil {
-			return err
+	// Read the posts of the bulk_posts index, if any
+	if err := s.readBulkPosts(); err != nil {
+		return err
+	}
+
+	// Fail on any page or post that could not be parsed, if strict
+	if s.Conf.GetBool("strict_front_matter", false) && len(s.parseErrs) > 0 {
+		return s.parseErrs
+	}
+
+	// Keep only the most recent posts, for faster builds while working on
+	// the layouts
+	if limit := s.Conf.GetInt("limit_posts", 0); limit > 0 && len(s.posts) > limit {
+		fmt.Printf(MsgLimitPosts+"\n", limit, len(s.posts))
+		sort.Stable(byDate(s.posts))
+		s.posts = s.posts[:limit]
+	}
+
+	// Check the front-end matter against the schema, before it is
+	// transformed
+	if err := s.validateFrontMatter(); err != nil {
+		return err
+	}
+
+	// Transform the front-end matter of all pages and posts before
+	// aggregating tags and categories
+	if err := s.preprocess(); err != nil {
+		return err
+	}
+
+	// Move the pages of a multilingual site under their language prefix
+	s.localize()
+
+	// Generate the urls of all pages, posts and documents, if overridden,
+	// once they are final
+	if err := s.generateUrls(); err != nil {
+		return err
+	}
+
+	// Move the image of each post next to it, if enabled
+	s.movePostImages()
+
+	// Add a plain text excerpt, the word count, the reading time and the
+	// comment id to all pages, posts and documents, once transformed and
+	// moved to their final url
+	words := s.Conf.GetInt("excerpt_length", defaultExcerptLength)
+	wpm := s.Conf.GetInt("words_per_minute", defaultWordsPerMinute)
+	commentsByUrl := s.Conf.GetString("comment_id_source") == "url"
+	lists := [][]Page{s.pages, s.posts}
+	for _, c := range s.collections {
+		lists = append(lists, c.docs)
+	}
+	for _, pages := range lists {
+		for _, page := range pages {
+			page["excerpt"] = page.GetExcerpt(words)
+			page["word_count"] = countWords(page.GetPlainText())
+			page["reading_time"] = page.ReadingTime(wpm)
+			page["comment_id"] = page.CommentId(commentsByUrl)
 		}
 	}
 
+	// Order the documents of each collection, once complete
+	s.orderCollections()
+
+	// Templates are compiled when the site is generated, so that functions
+	// may still be registered
+	s.layouts = layouts
+
 	// Add the posts, timestamp, etc to the Site Params
 	s.Conf.Set("posts", s.posts)
 	s.Conf.Set("pages", s.pages)
 	s.Conf.Set("time", time.Now())
+	s.Conf.Set("data", s.data)
 	s.calculateTags()
 	s.calculateCategories()
+	s.calculateArchives()
 
 	return nil
 }
 
+// Helper function that compiles all templates found, if any, along with
+// inline_svg, files_in, include_remote, if enabled, and the functions added with
+// RegisterFunc. Templates are only compiled once per read of the site. With
+// jekyll_compat, their Liquid tags are translated first, see translateLiquid.
+func (s *Site) compile() error {
+	if s.templ != nil || len(s.layouts) == 0 {
+		return nil
+	}
+	funcs := template.FuncMap{"inline_svg": s.inlineSvg, "files_in": s.filesIn}
+	if timeout, ok := remoteOptions(s.Conf); ok {
+		s.remoteTimeout = timeout
+		funcs["include_remote"] = s.includeRemote
+	}
+	templ := template.New("layouts").Funcs(funcMap).Funcs(funcs).Funcs(s.funcs)
+	var err error
+	if s.Conf.GetBool("jekyll_compat", false) {
+		s.liquid = liquidFuncs(funcMap, funcs, s.funcs)
+		templ, err = s.parseLiquid(templ)
+	} else {
+		templ, err = templ.ParseFiles(s.layouts...)
+	}
+	if err != nil {
+		return err
+	}
+	s.templ = templ
+	return nil
+}
+
+// Helper function that records a page or post that could not be parsed.
+// The file is skipped with a warning, unless strict_front_matter is enabled
+// in which case the build fails once all files have been read, or at once
+// with fail_fast.
+func (s *Site) parseError(rel string, err error) error {
+	if !s.Conf.GetBool("strict_front_matter", false) {
+		fmt.Printf(MsgSkipFile+"\n", err)
+	} else if s.Conf.GetBool("fail_fast", false) {
+		return err
+	}
+	s.parseErrs = append(s.parseErrs, newBuildError(rel, err))
+	return nil
+}
+
+// Helper function that records a file that could not be generated, so the
+// build carries on with the next file and fails once all have been
+// generated. With fail_fast enabled the error is returned to stop the build
+// at once.
+func (s *Site) buildError(rel string, err error) error {
+	if s.Conf.GetBool("fail_fast", false) {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.errs = append(s.errs, newBuildError(rel, err))
+	return nil
+}
+
+// Helper function that reads a source file, decoding it to UTF-8 from the
+// site's encoding.
+func (s *Site) readFile(fn string) ([]byte, error) {
+	b, err := ioutil.ReadFile(fn)
+	if err != nil || s.enc == nil {
+		return b, err
+	}
+	return s.enc.NewDecoder().Bytes(b)
+}
+
+// Helper function that runs the registered preprocessors on all pages and
+// posts, in the order they were added.
+func (s *Site) preprocess() error {
+	for _, pages := range [][]Page{s.pages, s.posts} {
+		for i := range pages {
+			for _, fn := range s.preprocessors {
+				if err := fn(&pages[i]); err != nil {
+					return fmt.Errorf("%s: %s", pages[i].GetPath(), err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// Helper function that sets the url of all pages, posts and documents from
+// the permalinks returned by the generator set with SetUrlGenerator.
+func (s *Site) generateUrls() error {
+	if s.urlGenerator == nil {
+		return nil
+	}
+	lists := [][]Page{s.pages, s.posts}
+	for _, c := range s.collections {
+		lists = append(lists, c.docs)
+	}
+	for _, pages := range lists {
+		for _, page := range pages {
+			permalink, err := s.urlGenerator(page)
+			if err != nil {
+				return fmt.Errorf("%s: %s", page.GetPath(), err)
+			}
+			if permalink == "" {
+				continue
+			}
+			if err := page.setUrl(permalinkUrl(collapseSlashes(permalink))); err != nil {
+				return fmt.Errorf("%s: %s", page.GetPath(), err)
+			}
+		}
+	}
+	return nil
+}
+
+// Helper function that adds a parsed page to the site, applying front-end
+// matter defaults. Unpublished pages are skipped, unless enabled in the
+// _config.yml.
+func (s *Site) addPage(rel string, page Page) error {
+	if err := s.applyDefaults(rel, "pages", page); err != nil {
+		return err
+	}
+	if s.isPublished(page) {
+		s.setGuid(page)
+		s.pages = append(s.pages, page)
+	}
+	return nil
+}
+
+// Helper function that adds a parsed post or draft to the site, applying
+// front-end matter defaults and the site's permalink pattern, unless the
+// post has its own. Unpublished and future posts are skipped, unless
+// enabled in the _config.yml.
+func (s *Site) addPost(rel string, post Page) error {
+	if err := s.applyDefaults(rel, "posts", post); err != nil {
+		return err
+	}
+
+	// The time in the post's file name is in its timezone, if any, or
+	// else the site's
+	tz := post.GetString("timezone")
+	if tz == "" {
+		tz = s.Conf.GetString("timezone")
+	}
+	if tz != "" {
+		date, err := inTimezone(post.GetDate(), tz)
+		if err != nil {
+			return fmt.Errorf("%s: %s", rel, err)
+		}
+		post["date"] = date
+	}
+
+	if !s.isPublished(post) {
+		return nil
+	}
+
+	permalink := post.GetString("permalink")
+	if permalink == "" {
+		permalink = s.Conf.GetString("permalink")
+	}
+	if permalink != "" {
+		if err := post.setPermalink(permalink); err != nil {
+			return fmt.Errorf("%s: %s", rel, err)
+		}
+	}
+
+	s.setGuid(post)
+
+	// TODO: this is a hack to get the posts in rev chronological order
+	s.posts = append([]Page{post}, s.posts...) //s.posts, post)
+	return nil
+}
+
+// Helper function that applies the front-end matter defaults in scope to
+// the page or post, and then resolves its layout and permalink, since both
+// may have been provided by the defaults. Pages without a language fall back
+// to the site's language, and the heading ids of converted pages get the
+// markdown.heading_id_prefix, if any.
+func (s *Site) applyDefaults(rel, typ string, page Page) error {
+	_, hadPermalink := page["permalink"]
+	applyDefaults(s.defaults, rel, typ, page)
+
+	page["layout"] = page.GetLayout()
+	if prefix := s.Conf.GetString("markdown.heading_id_prefix"); prefix != "" && isConverted(s.converters, page.GetExt()) && !page.IsRaw() {
+		for _, key := range []string{"content", "short_description"} {
+			page[key] = prefixHeadingIds(page.GetString(key), prefix, page.GetSlug())
+		}
+	}
+	if page.GetLang() == "" {
+		page["lang"] = s.Conf.GetString("lang")
+	}
+	if permalink := page.GetString("permalink"); permalink != "" && !hadPermalink {
+		if err := page.setPermalink(permalink); err != nil {
+			return fmt.Errorf("%s: %s", rel, err)
+		}
+	}
+	return nil
+}
+
+// Returns True if the page or post should be generated. Pages with
+// published: false in the front-end matter are only generated when the
+// unpublished option is enabled, and posts dated in the future only when
+// the future option is enabled.
+func (s *Site) isPublished(page Page) bool {
+	if published, ok := page["published"].(bool); ok && !published {
+		if !s.Conf.GetBool("unpublished", false) {
+			return false
+		}
+	}
+	if date, ok := page["date"].(time.Time); ok && date.After(time.Now()) {
+		if !s.Conf.GetBool("future", false) {
+			return false
+		}
+	}
+	return true
+}
+
+// Returns True if the file, relative to the source directory, matches one
+// of the exclude patterns in the _config.yml and is not explicitly included.
+func (s *Site) isExcluded(rel string) bool {
+	return matchAny(s.Conf.GetStringSlice("exclude"), rel) && !s.isIncluded(rel)
+}
+
+// Returns True if the file, relative to the source directory, matches one
+// of the include patterns in the _config.yml.
+func (s *Site) isIncluded(rel string) bool {
+	return matchAny(s.Conf.GetStringSlice("include"), rel)
+}
+
+// Returns True if the directory, relative to the source directory, matches
+// one of the include patterns in the _config.yml or if any of the patterns
+// refer to a file inside of it.
+func (s *Site) isIncludedDir(rel string) bool {
+	prefix := filepath.Clean(rel) + string(filepath.Separator)
+	for _, pattern := range s.Conf.GetStringSlice("include") {
+		if strings.HasPrefix(filepath.Clean(strings.TrimLeft(pattern, "/")), prefix) {
+			return true
+		}
+	}
+	return s.isIncluded(rel)
+}
+
 // Helper function to write all pages and posts to the destination directory
 // during site generation.
 func (s *Site) writePages() error {
@@ -183,91 +916,274 @@ func (s *Site) writePages() error {
 	// initial parsing) so we can combine the lists and use the same rendering
 	// code for both.
 	pages := []Page{}
-	pages = append(pages, s.pages...)
+	for _, page := range s.pages {
+		pages = append(pages, s.paginate(page)...)
+	}
 	pages = append(pages, s.posts...)
+	pages = append(pages, s.ampPages()...)
+	for _, doc := range s.documents() {
+		docs, err := s.documentOutputs(doc)
+		if err != nil {
+			if err := s.buildError(doc.GetPath(), err); err != nil {
+				return err
+			}
+			continue
+		}
+		pages = append(pages, docs...)
+	}
 
+	// Variants of pages must not overwrite any other page
+	urls := map[string]bool{}
 	for _, page := range pages {
-		url := page.GetUrl()
-		layout := page.GetLayout()
-
-		// is the layout provided? or is it nil /empty?
-		//layoutNil := layout == "" || layout == "nil"
+		urls[page.GetUrl()] = true
+	}
+	for _, page := range s.pages {
+		variants, err := s.variants(page, urls)
+		if err != nil {
+			if err := s.buildError(page.GetPath(), err); err != nil {
+				return err
+			}
+			continue
+		}
+		pages = append(pages, variants...)
+	}
 
-		// make sure the posts's parent dir exists
-		d := filepath.Join(s.Dest, filepath.Dir(url))
-		f := filepath.Join(s.Dest, url)
-		if err := os.MkdirAll(d, 0755); err != nil {
-			return err
+	for _, page := range pages {
+		if !s.isGenerated(page.GetPath()) {
+			continue
+		}
+		if err := s.writePage(page); err != nil {
+			if err := s.buildError(page.GetPath(), err); err != nil {
+				return err
+			}
 		}
+	}
 
-		// if markdown, need to convert to html
-		// otherwise just convert raw html to a string
-		//var content string
-		//if isMarkdown(page.GetExt()) {
-		//	content = string(blackfriday.MarkdownCommon(raw))
-		//} else {
-		//	content = string(raw)
-		//}
+	return nil
+}
 
-		//data passed in to each template
-		data := map[string]interface{}{
-			"site": s.Conf,
-			"page": page,
-		}
+// Helper function to render a single page or post, and its redirects, to
+// the destination directory.
+func (s *Site) writePage(page Page) error {
+	url := page.GetUrl()
 
-		// treat all non-markdown pages as templates
-		content := page.GetContent()
-		if isMarkdown(page.GetExt()) == false {
-			// this code will add the page to the list of templates,
-			// will execute the template, and then set the content
-			// to the rendered template
+	// make sure the posts's parent dir exists
+	f, err := s.destPath(page.OutputPath())
+	if err != nil {
+		return err
+	}
+	d := filepath.Dir(f)
+	if err := os.MkdirAll(d, 0755); err != nil {
+		return err
+	}
 
-			if s.templ == nil {
-				return fmt.Errorf("No templates defined for page: %s", url)
-			}
+	b, err := s.renderPage(page)
+	if err != nil {
+		return err
+	}
 
-			t, err := s.templ.New(url).Parse(content)
-			if err != nil {
-				return err
-			}
-			var buf bytes.Buffer
-			err = t.ExecuteTemplate(&buf, url, data)
-			if err != nil {
-				return err
+	// keep drafts out of search engines, should they be deployed
+	if page.IsDraft() && page.GetString("output_ext") == ".html" {
+		b = injectHead(b, noindexTag)
+	}
+	if page.GetString("output_ext") == ".html" {
+		b = ampLinks(page, b)
+	}
+
+	logf(MsgGenerateFile, url)
+	if err := s.writeOutput(f, b, 0644); err != nil {
+		return err
+	}
+
+	// write any redirects from the page's old urls
+	return s.writeRedirects(page)
+}
+
+// RenderFile renders a single page or post, given its path in the source
+// directory, through its layout and returns the result without writing
+// anything to the destination directory. The site must have been read, so
+// that layouts, includes and site variables are available, but it is not
+// generated, which makes previewing a single post much faster.
+//
+// Drafts and unpublished pages can only be rendered if they were read, for
+// example with show_drafts enabled.
+func (s *Site) RenderFile(path string) ([]byte, error) {
+	rel := path
+	if filepath.IsAbs(path) {
+		var err error
+		if rel, err = filepath.Rel(s.Src, path); err != nil {
+			return nil, err
+		}
+	}
+	rel = filepath.ToSlash(filepath.Clean(rel))
+
+	if err := s.compile(); err != nil {
+		return nil, err
+	}
+	for _, pages := range [][]Page{s.pages, s.posts, s.documents()} {
+		for _, page := range pages {
+			if page.GetPath() == rel {
+				return s.renderPage(page)
 			}
-			content = buf.String()
 		}
+	}
+	return nil, fmt.Errorf("%s: not a page or post of the site", rel)
+}
 
-		// add document body to the map
-		data["content"] = content
-		data["short_description"] = page.GetShortDescription()
+// Helper function that executes the content of a page or post, unless it
+// was converted from markup such as markdown, and wraps it in its layout.
+func (s *Site) renderPage(page Page) ([]byte, error) {
+	url := page.GetUrl()
+	layout := page.GetLayout()
 
-		// write the template to a buffer
-		// NOTE: if template is nil or empty, then we should parse the
-		//       content as if it were a template
-		var buf bytes.Buffer
-		if layout == "" || layout == "nil" {
-			//t, err := s.templ.New(url).Parse(content);
-			//if err != nil { return err }
-			//err = t.ExecuteTemplate(&buf, url, data);
-			//if err != nil { return err }
-
-			buf.WriteString(content)
-		} else {
-			layout = appendExt(layout, ".html")
-			err := s.templ.ExecuteTemplate(&buf, layout, data)
-			if err != nil {
-				fmt.Println(err)
+	// is the layout provided? or is it nil /empty?
+	//layoutNil := layout == "" || layout == "nil"
+
+	// if markdown, need to convert to html
+	// otherwise just convert raw html to a string
+	//var content string
+	//if isMarkdown(page.GetExt()) {
+	//	content = string(blackfriday.MarkdownCommon(raw))
+	//} else {
+	//	content = string(raw)
+	//}
+
+	// bind the template functions that depend on the page being
+	// rendered, such as translations
+	if s.templ != nil {
+		s.templ.Funcs(s.pageFuncs(page))
+	}
+
+	//data passed in to each template
+	data := map[string]interface{}{
+		"site": s.Conf,
+		"page": page,
+	}
+
+	// treat all pages that aren't converted, such as markdown, nor shown
+	// raw as templates
+	content := page.GetContent()
+	if !isConverted(s.converters, page.GetExt()) && !page.IsRaw() {
+		// this code will add the page to the list of templates,
+		// will execute the template, and then set the content
+		// to the rendered template
+
+		if s.templ == nil {
+			return nil, fmt.Errorf("No templates defined for page: %s", url)
+		}
+
+		if s.liquid != nil {
+			var err error
+			if content, err = translateLiquid(page.GetPath(), content, s.liquid); err != nil {
+				return nil, err
 			}
 		}
+		t, err := s.templ.New(url).Parse(content)
+		if err != nil {
+			return nil, err
+		}
+		var buf bytes.Buffer
+		err = t.ExecuteTemplate(&buf, url, data)
+		if err != nil {
+			return nil, err
+		}
+		content = buf.String()
+	}
 
-		logf(MsgGenerateFile, url)
-		if err := ioutil.WriteFile(f, buf.Bytes(), 0644); err != nil {
-			return err
+	// add document body to the map
+	data["content"] = content
+	data["short_description"] = page.GetShortDescription()
+
+	// write the template to a buffer
+	// NOTE: if template is nil or empty, then we should parse the
+	//       content as if it were a template
+	var buf bytes.Buffer
+	if layout == "" || layout == "nil" {
+		//t, err := s.templ.New(url).Parse(content);
+		//if err != nil { return err }
+		//err = t.ExecuteTemplate(&buf, url, data);
+		//if err != nil { return err }
+
+		buf.WriteString(content)
+	} else {
+		if s.templ == nil {
+			return nil, fmt.Errorf("No templates defined for layout %s in page: %s", layout, url)
+		}
+		layout = appendExt(layout, ".html")
+		err := s.templ.ExecuteTemplate(&buf, layout, data)
+		if err != nil {
+			return nil, err
 		}
 	}
+	return buf.Bytes(), nil
+}
 
-	return nil
+// Helper function that writes the value as an XML document to the url in
+// the destination directory. The XML is indented for readability, unless
+// xml_compact is enabled in the _config.yml.
+func (s *Site) writeXml(url string, v interface{}) error {
+	var b []byte
+	var err error
+	if s.Conf.GetBool("xml_compact", false) {
+		b, err = xml.Marshal(v)
+	} else {
+		b, err = xml.MarshalIndent(v, "", "  ")
+	}
+	if err != nil {
+		return err
+	}
+
+	f, err := s.destPath(url)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(f), 0755); err != nil {
+		return err
+	}
+	return s.writeOutput(f, append([]byte(xml.Header), b...), 0644)
+}
+
+// Helper function that returns the template functions bound to the page
+// being rendered. They replace the placeholders of the same name in the
+// funcMap.
+func (s *Site) pageFuncs(page Page) map[string]interface{} {
+	return map[string]interface{}{
+		"markdownify":     s.markdownify,
+		"render_includes": s.includesRenderer(page),
+		"seo":             func() string { return s.seo(page) },
+		"t":               s.translator(page),
+	}
+}
+
+// Helper function that rewrites relative links and images in the rendered
+// content of each post so they resolve against the post's output directory,
+// prefixed with the site's baseurl. Without this a relative image in a post
+// breaks once the post is listed on a page at a different path.
+func (s *Site) rewriteRelativeUrls() {
+	base := strings.TrimRight(s.Conf.GetString("baseurl"), "/")
+	for _, post := range s.posts {
+		postBase := base + "/"
+		if dir := filepath.Dir(post.GetUrl()); dir != "." {
+			postBase += filepath.ToSlash(dir) + "/"
+		}
+		post["content"] = rewriteUrls(post.GetContent(), postBase)
+		post["short_description"] = rewriteUrls(post.GetString("short_description"), postBase)
+	}
+}
+
+// Helper function that resolves the url of a generated file to its path in
+// the destination directory. Returns ErrBadPermalink if the path would fall
+// outside of the destination directory.
+func (s *Site) destPath(url string) (string, error) {
+	if err := checkUrl(url); err != nil {
+		return "", err
+	}
+	f := filepath.Join(s.Dest, url)
+	rel, err := filepath.Rel(s.Dest, f)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", ErrBadPermalink
+	}
+	return f, nil
 }
 
 // Helper function to write all static files to the destination directory
@@ -275,50 +1191,136 @@ func (s *Site) writePages() error {
 // directories, if necessary.
 func (s *Site) writeStatic() error {
 
+	files := []string{}
 	for _, file := range s.files {
-		from := filepath.Join(s.Src, file)
-		to := filepath.Join(s.Dest, file)
-		logf(MsgCopyingFile, file)
-		if err := copyTo(from, to); err != nil {
-			return err
+		if s.isGenerated(file) {
+			files = append(files, file)
 		}
 	}
 
-	return nil
+	// static files don't depend on each other, so are written in parallel
+	return s.parallel(len(files), func(i int) error {
+		if err := s.writeFile(files[i]); err != nil {
+			return s.buildError(files[i], err)
+		}
+		return nil
+	})
 }
 
-// Helper function to aggregate a list of all categories and their
-// related posts.
-func (s *Site) calculateCategories() {
+// Helper function to copy a single static file to the destination
+// directory, compiling Sass stylesheets to css and running files with a
+// registered processor through it.
+func (s *Site) writeFile(file string) error {
+	// Sass stylesheets are compiled to css, while partials are only
+	// included via @import and never written to the destination
+	if isSass(file) {
+		if isPartial(file) {
+			return nil
+		}
+		logf(MsgCompileFile, file)
+		return s.compileSass(file)
+	}
 
-	categories := make(map[string][]Page)
-	for _, post := range s.posts {
-		for _, category := range post.GetCategories() {
-			if posts, ok := categories[category]; ok == false {
-				categories[category] = append(posts, post)
-			} else {
-				categories[category] = []Page{post}
-			}
+	if p, ok := s.processors[filepath.Ext(file)]; ok {
+		logf(MsgProcessFile, file)
+		return s.processFile(file, p)
+	}
+
+	from := filepath.Join(s.Src, file)
+	to := filepath.Join(s.Dest, file)
+	logf(MsgCopyingFile, file)
+
+	// Text files are converted to UTF-8, like pages and posts
+	if s.enc != nil && isText(file) {
+		b, err := s.readFile(from)
+		if err != nil {
+			return err
 		}
+		os.MkdirAll(filepath.Dir(to), 0755)
+		return s.writeOutput(to, b, 0644)
 	}
 
+	return s.copyOutput(from, to)
+}
+
+// Helper function to aggregate a list of all categories and their
+// related posts.
+func (s *Site) calculateCategories() {
+	categories, names := s.aggregate(Page.GetCategories)
 	s.Conf.Set("categories", categories)
+	s.Conf.Set("category_names", names)
 }
 
 // Helper function to aggregate a list of all tags and their
 // related posts.
 func (s *Site) calculateTags() {
+	tags, names := s.aggregate(Page.GetTags)
+	s.Conf.Set("tags", tags)
+	s.Conf.Set("tag_names", names)
+}
 
-	tags := make(map[string][]Page)
+// Helper function to group posts by the year and month they were published,
+// for archive pages. The posts_by_year and posts_by_month maps are keyed by
+// 2006 and 2006-01 respectively, and post_years and post_months list the keys
+// newest first, since templates range over maps in ascending order.
+func (s *Site) calculateArchives() {
+	years, yearKeys := s.groupByDate("2006")
+	months, monthKeys := s.groupByDate("2006-01")
+	s.Conf.Set("posts_by_year", years)
+	s.Conf.Set("post_years", yearKeys)
+	s.Conf.Set("posts_by_month", months)
+	s.Conf.Set("post_months", monthKeys)
+}
+
+// Helper function to group posts by their date, formatted with the layout.
+// Returns the groups, each sorted newest first, and their keys in
+// descending order.
+func (s *Site) groupByDate(layout string) (map[string][]Page, []string) {
+	groups := make(map[string][]Page)
+	keys := []string{}
+	for _, post := range s.posts {
+		key := post.GetDate().Format(layout)
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], post)
+	}
+	for _, posts := range groups {
+		sort.Stable(byDate(posts))
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(keys)))
+	return groups, keys
+}
+
+// Helper function to group posts by names, such as their tags or
+// categories. Names are grouped by their slug so that Go, go and GO are the
+// same tag, and the display name of each slug is the first casing seen.
+func (s *Site) aggregate(names func(Page) []string) (map[string][]Page, map[string]string) {
+	groups := make(map[string][]Page)
+	display := make(map[string]string)
 	for _, post := range s.posts {
-		for _, tag := range post.GetTags() {
-			if posts, ok := tags[tag]; ok == false {
-				tags[tag] = append(posts, post)
-			} else {
-				tags[tag] = []Page{post}
+		seen := map[string]bool{}
+		for _, name := range names(post) {
+			slug := s.taxonomySlug(name)
+			if seen[slug] {
+				continue
+			}
+			seen[slug] = true
+			if _, ok := display[slug]; !ok {
+				display[slug] = name
 			}
+			groups[slug] = append(groups[slug], post)
 		}
 	}
+	return groups, display
+}
 
-	s.Conf.Set("tags", tags)
+// Helper function that returns the normalized slug for a tag or category.
+// Slugs are lowercase unless taxonomy_case is set to preserve in the
+// _config.yml.
+func (s *Site) taxonomySlug(name string) string {
+	if s.Conf.GetString("taxonomy_case") == "preserve" {
+		return strings.Trim(nonAlphanumeric.ReplaceAllString(name, "-"), "-")
+	}
+	return slugify(name)
 }
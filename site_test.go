@@ -0,0 +1,879 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Helper function that creates a site in a temporary directory from a map of
+// file names to contents, returning the site and a func that removes it.
+func newTestSite(t *testing.T, files map[string]string) (*Site, func()) {
+	return newTestSiteFlags(t, files, nil)
+}
+
+// Helper function that creates a site in a temporary directory, overriding
+// the _config.yml with the given flags.
+func newTestSiteFlags(t *testing.T, files map[string]string, flags Config) (*Site, func()) {
+	dir, err := ioutil.TempDir("", "jkl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		fn := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(fn), 0755)
+		if err := ioutil.WriteFile(fn, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// pages and posts are parsed relative to the working directory
+	wd, _ := os.Getwd()
+	os.Chdir(dir)
+	cleanup := func() {
+		os.Chdir(wd)
+		os.RemoveAll(dir)
+	}
+
+	site, err := NewSite(dir, filepath.Join(dir, "_site"), flags)
+	if err != nil {
+		cleanup()
+		t.Fatal(err)
+	}
+	return site, cleanup
+}
+
+func TestDestPath(t *testing.T) {
+	site := Site{Dest: "/tmp/_site"}
+	if path, err := site.destPath("about/index.html"); err != nil || path != "/tmp/_site/about/index.html" {
+		t.Errorf("Expected destination path [/tmp/_site/about/index.html] got [%s] %v", path, err)
+	}
+	if path, err := site.destPath("../../etc/passwd"); err == nil {
+		t.Errorf("Expected url escaping the destination to be rejected, got [%s]", path)
+	}
+}
+
+func TestRewriteRelativeUrls(t *testing.T) {
+	post := Page{
+		"url":               "2013/01/01/post/index.html",
+		"content":           `<p><img src="image.png"></p>`,
+		"short_description": `<p><a href="notes.txt">notes</a></p>`}
+	site := Site{
+		Conf:  Config{"baseurl": "http://example.com/blog/"},
+		posts: []Page{post}}
+
+	site.rewriteRelativeUrls()
+	if content := post.GetContent(); content != `<p><img src="http://example.com/blog/2013/01/01/post/image.png"></p>` {
+		t.Errorf("Expected rewritten content got [%s]", content)
+	}
+	if desc := post.GetString("short_description"); desc != `<p><a href="http://example.com/blog/2013/01/01/post/notes.txt">notes</a></p>` {
+		t.Errorf("Expected rewritten short description got [%s]", desc)
+	}
+}
+
+func TestReadExclude(t *testing.T) {
+	site, cleanup := newTestSite(t, map[string]string{
+		"_config.yml":            "exclude: [node_modules, \"*.txt\", docs/drafts/]\ninclude: [KEEP.txt]\n",
+		"LICENSE.txt":            "license",
+		"KEEP.txt":               "keep",
+		"img/logo.png":           "png",
+		"node_modules/lib.js":    "js",
+		"docs/drafts/page.html":  "html",
+		"docs/notes.txt":         "notes",
+		"node_modules/a/b/c.css": "css"})
+	defer cleanup()
+
+	sort.Strings(site.files)
+	expected := []string{"KEEP.txt", "docs/notes.txt", "img/logo.png"}
+	if !reflect.DeepEqual(site.files, expected) {
+		t.Errorf("Expected static files %v got %v", expected, site.files)
+	}
+}
+
+func TestReadIncludeHidden(t *testing.T) {
+	site, cleanup := newTestSite(t, map[string]string{
+		"_config.yml":              "include: [.htaccess, .nojekyll, .well-known/security.txt]\n",
+		".htaccess":                "deny from all",
+		".nojekyll":                "",
+		".well-known/security.txt": "Contact: me@example.com",
+		".well-known/other.txt":    "other",
+		".git/config":              "[core]",
+		"img/.DS_Store":            "store",
+		"img/logo.png":             "png"})
+	defer cleanup()
+
+	sort.Strings(site.files)
+	expected := []string{".htaccess", ".nojekyll", ".well-known/security.txt", "img/logo.png"}
+	if !reflect.DeepEqual(site.files, expected) {
+		t.Errorf("Expected static files %v got %v", expected, site.files)
+	}
+
+	if err := site.Generate(); err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range expected {
+		if _, err := os.Stat(filepath.Join(site.Dest, file)); err != nil {
+			t.Errorf("Expected included file [%s] to be copied to the destination", file)
+		}
+	}
+}
+
+func TestReadPermalinkCategories(t *testing.T) {
+	site, cleanup := newTestSite(t, map[string]string{
+		"_config.yml":                 "permalink: /:categories/:year/:title/\n",
+		"_posts/2013-02-14-first.md":  "---\ncategories: [Tech, Go]\n---\nfirst",
+		"_posts/2013-02-15-second.md": "---\ntitle: Second\n---\nsecond",
+		"_posts/2013-02-16-third.md":  "---\npermalink: /third.html\n---\nthird"})
+	defer cleanup()
+
+	urls := []string{}
+	for _, post := range site.posts {
+		urls = append(urls, post.GetUrl())
+	}
+	sort.Strings(urls)
+	expected := []string{"2013/second/index.html", "tech/go/2013/first/index.html", "third.html"}
+	if !reflect.DeepEqual(urls, expected) {
+		t.Errorf("Expected post urls %v got %v", expected, urls)
+	}
+}
+
+func TestReadPublishing(t *testing.T) {
+	files := map[string]string{
+		"_config.yml":                    "",
+		"_posts/2013-02-14-published.md": "---\ntitle: Published\n---\npublished",
+		"_posts/2013-02-15-hidden.md":    "---\npublished: false\n---\nunpublished",
+		"_posts/2999-01-01-future.md":    "---\ntitle: Future\n---\nfuture",
+		"_drafts/draft.md":               "---\ntitle: Draft\n---\ndraft",
+		"about.html":                     "---\ntitle: About\n---\nabout",
+		"secret.html":                    "---\npublished: false\n---\nsecret"}
+
+	tests := []struct {
+		flags Config
+		posts []string
+		pages []string
+	}{
+		{nil, []string{"published"}, []string{"about"}},
+		{Config{"show_drafts": true}, []string{"draft", "published"}, []string{"about"}},
+		{Config{"future": true}, []string{"future", "published"}, []string{"about"}},
+		{Config{"unpublished": true}, []string{"hidden", "published"}, []string{"about", "secret"}}}
+
+	for _, test := range tests {
+		site, cleanup := newTestSiteFlags(t, files, test.flags)
+		posts, pages := []string{}, []string{}
+		for _, post := range site.posts {
+			posts = append(posts, post.GetSlug())
+		}
+		for _, page := range site.pages {
+			pages = append(pages, page.GetSlug())
+		}
+		sort.Strings(posts)
+		sort.Strings(pages)
+		if !reflect.DeepEqual(posts, test.posts) {
+			t.Errorf("Expected posts %v with flags %v got %v", test.posts, test.flags, posts)
+		}
+		if !reflect.DeepEqual(pages, test.pages) {
+			t.Errorf("Expected pages %v with flags %v got %v", test.pages, test.flags, pages)
+		}
+		cleanup()
+	}
+}
+
+func TestNewSiteFlags(t *testing.T) {
+	site, cleanup := newTestSiteFlags(t, map[string]string{
+		"_config.yml": "future: false\ntitle: Blog\n"}, Config{"future": true, "preview": true})
+	defer cleanup()
+
+	if site.Conf.Get("future") != true || site.Conf.Get("preview") != true {
+		t.Errorf("Expected flags to override the _config.yml, got %v", site.Conf)
+	}
+	if site.Conf.GetString("title") != "Blog" {
+		t.Errorf("Expected _config.yml values to be kept, got %v", site.Conf)
+	}
+}
+
+func TestReadDefaults(t *testing.T) {
+	site, cleanup := newTestSite(t, map[string]string{
+		"_config.yml":                "defaults:\n  - scope: {type: posts}\n    values: {layout: post, permalink: \"/blog/:slug/\"}\n  - scope: {type: pages}\n    values: {layout: page}\n",
+		"_posts/2013-02-14-first.md": "---\ntitle: First\n---\nfirst",
+		"about.md":                   "---\ntitle: About\n---\nabout",
+		"index.html":                 "---\nlayout: home\n---\nindex"})
+	defer cleanup()
+
+	if post := site.posts[0]; post.GetLayout() != "post" || post.GetUrl() != "blog/first/index.html" {
+		t.Errorf("Expected post defaults to be applied, got layout [%s] url [%s]", post.GetLayout(), post.GetUrl())
+	}
+	layouts := map[string]string{}
+	for _, page := range site.pages {
+		layouts[page.GetSlug()] = page.GetString("layout")
+	}
+	if layouts["about"] != "page" || layouts["index"] != "home" {
+		t.Errorf("Expected page defaults to be applied, got %v", layouts)
+	}
+}
+
+func TestPreprocessors(t *testing.T) {
+	site, cleanup := newTestSite(t, map[string]string{
+		"_config.yml":                "",
+		"_posts/2013-02-14-first.md": "---\ntags: [Go, Web]\n---\nfirst",
+		"about.md":                   "---\ntitle: About\n---\nabout"})
+	defer cleanup()
+
+	site.AddPreprocessor(func(p *Page) error {
+		tags := []string{}
+		for _, tag := range p.GetTags() {
+			tags = append(tags, strings.ToLower(tag))
+		}
+		(*p)["tags"] = tags
+		return nil
+	})
+	site.AddPreprocessor(func(p *Page) error {
+		if p.GetString("description") == "" {
+			(*p)["description"] = "about " + p.GetSlug()
+		}
+		return nil
+	})
+	if err := site.Reload(); err != nil {
+		t.Fatal(err)
+	}
+
+	if tags := site.posts[0].GetTags(); !reflect.DeepEqual(tags, []string{"go", "web"}) {
+		t.Errorf("Expected normalized tags [go web] got %v", tags)
+	}
+	if desc := site.pages[0].GetString("description"); desc != "about about" {
+		t.Errorf("Expected derived description got [%s]", desc)
+	}
+	if _, ok := site.Conf.Get("tags").(map[string][]Page)["go"]; !ok {
+		t.Errorf("Expected tags to be counted after preprocessing, got %v", site.Conf.Get("tags"))
+	}
+
+	site.AddPreprocessor(func(p *Page) error {
+		return errors.New("boom")
+	})
+	if err := site.Reload(); err == nil || err.Error() != "about.md: boom" {
+		t.Errorf("Expected preprocessor error for about.md to abort the build, got %v", err)
+	}
+}
+
+func TestGeneratePartial(t *testing.T) {
+	site, cleanup := newTestSite(t, map[string]string{
+		"_config.yml":           "sitemap: true\n",
+		"_layouts/default.html": "{{.content}}",
+		"docs/guide.html":       "---\n---\nguide",
+		"docs/logo.png":         "logo",
+		"docs-old/page.html":    "---\n---\nold",
+		"about.html":            "---\n---\nabout"})
+	defer cleanup()
+
+	if err := site.Generate(); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range map[string]string{
+		"docs/guide.html":    "---\n---\nnew guide",
+		"docs-old/page.html": "---\n---\nnew old",
+		"about.html":         "---\n---\nnew about",
+	} {
+		ioutil.WriteFile(filepath.Join(site.Src, name), []byte(content), 0644)
+	}
+	os.Remove(filepath.Join(site.Dest, "sitemap.xml"))
+
+	site.Conf.Set("only", "docs/")
+	if err := site.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if err := site.Generate(); err != nil {
+		t.Fatal(err)
+	}
+	for fn, expected := range map[string]string{
+		"docs/guide.html":    "new guide",
+		"docs/logo.png":      "logo",
+		"docs-old/page.html": "old",
+		"about.html":         "about",
+	} {
+		if b, _ := ioutil.ReadFile(filepath.Join(site.Dest, fn)); string(b) != expected {
+			t.Errorf("Expected %s to be [%s] got [%s]", fn, expected, b)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(site.Dest, "sitemap.xml")); err == nil {
+		t.Errorf("Expected no sitemap in a partial build")
+	}
+}
+
+func TestUrlGenerator(t *testing.T) {
+	site, cleanup := newTestSite(t, map[string]string{
+		"_config.yml":                "",
+		"_layouts/default.html":      "{{.content}}",
+		"_posts/2013-02-14-first.md": "---\ntitle: First\nshort_id: x9Ab\n---\nfirst",
+		"about.md":                   "---\ntitle: About\n---\nabout"})
+	defer cleanup()
+
+	site.SetUrlGenerator(func(p Page) (string, error) {
+		if id := p.GetString("short_id"); id != "" {
+			return "/p/" + id + "/", nil
+		}
+		return "", nil
+	})
+	if err := site.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if url := site.posts[0].Permalink(); url != "/p/x9Ab/" {
+		t.Errorf("Expected the generated permalink /p/x9Ab/ got %s", url)
+	}
+	if url := site.pages[0].Permalink(); url != "/about.html" {
+		t.Errorf("Expected the built-in permalink /about.html got %s", url)
+	}
+	if err := site.Generate(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(site.Dest, "p", "x9Ab", "index.html")); err != nil {
+		t.Errorf("Expected the post written to its generated url, got %s", err)
+	}
+
+	for permalink, msg := range map[string]string{
+		"/../../outside/": ErrBadPermalink.Error(),
+		"error":           "boom",
+	} {
+		permalink := permalink
+		site.SetUrlGenerator(func(p Page) (string, error) {
+			if permalink == "error" {
+				return "", errors.New("boom")
+			}
+			return permalink, nil
+		})
+		if err := site.Reload(); err == nil || !strings.Contains(err.Error(), msg) {
+			t.Errorf("Expected error [%s] for [%s] got %v", msg, permalink, err)
+		}
+	}
+}
+
+func TestCalculateTags(t *testing.T) {
+	first := Page{"title": "First", "tags": []string{"Go", "Web Dev"}, "categories": []string{"Tech"}}
+	second := Page{"title": "Second", "tags": []string{"go", "GO", "web-dev"}, "categories": []string{"tech"}}
+	third := Page{"title": "Third", "tags": []string{"Rust"}}
+
+	site := Site{Conf: Config{}, posts: []Page{first, second, third}}
+	site.calculateTags()
+	site.calculateCategories()
+
+	tags := site.Conf.Get("tags").(map[string][]Page)
+	if len(tags) != 3 || len(tags["go"]) != 2 || len(tags["web-dev"]) != 2 || len(tags["rust"]) != 1 {
+		t.Errorf("Expected tags grouped case-insensitively, got %v", tags)
+	}
+	names := site.Conf.Get("tag_names").(map[string]string)
+	if names["go"] != "Go" || names["web-dev"] != "Web Dev" || names["rust"] != "Rust" {
+		t.Errorf("Expected display names of first seen casing, got %v", names)
+	}
+	categories := site.Conf.Get("categories").(map[string][]Page)
+	if len(categories) != 1 || len(categories["tech"]) != 2 {
+		t.Errorf("Expected categories grouped case-insensitively, got %v", categories)
+	}
+
+	site.Conf.Set("taxonomy_case", "preserve")
+	site.calculateTags()
+	tags = site.Conf.Get("tags").(map[string][]Page)
+	if len(tags["Go"]) != 1 || len(tags["go"]) != 1 || len(tags["GO"]) != 1 {
+		t.Errorf("Expected tags grouped by case with taxonomy_case preserve, got %v", tags)
+	}
+}
+
+func TestCalculateArchives(t *testing.T) {
+	date := func(s string) time.Time {
+		d, _ := time.Parse("2006-01-02", s)
+		return d
+	}
+	posts := []Page{
+		{"title": "A", "date": date("2012-12-31")},
+		{"title": "B", "date": date("2013-02-01")},
+		{"title": "C", "date": date("2013-02-14")},
+		{"title": "D", "date": date("2013-05-01")}}
+
+	site := Site{Conf: Config{}, posts: posts}
+	site.calculateArchives()
+
+	if years := site.Conf.Get("post_years").([]string); !reflect.DeepEqual(years, []string{"2013", "2012"}) {
+		t.Errorf("Expected years newest first, got %v", years)
+	}
+	if months := site.Conf.Get("post_months").([]string); !reflect.DeepEqual(months, []string{"2013-05", "2013-02", "2012-12"}) {
+		t.Errorf("Expected months newest first, got %v", months)
+	}
+
+	titles := []string{}
+	for _, post := range site.Conf.Get("posts_by_year").(map[string][]Page)["2013"] {
+		titles = append(titles, post.GetTitle())
+	}
+	if !reflect.DeepEqual(titles, []string{"D", "C", "B"}) {
+		t.Errorf("Expected posts of 2013 newest first, got %v", titles)
+	}
+	if feb := site.Conf.Get("posts_by_month").(map[string][]Page)["2013-02"]; len(feb) != 2 {
+		t.Errorf("Expected 2 posts in 2013-02, got %d", len(feb))
+	}
+}
+
+func TestReadEncoding(t *testing.T) {
+	site, cleanup := newTestSite(t, map[string]string{
+		"_config.yml":               "encoding: iso-8859-1\n",
+		"_layouts/default.html":     "{{.content}}",
+		"_posts/2013-02-14-cafe.md": "---\ntitle: Caf\xe9\n---\nCr\xe8me br\xfbl\xe9e",
+		"css/style.css":             "/* na\xefve */"})
+	defer cleanup()
+
+	post := site.posts[0]
+	if post.GetTitle() != "Café" {
+		t.Errorf("Expected decoded title [Café] got [%s]", post.GetTitle())
+	}
+	if post.GetContent() != "<p>Crème brûlée</p>\n" {
+		t.Errorf("Expected decoded content got [%s]", post.GetContent())
+	}
+
+	if err := site.Generate(); err != nil {
+		t.Fatal(err)
+	}
+	b, _ := ioutil.ReadFile(filepath.Join(site.Dest, "css", "style.css"))
+	if string(b) != "/* naïve */" {
+		t.Errorf("Expected static text file converted to UTF-8, got [%s]", b)
+	}
+}
+
+func TestNewSiteUnknownEncoding(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jkl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ioutil.WriteFile(filepath.Join(dir, "_config.yml"), []byte("encoding: klingon\n"), 0644)
+
+	if _, err := NewSite(dir, filepath.Join(dir, "_site"), nil); err == nil {
+		t.Errorf("Expected error for unknown encoding")
+	}
+}
+
+func TestNewSiteOutsideSource(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jkl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	os.MkdirAll(filepath.Join(dir, "_posts"), 0755)
+	ioutil.WriteFile(filepath.Join(dir, "_config.yml"), nil, 0644)
+	ioutil.WriteFile(filepath.Join(dir, "about.md"), []byte("---\ntitle: About\n---\nabout"), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "_posts", "2013-02-14-first.md"), []byte("---\ntitle: First\n---\nfirst"), 0644)
+
+	// the working directory is not the source directory
+	site, err := NewSite(dir, filepath.Join(dir, "_site"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(site.pages) != 1 || len(site.posts) != 1 {
+		t.Errorf("Expected 1 page and 1 post read from outside the source, got %d pages %d posts", len(site.pages), len(site.posts))
+	}
+}
+
+func TestResolvePaths(t *testing.T) {
+	wd, _ := os.Getwd()
+	tests := []struct {
+		src, dest string
+		conf      Config
+		expSrc    string
+		expDest   string
+	}{
+		{"", "", Config{}, "/blog", filepath.Join(wd, "_site")},
+		{"", "", Config{"source": "src", "destination": "public"}, "/blog/src", "/blog/public"},
+		{"", "", Config{"destination": "/var/www"}, "/blog", "/var/www"},
+		{"/other", "/tmp/out", Config{"source": "src", "destination": "public"}, "/other", "/tmp/out"},
+	}
+
+	for _, test := range tests {
+		src, dest, err := resolvePaths("/blog", test.src, test.dest, test.conf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if src != test.expSrc || dest != test.expDest {
+			t.Errorf("Expected paths [%s] [%s] got [%s] [%s] for %v", test.expSrc, test.expDest, src, dest, test.conf)
+		}
+	}
+}
+
+func TestReadLang(t *testing.T) {
+	site, cleanup := newTestSite(t, map[string]string{
+		"_config.yml": "",
+		"index.html":  "---\ntitle: Home\n---\nhome",
+		"ola.html":    "---\nlang: pt-BR\n---\nola"})
+	defer cleanup()
+
+	if lang := site.Conf.GetString("lang"); lang != "en" {
+		t.Errorf("Expected default site language [en] got [%s]", lang)
+	}
+	langs := map[string]string{}
+	for _, page := range site.pages {
+		langs[page.GetSlug()] = page.GetLang()
+	}
+	expected := map[string]string{"index": "en", "ola": "pt-BR"}
+	if !reflect.DeepEqual(langs, expected) {
+		t.Errorf("Expected page languages %v got %v", expected, langs)
+	}
+}
+
+func TestReadStrictFrontMatter(t *testing.T) {
+	files := map[string]string{
+		"_config.yml":               "",
+		"_posts/2013-02-14-good.md": "---\ntitle: Good\n---\ngood",
+		"_posts/2013-02-15-bad.md":  "---\ntitle: [unclosed\n---\nbad",
+		"_posts/not-a-date.md":      "---\ntitle: Undated\n---\nundated",
+		"about.md":                  ";;;\n{\"title\": }\n;;;\nabout"}
+
+	site, cleanup := newTestSite(t, files)
+	if len(site.posts) != 1 || len(site.pages) != 0 || len(site.parseErrs) != 3 {
+		t.Errorf("Expected unparseable files to be skipped, got %d posts %d pages %v", len(site.posts), len(site.pages), site.parseErrs)
+	}
+	cleanup()
+
+	dir, _ := ioutil.TempDir("", "jkl")
+	defer os.RemoveAll(dir)
+	for name, content := range files {
+		os.MkdirAll(filepath.Join(dir, filepath.Dir(name)), 0755)
+		ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644)
+	}
+	_, err := NewSite(dir, filepath.Join(dir, "_site"), Config{"strict_front_matter": true})
+	errs, ok := err.(BuildErrors)
+	if !ok || len(errs) != 3 {
+		t.Fatalf("Expected every unparseable file to be reported, got %v", err)
+	}
+	for _, file := range []string{"2013-02-15-bad.md", "not-a-date.md", "about.md"} {
+		if !strings.Contains(err.Error(), file) {
+			t.Errorf("Expected [%s] in error [%s]", file, err)
+		}
+	}
+}
+
+func TestWritePagesVerbatim(t *testing.T) {
+	site, cleanup := newTestSite(t, map[string]string{
+		"_config.yml":           "title: Blog\n",
+		"_layouts/default.html": "<html>{{.content}}</html>",
+		"humans.txt":            "---\n---\nSite: {{.site.title}}",
+		"manifest.html":         "---\npermalink: /manifest.webmanifest\n---\n{\"name\": \"{{.site.title}}\"}",
+		"about.md":              "---\npermalink: /about/\n---\nabout"})
+	defer cleanup()
+
+	if err := site.Generate(); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{
+		"humans.txt":           "Site: Blog",
+		"manifest.webmanifest": `{"name": "Blog"}`,
+		"about/index.html":     "<html><p>about</p>\n</html>"}
+	for file, content := range expected {
+		b, err := ioutil.ReadFile(filepath.Join(site.Dest, file))
+		if err != nil || string(b) != content {
+			t.Errorf("Expected [%s] written verbatim as [%s] got [%s] %v", file, content, b, err)
+		}
+	}
+}
+
+func TestGenerateBuildErrors(t *testing.T) {
+	files := map[string]string{
+		"_config.yml":           "",
+		"_layouts/default.html": "{{.content}}",
+		"_layouts/broken.html":  "{{.content.missing}}",
+		"good.html":             "---\ntitle: Good\n---\ngood",
+		"bad.html":              "---\ntitle: Bad\n---\n{{template \"nope.html\"}}",
+		"worse.md":              "---\nlayout: broken\n---\nworse"}
+
+	site, cleanup := newTestSite(t, files)
+	defer cleanup()
+	err := site.Generate()
+	errs, ok := err.(BuildErrors)
+	if !ok || len(errs) != 2 {
+		t.Fatalf("Expected an error for each broken page, got %v", err)
+	}
+	paths := []string{errs[0].Path, errs[1].Path}
+	sort.Strings(paths)
+	if !reflect.DeepEqual(paths, []string{"bad.html", "worse.md"}) {
+		t.Errorf("Expected errors for [bad.html worse.md] got %v", paths)
+	}
+	if _, err := os.Stat(filepath.Join(site.Dest, "good.html")); err != nil {
+		t.Errorf("Expected good pages to be generated despite errors")
+	}
+
+	site.Conf.Set("fail_fast", true)
+	if err := site.Generate(); err == nil {
+		t.Errorf("Expected error with fail_fast")
+	} else if _, ok := err.(BuildErrors); ok {
+		t.Errorf("Expected only the first error with fail_fast, got %v", err)
+	}
+}
+
+func TestRegisterFunc(t *testing.T) {
+	site, cleanup := newTestSite(t, map[string]string{
+		"_config.yml":           "title: Blog\n",
+		"_layouts/default.html": "<html>{{shout .site.title}} {{.content}}</html>",
+		"index.html":            "---\n---\n{{upcase \"home\"}}"})
+	defer cleanup()
+
+	if err := site.RegisterFunc("shout", func(s string) string { return s + "!" }); err != nil {
+		t.Fatal(err)
+	}
+	if err := site.RegisterFunc("upcase", func(s string) string { return "custom" }); err != nil {
+		t.Fatal(err)
+	}
+	if err := site.RegisterFunc("bad", "not a func"); err == nil {
+		t.Errorf("Expected error registering a value that is not a function")
+	}
+	if err := site.Generate(); err != nil {
+		t.Fatal(err)
+	}
+	b, _ := ioutil.ReadFile(filepath.Join(site.Dest, "index.html"))
+	if string(b) != "<html>Blog! custom</html>" {
+		t.Errorf("Expected registered functions in templates, got [%s]", b)
+	}
+
+	if err := site.RegisterFunc("late", strings.ToLower); err == nil {
+		t.Errorf("Expected error registering a function after templates are compiled")
+	}
+
+	// registered functions are kept when the site is reloaded
+	if err := site.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if err := site.Generate(); err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := ioutil.ReadFile(filepath.Join(site.Dest, "index.html")); string(b) != "<html>Blog! custom</html>" {
+		t.Errorf("Expected registered functions after reload, got [%s]", b)
+	}
+}
+
+func TestRenderFile(t *testing.T) {
+	site, cleanup := newTestSite(t, map[string]string{
+		"_config.yml":                "title: Blog\n",
+		"_layouts/default.html":      "<html>{{.site.title}}: {{.content}}</html>",
+		"_posts/2013-02-14-first.md": "---\ntitle: First\n---\nfirst",
+		"about.html":                 "---\ntitle: About\n---\n{{.page.title}}"})
+	defer cleanup()
+
+	tests := map[string]string{
+		"_posts/2013-02-14-first.md":                    "<html>Blog: <p>first</p>\n</html>",
+		filepath.Join(site.Src, "about.html"):           "<html>Blog: About</html>",
+		filepath.Join(site.Src, "_posts/../about.html"): "<html>Blog: About</html>",
+	}
+	for path, expected := range tests {
+		b, err := site.RenderFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != expected {
+			t.Errorf("Expected [%s] rendered as [%s] got [%s]", path, expected, b)
+		}
+	}
+
+	if _, err := os.Stat(site.Dest); !os.IsNotExist(err) {
+		t.Errorf("Expected nothing written to the destination directory")
+	}
+	if _, err := site.RenderFile("missing.md"); err == nil {
+		t.Errorf("Expected error rendering a file that is not a page or post")
+	}
+}
+
+func TestWriteDrafts(t *testing.T) {
+	site, cleanup := newTestSiteFlags(t, map[string]string{
+		"_config.yml":                    "",
+		"_layouts/default.html":          "<html><head><title>{{.page.title}}</title></head>{{if .page.draft}}DRAFT {{end}}{{.content}}</html>",
+		"_drafts/idea.md":                "---\ntitle: Idea\n---\nidea",
+		"_posts/2013-02-14-published.md": "---\ntitle: Published\n---\npublished"}, Config{"show_drafts": true})
+	defer cleanup()
+
+	if err := site.Generate(); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{
+		"idea/index.html":      `<html><head><title>Idea</title><meta name="robots" content="noindex"></head>DRAFT <p>idea</p>` + "\n</html>",
+		"published/index.html": "<html><head><title>Published</title></head><p>published</p>\n</html>"}
+	for file, content := range expected {
+		b, err := ioutil.ReadFile(filepath.Join(site.Dest, file))
+		if err != nil || string(b) != content {
+			t.Errorf("Expected [%s] as [%s] got [%s] %v", file, content, b, err)
+		}
+	}
+	for _, post := range site.posts {
+		if post.IsDraft() != (post.GetSlug() == "idea") {
+			t.Errorf("Expected only the post from _drafts to be a draft, got [%s] %v", post.GetSlug(), post.IsDraft())
+		}
+	}
+}
+
+func TestWritePagesPaginated(t *testing.T) {
+	files := map[string]string{
+		"_config.yml":           "",
+		"_layouts/default.html": "{{.content}}",
+		"news.html":             "---\npagination:\n  enabled: true\n  category: news\n  per_page: 2\n---\n{{.page.paginator.page}}/{{.page.paginator.total_pages}}:{{range .page.paginator.posts}} {{.slug}}{{end}} [{{.page.paginator.previous_page_path}}|{{.page.paginator.next_page_path}}]",
+		"blog/index.html":       "---\npagination: {enabled: true}\n---\n{{len .page.paginator.posts}}"}
+	for i := 1; i <= 5; i++ {
+		category := "news"
+		if i == 5 {
+			category = "misc"
+		}
+		files[fmt.Sprintf("_posts/2013-02-0%d-post-%d.md", i, i)] = "---\ncategory: " + category + "\n---\npost"
+	}
+	site, cleanup := newTestSite(t, files)
+	defer cleanup()
+
+	if err := site.Generate(); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{
+		"news.html":              "1/2: post-4 post-3 [|/news/page/2/]",
+		"news/page/2/index.html": "2/2: post-2 post-1 [/news.html|]",
+		"blog/index.html":        "5",
+		"blog/page/2/index.html": "",
+	}
+	for file, content := range expected {
+		b, err := ioutil.ReadFile(filepath.Join(site.Dest, file))
+		if content == "" {
+			if err == nil {
+				t.Errorf("Expected [%s] not to be written", file)
+			}
+			continue
+		}
+		if string(b) != content {
+			t.Errorf("Expected [%s] to contain [%s] got [%s]", file, content, b)
+		}
+	}
+}
+
+func TestWritePagesPaginatedRedirect(t *testing.T) {
+	files := map[string]string{
+		"_config.yml":           "",
+		"_layouts/default.html": "{{.content}}",
+		"news.html":             "---\nredirect_from: /old-news/\npagination:\n  enabled: true\n  per_page: 1\n---\n{{.page.paginator.page}}"}
+	for i := 1; i <= 3; i++ {
+		files[fmt.Sprintf("_posts/2013-02-0%d-post-%d.md", i, i)] = "---\n---\npost"
+	}
+	site, cleanup := newTestSite(t, files)
+	defer cleanup()
+
+	if err := site.Generate(); err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadFile(filepath.Join(site.Dest, "old-news", "index.html"))
+	if err != nil || !strings.Contains(string(b), `url=/news.html"`) {
+		t.Errorf("Expected the redirect to point at the first page, got [%s] %v", b, err)
+	}
+}
+
+func TestWritePagesRaw(t *testing.T) {
+	site, cleanup := newTestSite(t, map[string]string{
+		"_config.yml":           "",
+		"_layouts/default.html": "<main>{{.content}}</main>",
+		"templates.html":        "---\nrender: raw\n---\n{{.page.title}}"})
+	defer cleanup()
+
+	if err := site.Generate(); err != nil {
+		t.Fatal(err)
+	}
+	b, _ := ioutil.ReadFile(filepath.Join(site.Dest, "templates.html"))
+	if expected := "<main><pre>{{.page.title}}</pre>\n</main>"; string(b) != expected {
+		t.Errorf("Expected a raw page in its layout [%s] got [%s]", expected, b)
+	}
+}
+
+func TestSiteAccessors(t *testing.T) {
+	site, cleanup := newTestSite(t, map[string]string{
+		"_config.yml":                 "",
+		"about.html":                  "---\ntitle: About\n---\nabout",
+		"_posts/2013-02-14-first.md":  "---\ntitle: First\n---\nfirst",
+		"_posts/2013-02-15-second.md": "---\ntitle: Second\n---\nsecond",
+		"css/site.css":                "body {}"})
+	defer cleanup()
+
+	posts := site.Posts()
+	if len(posts) != 2 || posts[0].GetTitle() != "Second" || posts[1].GetTitle() != "First" {
+		t.Errorf("Expected the posts most recent first, got %v", posts)
+	}
+	pages := site.Pages()
+	if len(pages) != 1 || pages[0].GetTitle() != "About" {
+		t.Errorf("Expected the about page, got %v", pages)
+	}
+	if files := site.StaticFiles(); !reflect.DeepEqual(files, []string{filepath.Join("css", "site.css")}) {
+		t.Errorf("Expected the static files, got %v", files)
+	}
+
+	// changing the copies leaves the site as is
+	posts[0]["title"] = "Changed"
+	pages[0] = Page{}
+	site.StaticFiles()[0] = "changed"
+	if site.posts[0].GetTitle() != "Second" || site.pages[0].GetTitle() != "About" || site.files[0] == "changed" {
+		t.Errorf("Expected the site not to change with the returned copies")
+	}
+}
+
+func TestReadHeadingIdPrefix(t *testing.T) {
+	site, cleanup := newTestSite(t, map[string]string{
+		"_config.yml": "markdown:\n  auto_heading_ids: true\n  heading_id_prefix: \"docs-:slug-\"\n",
+		"guide.md":    "---\n---\n[Setup](#setup)\n\n## Setup\n",
+		"about.html":  "---\n---\n<h2 id=\"team\">Team</h2>"})
+	defer cleanup()
+
+	for _, page := range site.pages {
+		content := page.GetContent()
+		switch page.GetSlug() {
+		case "guide":
+			if !strings.Contains(content, `<a href="#docs-guide-setup">`) || !strings.Contains(content, `<h2 id="docs-guide-setup">`) {
+				t.Errorf("Expected prefixed heading ids and links, got [%s]", content)
+			}
+		case "about":
+			if !strings.Contains(content, `id="team"`) {
+				t.Errorf("Expected html pages to be left as is, got [%s]", content)
+			}
+		}
+	}
+}
+
+func TestReadLimitPosts(t *testing.T) {
+	site, cleanup := newTestSiteFlags(t, map[string]string{
+		"_config.yml":                 "",
+		"_posts/2013-02-14-first.md":  "---\ntags: [old]\n---\nfirst",
+		"_posts/2013-03-01-third.md":  "---\ntags: [new]\n---\nthird",
+		"_posts/2013-02-20-second.md": "---\ntags: [new]\n---\nsecond"}, Config{"limit_posts": 2})
+	defer cleanup()
+
+	slugs := []string{}
+	for _, post := range site.posts {
+		slugs = append(slugs, post.GetSlug())
+	}
+	if !reflect.DeepEqual(slugs, []string{"third", "second"}) {
+		t.Errorf("Expected the 2 most recent posts, got %v", slugs)
+	}
+	tags, _ := site.Conf.Get("tags").(map[string][]Page)
+	if _, ok := tags["old"]; ok || len(tags["new"]) != 2 {
+		t.Errorf("Expected the tags of the remaining posts only, got %v", site.Conf.Get("tag_names"))
+	}
+}
+
+func TestGenerateStaticBraces(t *testing.T) {
+	files := map[string]string{
+		"_config.yml":           "",
+		"_layouts/default.html": "{{.content}}",
+		"manifest.json":         "{\n  \"name\": \"My Site\",\n  \"icons\": []\n}\n",
+		"site.webmanifest":      "{\"name\": \"My Site\"}\n\n",
+		"partial.html":          "{{.site.title}}\n<p>Hello</p>\n",
+		"notes.txt":             ";;;\n{\"title\": \"Notes\"}\n;;;\n",
+		"app.js":                "{\n}\n\nrun()\n",
+	}
+	site, cleanup := newTestSite(t, files)
+	defer cleanup()
+
+	if err := site.Generate(); err != nil {
+		t.Fatal(err)
+	}
+	for _, fn := range []string{"manifest.json", "site.webmanifest", "partial.html", "notes.txt", "app.js"} {
+		b, err := ioutil.ReadFile(filepath.Join(site.Dest, fn))
+		if err != nil || string(b) != files[fn] {
+			t.Errorf("Expected %s copied as is [%s] got [%s] %v", fn, files[fn], b, err)
+		}
+	}
+}
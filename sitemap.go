@@ -0,0 +1,160 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+var (
+	MsgSitemapFile    = "Generating Sitemap: %s"
+	MsgSitemapInvalid = "Warning: %s: invalid %s %v, left out of the sitemap"
+)
+
+// Path of the sitemap in the destination directory.
+const sitemapPath = "sitemap.xml"
+
+// Sitemap of the site's pages and posts, see sitemaps.org
+type sitemap struct {
+	XMLName xml.Name     `xml:"http://www.sitemaps.org/schemas/sitemap/0.9 urlset"`
+	XHTML   string       `xml:"xmlns:xhtml,attr,omitempty"`
+	Urls    []sitemapUrl `xml:"url"`
+}
+
+type sitemapUrl struct {
+	Loc        string        `xml:"loc"`
+	LastMod    string        `xml:"lastmod,omitempty"`
+	ChangeFreq string        `xml:"changefreq,omitempty"`
+	Priority   string        `xml:"priority,omitempty"`
+	Alternates []sitemapLink `xml:"xhtml:link"`
+}
+
+// Translation of a page, linked to from each of its translations
+type sitemapLink struct {
+	Rel      string `xml:"rel,attr"`
+	HrefLang string `xml:"hreflang,attr"`
+	Href     string `xml:"href,attr"`
+}
+
+// Namespace of the links to the translations of a page.
+const sitemapXHTML = "http://www.w3.org/1999/xhtml"
+
+// Valid values of the changefreq of a url, see sitemaps.org
+var sitemapChangeFreqs = []string{
+	"always", "hourly", "daily", "weekly", "monthly", "yearly", "never"}
+
+// Helper function that writes a sitemap of all html pages and posts to the
+// destination directory, when enabled with sitemap: true in the _config.yml.
+// Pages may leave themselves out with sitemap: false in their front-end
+// matter.
+//
+// The sitemap_priority and sitemap_changefreq of a page are taken from its
+// front-end matter, or else from the _config.yml, so that posts may be given
+// a lower priority with defaults. Invalid values are left out with a warning.
+// The translations of a page in a multilingual site are listed with it as
+// alternates by language, see localize.
+func (s *Site) writeSitemap() error {
+	if !s.Conf.GetBool("sitemap", false) {
+		return nil
+	}
+
+	pages := []Page{}
+	pages = append(pages, s.pages...)
+	pages = append(pages, s.posts...)
+	pages = append(pages, s.documents()...)
+
+	m := sitemap{}
+	for _, page := range pages {
+		if page.GetString("output_ext") != ".html" {
+			continue
+		}
+		if include, ok := page["sitemap"].(bool); ok && !include {
+			continue
+		}
+		url := sitemapUrl{Loc: s.pageUrl(page)}
+		if date := page.GetDate(); !date.IsZero() {
+			url.LastMod = date.Format("2006-01-02")
+		}
+		url.ChangeFreq = s.sitemapChangeFreq(page)
+		url.Priority = s.sitemapPriority(page)
+		url.Alternates = s.sitemapAlternates(page)
+		if len(url.Alternates) > 0 {
+			m.XHTML = sitemapXHTML
+		}
+		m.Urls = append(m.Urls, url)
+	}
+	sort.Sort(byLoc(m.Urls))
+
+	logf(MsgSitemapFile, sitemapPath)
+	if err := s.writeXml(sitemapPath, m); err != nil {
+		return fmt.Errorf("sitemap: %s", err)
+	}
+	return nil
+}
+
+// Helper function that returns a sitemap option of the page, or else of the
+// site, along with the file it is set in.
+func (s *Site) sitemapOption(page Page, key string) (interface{}, string) {
+	if v, ok := page[key]; ok && v != nil {
+		return v, page.GetPath()
+	}
+	return s.Conf.Get(key), "_config.yml"
+}
+
+// Helper function that returns the changefreq of the page, or an empty string
+// if it has none or it is invalid.
+func (s *Site) sitemapChangeFreq(page Page) string {
+	v, file := s.sitemapOption(page, "sitemap_changefreq")
+	if v == nil {
+		return ""
+	}
+	if freq, ok := v.(string); ok && contains(sitemapChangeFreqs, freq) {
+		return freq
+	}
+	fmt.Printf(MsgSitemapInvalid+"\n", file, "sitemap_changefreq", v)
+	return ""
+}
+
+// Helper function that returns the priority of the page, between 0.0 and 1.0,
+// or an empty string if it has none or it is invalid.
+func (s *Site) sitemapPriority(page Page) string {
+	v, file := s.sitemapOption(page, "sitemap_priority")
+	if v == nil {
+		return ""
+	}
+	p, ok := toFloat(v)
+	if str, isStr := v.(string); isStr {
+		f, err := strconv.ParseFloat(str, 64)
+		p, ok = f, err == nil
+	}
+	if !ok || p < 0 || p > 1 {
+		fmt.Printf(MsgSitemapInvalid+"\n", file, "sitemap_priority", v)
+		return ""
+	}
+	return strconv.FormatFloat(p, 'f', -1, 64)
+}
+
+// Helper function that returns the links to the translations of the page,
+// including itself, ordered by language.
+func (s *Site) sitemapAlternates(page Page) []sitemapLink {
+	translations, _ := page["translations"].(map[string]string)
+	langs := []string{}
+	for lang := range translations {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+
+	links := []sitemapLink{}
+	for _, lang := range langs {
+		links = append(links, sitemapLink{"alternate", lang, s.siteUrl(translations[lang])})
+	}
+	return links
+}
+
+// byLoc sorts the urls of a sitemap alphabetically.
+type byLoc []sitemapUrl
+
+func (u byLoc) Len() int           { return len(u) }
+func (u byLoc) Swap(i, j int)      { u[i], u[j] = u[j], u[i] }
+func (u byLoc) Less(i, j int) bool { return u[i].Loc < u[j].Loc }
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteSitemap(t *testing.T) {
+	site, cleanup := newTestSite(t, map[string]string{
+		"_config.yml":                "url: http://example.com\nsitemap: true\nxml_compact: true\n",
+		"_layouts/default.html":      "{{.content}}",
+		"_posts/2013-02-14-first.md": "---\ntitle: First\n---\nfirst",
+		"about.md":                   "---\ntitle: About\n---\nabout",
+		"hidden.md":                  "---\nsitemap: false\n---\nhidden"})
+	defer cleanup()
+
+	if err := site.Generate(); err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadFile(filepath.Join(site.Dest, "sitemap.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `<?xml version="1.0" encoding="UTF-8"?>` + "\n" +
+		`<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">` +
+		`<url><loc>http://example.com/about.html</loc></url>` +
+		`<url><loc>http://example.com/first/</loc><lastmod>2013-02-14</lastmod></url>` +
+		`</urlset>`
+	if string(b) != expected {
+		t.Errorf("Expected compact sitemap [%s] got [%s]", expected, b)
+	}
+}
+
+func TestWriteSitemapPriority(t *testing.T) {
+	site, cleanup := newTestSite(t, map[string]string{
+		"_config.yml": "url: http://example.com\nsitemap: true\nxml_compact: true\n" +
+			"sitemap_changefreq: monthly\n" +
+			"defaults:\n  - scope:\n      type: posts\n    values:\n      sitemap_priority: 0.5\n",
+		"_layouts/default.html":      "{{.content}}",
+		"_posts/2013-02-14-first.md": "---\ntitle: First\n---\nfirst",
+		"index.md":                   "---\nsitemap_priority: 1\nsitemap_changefreq: daily\n---\nhome",
+		"about.md":                   "---\nsitemap_priority: 1.5\nsitemap_changefreq: sometimes\n---\nabout"})
+	defer cleanup()
+
+	if err := site.Generate(); err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadFile(filepath.Join(site.Dest, "sitemap.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `<?xml version="1.0" encoding="UTF-8"?>` + "\n" +
+		`<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">` +
+		`<url><loc>http://example.com/</loc>` +
+		`<changefreq>daily</changefreq><priority>1</priority></url>` +
+		`<url><loc>http://example.com/about.html</loc></url>` +
+		`<url><loc>http://example.com/first/</loc><lastmod>2013-02-14</lastmod>` +
+		`<changefreq>monthly</changefreq><priority>0.5</priority></url>` +
+		`</urlset>`
+	if string(b) != expected {
+		t.Errorf("Expected sitemap [%s] got [%s]", expected, b)
+	}
+}
+
+func TestWriteXmlIndent(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jkl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	site := Site{Dest: dir, Conf: Config{}}
+	m := sitemap{Urls: []sitemapUrl{{Loc: "http://example.com/"}}}
+	if err := site.writeXml("sitemap.xml", m); err != nil {
+		t.Fatal(err)
+	}
+	b, _ := ioutil.ReadFile(filepath.Join(dir, "sitemap.xml"))
+	expected := `<?xml version="1.0" encoding="UTF-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <url>
+    <loc>http://example.com/</loc>
+  </url>
+</urlset>`
+	if string(b) != expected {
+		t.Errorf("Expected indented xml [%s] got [%s]", expected, b)
+	}
+}
+
+func TestWriteSitemapTranslations(t *testing.T) {
+	site, cleanup := newTestSite(t, map[string]string{
+		"_config.yml":           "url: http://example.com\nsitemap: true\nxml_compact: true\nlanguages: [en, fr]\n",
+		"_layouts/default.html": "{{.content}}",
+		"about.md":              "---\n---\nabout",
+		"fr/about.md":           "---\n---\nà propos"})
+	defer cleanup()
+
+	if err := site.Generate(); err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadFile(filepath.Join(site.Dest, "sitemap.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	links := `<xhtml:link rel="alternate" hreflang="en" href="http://example.com/about.html"></xhtml:link>` +
+		`<xhtml:link rel="alternate" hreflang="fr" href="http://example.com/fr/about.html"></xhtml:link>`
+	expected := `<?xml version="1.0" encoding="UTF-8"?>` + "\n" +
+		`<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9" xmlns:xhtml="http://www.w3.org/1999/xhtml">` +
+		`<url><loc>http://example.com/about.html</loc>` + links + `</url>` +
+		`<url><loc>http://example.com/fr/about.html</loc>` + links + `</url>` +
+		`</urlset>`
+	if string(b) != expected {
+		t.Errorf("Expected sitemap with translations [%s] got [%s]", expected, b)
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Matches the XML prolog and doctype of an svg file, left out when inlined.
+var svgProlog = regexp.MustCompile(`(?s)^\s*(<\?xml.*?\?>\s*)?(<!DOCTYPE[^>]*>\s*)?`)
+
+// Matches the opening svg tag, and its class attribute if any.
+var svgTag = regexp.MustCompile(`<svg\b[^>]*>`)
+var svgClass = regexp.MustCompile(`\sclass="([^"]*)"`)
+
+// Helper function that returns the svg file, relative to the svg_dir in the
+// _config.yml (the source directory by default), for inlining in the page,
+// e.g. {{inline_svg "icons/menu.svg" "icon"}}. The XML prolog is left out,
+// and the class, if given, is added to the svg tag so it can be styled.
+func (s *Site) inlineSvg(path string, class ...string) (string, error) {
+	dir := joinPath(s.Src, s.Conf.GetString("svg_dir"))
+	fn := filepath.Join(dir, filepath.FromSlash(path))
+	if rel, err := filepath.Rel(dir, fn); err != nil || strings.HasPrefix(rel, "..") {
+		return "", fmt.Errorf("inline_svg: %s is outside of %s", path, dir)
+	}
+
+	b, err := s.readFile(fn)
+	if os.IsNotExist(err) {
+		return "", fmt.Errorf("inline_svg: %s not found", path)
+	} else if err != nil {
+		return "", fmt.Errorf("inline_svg: %s: %s", path, err)
+	}
+	svg := strings.TrimSpace(svgProlog.ReplaceAllString(string(b), ""))
+
+	if len(class) > 0 && class[0] != "" {
+		cls := html.EscapeString(strings.Join(class, " "))
+		svg = svgTag.ReplaceAllStringFunc(svg, func(tag string) string {
+			if m := svgClass.FindStringSubmatch(tag); m != nil {
+				return strings.Replace(tag, m[0], ` class="`+m[1]+" "+cls+`"`, 1)
+			}
+			return "<svg" + ` class="` + cls + `"` + tag[len("<svg"):]
+		})
+	}
+	return svg, nil
+}
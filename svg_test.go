@@ -0,0 +1,59 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestInlineSvg(t *testing.T) {
+	site, cleanup := newTestSite(t, map[string]string{
+		"_config.yml":            "svg_dir: _assets\n",
+		"_assets/icons/menu.svg": "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n<!DOCTYPE svg PUBLIC \"-//W3C//DTD SVG 1.1//EN\" \"svg11.dtd\">\n<svg viewBox=\"0 0 1 1\"><path d=\"M0\"/></svg>\n",
+		"_assets/logo.svg":       "<svg class=\"logo\" viewBox=\"0 0 1 1\"></svg>"})
+	defer cleanup()
+
+	tests := []struct {
+		path, class, svg string
+	}{
+		{"icons/menu.svg", "", `<svg viewBox="0 0 1 1"><path d="M0"/></svg>`},
+		{"icons/menu.svg", "icon", `<svg class="icon" viewBox="0 0 1 1"><path d="M0"/></svg>`},
+		{"logo.svg", "icon big", `<svg class="logo icon big" viewBox="0 0 1 1"></svg>`},
+	}
+	for _, test := range tests {
+		svg, err := site.inlineSvg(test.path, test.class)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if svg != test.svg {
+			t.Errorf("Expected [%s] with class [%s] to be inlined as [%s] got [%s]", test.path, test.class, test.svg, svg)
+		}
+	}
+
+	for path, msg := range map[string]string{
+		"icons/missing.svg": "inline_svg: icons/missing.svg not found",
+		"../_config.yml":    "is outside of",
+	} {
+		if _, err := site.inlineSvg(path); err == nil || !strings.Contains(err.Error(), msg) {
+			t.Errorf("Expected error [%s] for [%s] got %v", msg, path, err)
+		}
+	}
+}
+
+func TestInlineSvgTemplate(t *testing.T) {
+	site, cleanup := newTestSite(t, map[string]string{
+		"_config.yml":           "",
+		"_layouts/default.html": "{{.content}}",
+		"menu.svg":              "<svg></svg>",
+		"index.html":            "---\n---\n<nav>{{inline_svg \"menu.svg\" \"icon\"}}</nav>"})
+	defer cleanup()
+
+	if err := site.Generate(); err != nil {
+		t.Fatal(err)
+	}
+	b, _ := ioutil.ReadFile(filepath.Join(site.Dest, "index.html"))
+	if string(b) != `<nav><svg class="icon"></svg></nav>` {
+		t.Errorf("Expected the svg inlined in the page, got [%s]", b)
+	}
+}
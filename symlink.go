@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	MsgSkipSymlink  = "Warning: skipping the symlinked directory %s, set follow_symlinks to read it"
+	MsgSymlinkCycle = "Warning: skipping the symlinked directory %s, it links to one of its parents"
+)
+
+// Returns True if the file is a symlink to a directory. filepath.Walk
+// doesn't follow symlinks, so such a directory would otherwise be read as a
+// single file.
+func isSymlinkedDir(fn string, fi os.FileInfo) bool {
+	if fi.Mode()&os.ModeSymlink == 0 {
+		return false
+	}
+	target, err := os.Stat(fn)
+	return err == nil && target.IsDir()
+}
+
+// Helper function that walks a symlinked directory of the source as if it
+// were a directory of its own, when enabled with follow_symlinks: true in the
+// _config.yml, reporting every file by its path through the symlink. A
+// symlink to one of its own parents is skipped, rather than walked forever.
+// Symlinked files are always read, as they are opened like any other file.
+func (s *Site) walkSymlink(fn string, walkFn filepath.WalkFunc) error {
+	rel, _ := filepath.Rel(s.Src, fn)
+	if !s.Conf.GetBool("follow_symlinks", false) {
+		fmt.Printf(MsgSkipSymlink+"\n", rel)
+		return nil
+	}
+
+	target, err := filepath.EvalSymlinks(fn)
+	if err != nil {
+		return walkFn(fn, nil, err)
+	}
+	parent, err := filepath.EvalSymlinks(filepath.Dir(fn))
+	if err != nil {
+		return walkFn(fn, nil, err)
+	}
+	sep := string(filepath.Separator)
+	if strings.HasPrefix(parent+sep, target+sep) {
+		fmt.Printf(MsgSymlinkCycle+"\n", rel)
+		return nil
+	}
+
+	return filepath.Walk(target, func(path string, fi os.FileInfo, err error) error {
+		sub, _ := filepath.Rel(target, path)
+		return walkFn(filepath.Join(fn, sub), fi, err)
+	})
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFollowSymlinks(t *testing.T) {
+	shared, err := ioutil.TempDir("", "jkl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(shared)
+	os.MkdirAll(filepath.Join(shared, "img"), 0755)
+	ioutil.WriteFile(filepath.Join(shared, "img", "logo.png"), []byte("logo"), 0644)
+	ioutil.WriteFile(filepath.Join(shared, "shared.html"), []byte("---\n---\nshared"), 0644)
+
+	for _, follow := range []bool{false, true} {
+		site, cleanup := newTestSiteFlags(t, map[string]string{
+			"_config.yml":           "",
+			"_layouts/default.html": "{{.content}}",
+			"index.html":            "home"}, Config{"follow_symlinks": follow})
+		defer cleanup()
+
+		os.Symlink(shared, filepath.Join(site.Src, "assets"))
+		os.Symlink(site.Src, filepath.Join(site.Src, "loop"))
+		if err := site.Reload(); err != nil {
+			t.Fatal(err)
+		}
+		if err := site.Generate(); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, fn := range []string{"assets/img/logo.png", "assets/shared.html"} {
+			if _, err := os.Stat(filepath.Join(site.Dest, fn)); (err == nil) != follow {
+				t.Errorf("Expected %s to be generated %v with follow_symlinks %v, got %v", fn, follow, follow, err)
+			}
+		}
+		if _, err := os.Stat(filepath.Join(site.Dest, "loop")); err == nil {
+			t.Errorf("Expected the symlink to the source to be skipped with follow_symlinks %v", follow)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+var MsgTaxonomyFile = "Generating Taxonomy: %s"
+
+// A tag or category, and its posts, as written to JSON for front-ends that
+// render the site's taxonomy themselves.
+type taxonomyJson struct {
+	Name  string         `json:"name"`
+	Slug  string         `json:"slug"`
+	Posts []taxonomyPost `json:"posts"`
+}
+
+type taxonomyPost struct {
+	Title   string `json:"title"`
+	Url     string `json:"url"`
+	Date    string `json:"date"`
+	Excerpt string `json:"excerpt"`
+}
+
+// Helper function that writes the posts of every tag and category to JSON,
+// when enabled with taxonomy_json: true in the _config.yml, e.g. to
+// /tags/go.json and /categories/tech.json. Posts are listed newest first.
+func (s *Site) writeTaxonomyJson() error {
+	if !s.Conf.GetBool("taxonomy_json", false) {
+		return nil
+	}
+	for dir, names := range map[string]func(Page) []string{
+		"tags":       Page.GetTags,
+		"categories": Page.GetCategories,
+	} {
+		groups, display := s.aggregate(names)
+		for slug, posts := range groups {
+			if err := s.writeTaxonomy(dir, slug, display[slug], posts); err != nil {
+				return fmt.Errorf("taxonomy_json: %s", err)
+			}
+		}
+	}
+	return nil
+}
+
+// Helper function that writes the posts of a single tag or category to JSON.
+func (s *Site) writeTaxonomy(dir, slug, name string, posts []Page) error {
+	posts = append([]Page{}, posts...)
+	sort.Stable(byDate(posts))
+
+	t := taxonomyJson{Name: name, Slug: slug, Posts: []taxonomyPost{}}
+	for _, post := range posts {
+		t.Posts = append(t.Posts, taxonomyPost{
+			Title:   post.GetTitle(),
+			Url:     s.pageUrl(post),
+			Date:    post.GetDate().Format(time.RFC3339),
+			Excerpt: post.GetString("excerpt"),
+		})
+	}
+	b, err := json.Marshal(t)
+	if err != nil {
+		return err
+	}
+
+	url := dir + "/" + slug + ".json"
+	f, err := s.destPath(url)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(f), 0755); err != nil {
+		return err
+	}
+	logf(MsgTaxonomyFile, url)
+	return s.writeOutput(f, b, 0644)
+}
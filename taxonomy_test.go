@@ -0,0 +1,38 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteTaxonomyJson(t *testing.T) {
+	site, cleanup := newTestSite(t, map[string]string{
+		"_config.yml":                 "baseurl: /blog\ntaxonomy_json: true\n",
+		"_layouts/default.html":       "{{.content}}",
+		"_posts/2013-02-14-first.md":  "---\ntitle: \"First <b>\"\ntags: [Go]\ncategories: [Tech]\n---\nfirst & foremost",
+		"_posts/2013-02-20-second.md": "---\ntitle: Second\ntags: [go, Web]\n---\nsecond"})
+	defer cleanup()
+
+	if err := site.Generate(); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := ioutil.ReadFile(filepath.Join(site.Dest, "tags", "go.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"name":"go","slug":"go","posts":[` +
+		`{"title":"Second","url":"/blog/second/","date":"2013-02-20T00:00:00Z","excerpt":"second"},` +
+		`{"title":"First \u003cb\u003e","url":"/blog/Tech/first/","date":"2013-02-14T00:00:00Z","excerpt":"first \u0026 foremost"}]}`
+	if string(b) != expected {
+		t.Errorf("Expected tag json [%s] got [%s]", expected, b)
+	}
+
+	var category taxonomyJson
+	b, _ = ioutil.ReadFile(filepath.Join(site.Dest, "categories", "tech.json"))
+	if err := json.Unmarshal(b, &category); err != nil || category.Name != "Tech" || len(category.Posts) != 1 {
+		t.Errorf("Expected the category's posts, got [%s] %v", b, err)
+	}
+}
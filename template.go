@@ -1,7 +1,11 @@
 package main
 
 import (
+	"errors"
+	"fmt"
 	"net/url"
+	"reflect"
+	"regexp"
 	"strings"
 	"time"
 )
@@ -9,24 +13,52 @@ import (
 // Additional functions available in Jekyll templates
 var funcMap = map[string]interface{}{
 
+	"append":            appendTo,
 	"capitalize":        capitalize,
 	"date_to_string":    dateToString,
 	"date_to_xmlschema": dateToXmlSchema,
+	"dict":              dict,
 	"downcase":          lower,
 	"eq":                eq,
+	"files_in":          filesIn,
+	"include_remote":    includeRemote,
+	"inline_svg":        inlineSvg,
+	"markdownify":       markdownify,
 	"newline_to_br":     newlineToBreak,
+	"number_of_words":   numberOfWords,
 	"replace":           replace,
 	"replace_first":     replaceFirst,
+	"seo":               seoTags,
+	"seq":               seq,
 	"remove":            remove,
 	"remove_first":      removeFirst,
+	"render_includes":   renderIncludes,
 	"split":             split,
+	"strip_html":        stripHtml,
 	"strip_newlines":    stripNewlines,
+	"t":                 translateKey,
 	"truncate":          truncate,
 	"truncatewords":     truncateWords,
 	"upcase":            upper,
 	"url_encode":        urlEncode,
 }
 
+// Appends values to the end of a list, returning a new list. The list may be
+// a slice of any type, or nil to start a new list.
+func appendTo(list interface{}, values ...interface{}) ([]interface{}, error) {
+	items := []interface{}{}
+	if list != nil {
+		v := reflect.ValueOf(list)
+		if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+			return nil, fmt.Errorf("append: cannot append to type %s", v.Type())
+		}
+		for i := 0; i < v.Len(); i++ {
+			items = append(items, v.Index(i).Interface())
+		}
+	}
+	return append(items, values...), nil
+}
+
 // Capitalize words in the input sentence
 func capitalize(s string) string {
 	return strings.Title(s)
@@ -37,6 +69,25 @@ func eq(v1 interface{}, v2 interface{}) bool {
 	return v1 == v2
 }
 
+// Builds a map from a list of alternating keys and values, for example
+// to pass several values to an included template:
+//
+//	{{template "card.html" dict "title" .page.title "url" .page.url}}
+func dict(pairs ...interface{}) (map[string]interface{}, error) {
+	if len(pairs)%2 != 0 {
+		return nil, errors.New("dict: expecting an even number of arguments")
+	}
+	m := make(map[string]interface{}, len(pairs)/2)
+	for i := 0; i < len(pairs); i += 2 {
+		key, ok := pairs[i].(string)
+		if !ok {
+			return nil, fmt.Errorf("dict: key %v is not a string", pairs[i])
+		}
+		m[key] = pairs[i+1]
+	}
+	return m, nil
+}
+
 // Converts a date to a string
 func dateToString(date time.Time) string {
 	return date.Format("Jan 2, 2006")
@@ -77,6 +128,47 @@ func replaceFirst(s, old, new string) string {
 	return strings.Replace(s, old, new, 1)
 }
 
+// Fetches a remote include. This is replaced when the templates are compiled
+// if include_remote is enabled in the _config.yml, and fails until then.
+func includeRemote(url string) (string, error) {
+	return "", errors.New("include_remote: not enabled in the _config.yml")
+}
+
+// Lists the static files in a directory of the source. This is replaced when
+// the templates are compiled.
+func filesIn(dir string, recursive ...bool) ([]map[string]interface{}, error) {
+	return nil, errors.New("files_in: not available")
+}
+
+// Returns an svg file for inlining in the page. This is replaced when the
+// templates are compiled.
+func inlineSvg(path string, class ...string) (string, error) {
+	return "", errors.New("inline_svg: not available")
+}
+
+// Converts a string of Markdown to html with the engine of the site. This is
+// replaced when each page is rendered.
+func markdownify(markup string, mode ...string) (string, error) {
+	return "", errors.New("markdownify: not available")
+}
+
+// Renders the _includes configured for a position of the layout, such as
+// head. This is replaced when each page is rendered.
+func renderIncludes(position string) (string, error) {
+	return "", nil
+}
+
+// Returns the meta tags describing the page to search engines and social
+// networks. This is replaced when each page is rendered.
+func seoTags() string {
+	return ""
+}
+
+// Builds a list from the input values
+func seq(values ...interface{}) []interface{} {
+	return values
+}
+
 // Split a string on a matching pattern
 func split(s, pattern string) []string {
 	return strings.Split(s, pattern)
@@ -87,10 +179,32 @@ func stripNewlines(s string) string {
 	return strings.Replace(s, "\n", "", -1)
 }
 
-// Truncate a string down to x characters
+// Matches the elements and comments of html that aren't text
+var htmlNonText = regexp.MustCompile(`(?is)<script\b.*?</script>|<style\b.*?</style>|<!--.*?-->`)
+
+// Remove all html tags, leaving the text, with its entities decoded and its
+// whitespace collapsed
+func stripHtml(s string) string {
+	return strings.Join(strings.Fields(plainText(htmlNonText.ReplaceAllString(s, " "))), " ")
+}
+
+// Count the words in a string, without its html tags, the same as the
+// word_count of a page
+func numberOfWords(s string) int {
+	return countWords(stripHtml(s))
+}
+
+// Translates a key to the language of the page. This is replaced when each
+// page is rendered, and returns the key itself until then.
+func translateKey(key string) string {
+	return key
+}
+
+// Truncate a string down to x characters, without splitting a multi-byte
+// character
 func truncate(s string, x int) string {
-	if len(s) > x {
-		return s[0:x]
+	if r := []rune(s); len(r) > x {
+		return string(r[0:x])
 	}
 	return s
 }
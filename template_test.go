@@ -1,7 +1,10 @@
 package main
 
 import (
+	"bytes"
+	"reflect"
 	"testing"
+	"text/template"
 )
 
 func TestUrlEncode(t *testing.T) {
@@ -13,3 +16,117 @@ func TestUrlEncode(t *testing.T) {
 		t.Errorf("Expected [%v] got [%v]", expected, result)
 	}
 }
+
+func TestDict(t *testing.T) {
+	m, err := dict("title", "Hello", "count", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m["title"] != "Hello" || m["count"] != 2 {
+		t.Errorf("Expected map with title and count got %v", m)
+	}
+
+	if _, err := dict("title"); err == nil {
+		t.Errorf("Expected error for odd number of arguments")
+	}
+	if _, err := dict(1, "one"); err == nil {
+		t.Errorf("Expected error for non-string key")
+	}
+}
+
+func TestSeqAndAppend(t *testing.T) {
+	list := seq("a", "b")
+	if !reflect.DeepEqual(list, []interface{}{"a", "b"}) {
+		t.Errorf("Expected list [a b] got %v", list)
+	}
+
+	result, err := appendTo(list, "c")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(result, []interface{}{"a", "b", "c"}) {
+		t.Errorf("Expected list [a b c] got %v", result)
+	}
+	if len(list) != 2 {
+		t.Errorf("Expected append to leave the original list unchanged, got %v", list)
+	}
+
+	result, err = appendTo([]string{"x"}, "y")
+	if err != nil || !reflect.DeepEqual(result, []interface{}{"x", "y"}) {
+		t.Errorf("Expected list [x y] got %v %v", result, err)
+	}
+	result, err = appendTo(nil, "z")
+	if err != nil || !reflect.DeepEqual(result, []interface{}{"z"}) {
+		t.Errorf("Expected list [z] got %v %v", result, err)
+	}
+	if _, err := appendTo("x", "y"); err == nil {
+		t.Errorf("Expected error appending to a string")
+	}
+}
+
+func TestDictInTemplate(t *testing.T) {
+	src := `{{define "card"}}{{.title}}:{{range .tags}}[{{.}}]{{end}}{{end}}` +
+		`{{$tags := seq "go"}}{{$tags := append $tags "web"}}` +
+		`{{template "card" dict "title" "Hello" "tags" $tags}}`
+	tmpl, err := template.New("test").Funcs(funcMap).Parse(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, nil); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "Hello:[go][web]" {
+		t.Errorf("Expected rendered template [Hello:[go][web]] got [%s]", buf.String())
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	tests := map[int]string{3: "crè", 5: "crème", 10: "crème"}
+	for x, expected := range tests {
+		if s := truncate("crème", x); s != expected {
+			t.Errorf("Expected truncated [%s] got [%s] for %d characters", expected, s, x)
+		}
+	}
+}
+
+func TestStripHtml(t *testing.T) {
+	tests := map[string]string{
+		"<p>Hello <em>world</em></p>":                      "Hello world",
+		"<h1>Fish &amp; Chips</h1>\n\n<p>Caf&eacute;</p>":  "Fish & Chips Café",
+		"<style>p { color: red }</style><p>Text</p>":       "Text",
+		"<script>var a = 1 < 2;</script>Text<!-- note -->": "Text",
+		"<p>日本語のテキスト</p>":                                  "日本語のテキスト",
+		"no markup":                                        "no markup",
+	}
+	for in, expected := range tests {
+		if out := stripHtml(in); out != expected {
+			t.Errorf("Expected strip_html of [%s] to be [%s] got [%s]", in, expected, out)
+		}
+	}
+}
+
+func TestNumberOfWords(t *testing.T) {
+	tests := map[string]int{
+		"<p>Hello <em>world</em>, again</p>":    3,
+		"<p>Fish &amp; Chips</p>":               2,
+		"<script>var a = 1;</script><p>One</p>": 1,
+		"<p>日本語</p>":                            3,
+		"":                                      0,
+	}
+	for in, expected := range tests {
+		if n := numberOfWords(in); n != expected {
+			t.Errorf("Expected number_of_words of [%s] to be %d got %d", in, expected, n)
+		}
+	}
+
+	var buf bytes.Buffer
+	templ := template.Must(template.New("t").Funcs(funcMap).Parse(`{{.content | strip_html}} ({{.content | number_of_words}})`))
+	if err := templ.Execute(&buf, map[string]string{"content": "<p>Two <b>words</b></p>"}); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "Two words (2)" {
+		t.Errorf("Expected [Two words (2)] got [%s]", buf.String())
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+)
+
+// Matches the templates included by a template, e.g. {{template "nav.html" .}}
+var templateRef = regexp.MustCompile(`{{-?\s*template\s+"([^"]+)"`)
+
+// Matches the data files used by a template, e.g. {{.site.data.authors}}
+var dataRef = regexp.MustCompile(`\.site\.data\.([\pL\pN_]+)`)
+
+// The dependencies of a page, post or document: the layout it is rendered
+// with, the includes and data files used by the page or its layout, and the
+// file it is written to.
+type pageTrace struct {
+	Path     string   `json:"path"`
+	Output   string   `json:"output"`
+	Layout   string   `json:"layout,omitempty"`
+	Includes []string `json:"includes"`
+	Data     []string `json:"data"`
+}
+
+// Helper function that writes the dependencies of every page, post and
+// document of the site as JSON, sorted by path, to help understand why a
+// file is, or isn't, generated again.
+func (s *Site) writeTrace(w io.Writer) error {
+	templates := map[string]string{}
+	for _, fn := range s.layouts {
+		rel, _ := filepath.Rel(s.Src, fn)
+		templates[filepath.Base(fn)] = rel
+	}
+
+	pages := []Page{}
+	pages = append(pages, s.pages...)
+	pages = append(pages, s.posts...)
+	for _, c := range s.collections {
+		pages = append(pages, c.docs...)
+	}
+
+	traces := []pageTrace{}
+	for _, page := range pages {
+		t := pageTrace{Path: page.GetPath(), Output: page.OutputPath()}
+		includes, data := map[string]bool{}, map[string]bool{}
+
+		// only pages that aren't converted are templates themselves
+		if !isConverted(s.converters, page.GetExt()) && !page.IsRaw() {
+			s.traceTemplate(page.GetContent(), templates, includes, data)
+		}
+		if layout := page.GetLayout(); layout != "" && layout != "nil" {
+			name := appendExt(layout, ".html")
+			t.Layout = templates[name]
+			s.traceTemplate("{{template \""+name+"\"}}", templates, includes, data)
+			delete(includes, t.Layout)
+		}
+
+		t.Includes = sortedKeys(includes)
+		t.Data = sortedKeys(data)
+		traces = append(traces, t)
+	}
+	sort.Sort(byTracePath(traces))
+
+	b, err := json.MarshalIndent(traces, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(b, '\n'))
+	return err
+}
+
+// Helper function that adds the templates and data files used by the
+// template, and those used by the templates it includes, to the sets.
+func (s *Site) traceTemplate(src string, templates map[string]string, includes, data map[string]bool) {
+	for _, m := range dataRef.FindAllStringSubmatch(src, -1) {
+		for _, ext := range []string{".yml", ".yaml"} {
+			rel := filepath.Join(dataDir, m[1]+ext)
+			if _, err := os.Stat(filepath.Join(s.Src, rel)); err == nil {
+				data[rel] = true
+			}
+		}
+	}
+	for _, m := range templateRef.FindAllStringSubmatch(src, -1) {
+		rel, ok := templates[m[1]]
+		if !ok || includes[rel] {
+			continue
+		}
+		includes[rel] = true
+		if b, err := s.readFile(filepath.Join(s.Src, rel)); err == nil {
+			s.traceTemplate(string(b), templates, includes, data)
+		}
+	}
+}
+
+// Helper function that returns the keys of the set, sorted.
+func sortedKeys(set map[string]bool) []string {
+	keys := []string{}
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// byTracePath sorts the dependencies of pages by the path of the page.
+type byTracePath []pageTrace
+
+func (t byTracePath) Len() int           { return len(t) }
+func (t byTracePath) Swap(i, j int)      { t[i], t[j] = t[j], t[i] }
+func (t byTracePath) Less(i, j int) bool { return t[i].Path < t[j].Path }
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestWriteTrace(t *testing.T) {
+	site, cleanup := newTestSite(t, map[string]string{
+		"_config.yml":                "",
+		"_layouts/default.html":      "{{template \"head.html\" .}}{{.content}}",
+		"_layouts/bare.html":         "{{.content}}",
+		"_includes/head.html":        "{{template \"nav.html\" .}}{{.site.data.meta}}",
+		"_includes/nav.html":         "{{range .site.data.links}}{{.}}{{end}}{{template \"nav.html\"}}",
+		"_includes/footer.html":      "footer",
+		"_data/links.yml":            "[a]",
+		"_data/meta.yaml":            "b",
+		"_posts/2013-02-14-first.md": "---\nlayout: bare\n---\n{{template \"footer.html\"}}",
+		"about.html":                 "---\n---\n{{template \"footer.html\"}}{{.site.data.missing}}"})
+	defer cleanup()
+
+	var buf bytes.Buffer
+	if err := site.writeTrace(&buf); err != nil {
+		t.Fatal(err)
+	}
+	expected := `[
+  {
+    "path": "_posts/2013-02-14-first.md",
+    "output": "first/index.html",
+    "layout": "_layouts/bare.html",
+    "includes": [],
+    "data": []
+  },
+  {
+    "path": "about.html",
+    "output": "about.html",
+    "layout": "_layouts/default.html",
+    "includes": [
+      "_includes/footer.html",
+      "_includes/head.html",
+      "_includes/nav.html"
+    ],
+    "data": [
+      "_data/links.yml",
+      "_data/meta.yaml"
+    ]
+  }
+]
+`
+	if buf.String() != expected {
+		t.Errorf("Expected trace [%s] got [%s]", expected, buf.String())
+	}
+}
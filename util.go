@@ -2,10 +2,15 @@ package main
 
 import (
 	"bytes"
+	"fmt"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/htmlindex"
 	"io"
+	"io/ioutil"
+	"net/url"
 	"os"
-	"os/exec"
 	"path/filepath"
+	"regexp"
 	"strings"
 )
 
@@ -18,22 +23,89 @@ func appendExt(fn, ext string) string {
 	return fn + ext
 }
 
-// Copies a file to the specified directory. It will also create any necessary
-// sub directories.
-//
-// TODO use native Go code to copy file to enable Windows support
-func copyTo(from, to string) error {
+// Copies a file to the specified path, with the same permissions, streaming
+// it through the buffer rather than reading it into memory. It will also
+// create any necessary sub directories.
+func copyTo(from, to string, buf []byte) error {
 	os.MkdirAll(filepath.Dir(to), 0755)
-	if err := exec.Command("cp", from, to).Run(); err != nil {
+	src, err := os.Open(from)
+	if err != nil {
 		return err
 	}
-	return nil
+	defer src.Close()
+	fi, err := src.Stat()
+	if err != nil {
+		return err
+	}
+
+	dst, err := os.OpenFile(to, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, fi.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.CopyBuffer(dst, src, buf); err != nil {
+		dst.Close()
+		return err
+	}
+	return dst.Close()
 }
 
-// Returns True if a file has YAML front-end matter.
-func hasMatter(fn string) bool {
+// Returns True if two files have the same content, comparing them a buffer
+// at a time. A file that can't be read, or is missing, is never the same.
+func sameContent(a, b string, size int) bool {
+	fa, err := os.Open(a)
+	if err != nil {
+		return false
+	}
+	defer fa.Close()
+	fb, err := os.Open(b)
+	if err != nil {
+		return false
+	}
+	defer fb.Close()
+	if ia, err := fa.Stat(); err != nil {
+		return false
+	} else if ib, err := fb.Stat(); err != nil || ia.Size() != ib.Size() {
+		return false
+	}
+
+	bufa, bufb := make([]byte, size), make([]byte, size)
+	for {
+		na, erra := io.ReadFull(fa, bufa)
+		nb, errb := io.ReadFull(fb, bufb)
+		if na != nb || !bytes.Equal(bufa[:na], bufb[:nb]) {
+			return false
+		}
+		if erra == io.EOF || erra == io.ErrUnexpectedEOF {
+			return errb == erra
+		}
+		if erra != nil || errb != nil {
+			return false
+		}
+	}
+}
+
+// Returns True if a file has YAML or JSON front-end matter. Stylesheets,
+// scripts, data and other text files must start with a --- block, while
+// JSON front-end matter is only sniffed in markup. Only Markdown files may
+// start with a bare JSON object, followed by a blank line, so that static
+// files such as a manifest.json, or html starting with {{, aren't taken for
+// pages. Converted is True if the file is converted, such as Markdown.
+func hasMatter(fn string, converted bool) bool {
 	sample, _ := sniff(strings.TrimLeft(fn, " \t\n"), 4)
-	return bytes.Equal(sample, []byte("---\n"))
+	switch {
+	case bytes.Equal(sample, []byte("---\n")):
+		return true
+	case bytes.Equal(sample, []byte(";;;\n")):
+		return isHtml(fn) || converted
+	case bytes.HasPrefix(sample, []byte("{")) && isMarkdown(fn):
+		b, err := ioutil.ReadFile(fn)
+		if err != nil {
+			return false
+		}
+		_, _, err = parseJSONObject(b)
+		return err == nil
+	}
+	return false
 }
 
 // Returns True if the file is a temp file (starts with . or ends with ~).
@@ -69,6 +141,16 @@ func isHtml(fn string) bool {
 	return false
 }
 
+// Returns True if the file is a text-based file, such as markup, a
+// stylesheet or a script.
+func isText(fn string) bool {
+	switch strings.ToLower(filepath.Ext(fn)) {
+	case ".html", ".htm", ".css", ".js", ".json", ".webmanifest", ".xml", ".rss", ".atom", ".svg", ".txt", ".md", ".markdown":
+		return true
+	}
+	return false
+}
+
 // Returns True if the markup is Markdown.
 func isMarkdown(fn string) bool {
 	switch filepath.Ext(fn) {
@@ -78,27 +160,55 @@ func isMarkdown(fn string) bool {
 	return false
 }
 
-// Returns True if the specified file is a Page.
-func isPage(fn string) bool {
+// Returns True if the file is a Sass stylesheet.
+func isSass(fn string) bool {
+	switch filepath.Ext(fn) {
+	case ".scss", ".sass":
+		return true
+	}
+	return false
+}
+
+// Returns True if the file is a partial (starts with _), meant to be
+// included by other files rather than generated on its own.
+func isPartial(fn string) bool {
+	return strings.HasPrefix(filepath.Base(fn), "_")
+}
+
+// Returns True if the specified file is a Page, by its path and type, where
+// converted is True for converted markup, such as Markdown. The file must
+// also have front-end matter, see hasMatter.
+func isPage(fn string, converted bool) bool {
 	switch {
 	case strings.HasPrefix(fn, "_"):
 		return false
-	case !isMarkdown(fn) && !isHtml(fn):
-		return false
-	case !hasMatter(fn):
+	case !converted && !isText(fn):
 		return false
 	}
 	return true
 }
 
-// Returns True if the specified file is a Post.
-func isPost(fn string) bool {
+// Returns True if the specified file is a Post, by its path and type, where
+// converted is True for converted markup, such as Markdown. The file must
+// also have front-end matter, see hasMatter.
+func isPost(fn string, converted bool) bool {
 	switch {
 	case !strings.HasPrefix(fn, "_posts"):
 		return false
-	case !isMarkdown(fn):
+	case !converted:
+		return false
+	}
+	return true
+}
+
+// Returns True if the specified file is a Draft, by its path and type, where
+// converted is True for converted markup, such as Markdown. The file must
+// also have front-end matter, see hasMatter.
+func isDraft(fn string, converted bool) bool {
+	switch {
+	case !strings.HasPrefix(fn, "_drafts"):
 		return false
-	case !hasMatter(fn):
+	case !converted:
 		return false
 	}
 	return true
@@ -113,26 +223,19 @@ func isStatic(fn string) bool {
 	return !strings.HasPrefix(fn, "_")
 }
 
-// Returns an recursive list of all child directories
-func dirs(path string) (paths []string) {
-	site := filepath.Join(path, "_site")
-	filepath.Walk(path, func(fn string, fi os.FileInfo, err error) error {
-		switch {
-		case err != nil:
-			return nil
-		case fi.IsDir() && isHiddenOrTemp(fn):
-			return filepath.SkipDir
-		case fi.IsDir() == false:
-			return nil
-		case strings.HasPrefix(fn, site):
-			return nil
+// Returns True if the path matches any of the glob patterns. A pattern that
+// matches a parent directory also matches everything inside that directory.
+func matchAny(patterns []string, path string) bool {
+	path = filepath.Clean(path)
+	for _, pattern := range patterns {
+		pattern = filepath.Clean(strings.TrimLeft(pattern, "/"))
+		for p := path; p != "." && p != string(filepath.Separator); p = filepath.Dir(p) {
+			if ok, _ := filepath.Match(pattern, p); ok {
+				return true
+			}
 		}
-
-		paths = append(paths, fn)
-		return nil
-	})
-
-	return
+	}
+	return false
 }
 
 // Removes the files extension. If the file has no extension the string is
@@ -144,6 +247,35 @@ func removeExt(fn string) string {
 	return fn
 }
 
+// Looks up the value at a dotted path, such as nav.home, in nested maps as
+// parsed from YAML.
+func lookupPath(m map[string]interface{}, path string) (interface{}, bool) {
+	var v interface{} = m
+	for _, key := range strings.Split(path, ".") {
+		var ok bool
+		switch m := v.(type) {
+		case map[string]interface{}:
+			v, ok = m[key]
+		case Config:
+			v, ok = m[key]
+		case map[interface{}]interface{}:
+			v, ok = m[key]
+		}
+		if !ok {
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+// Joins the path to the base directory, unless the path is already absolute.
+func joinPath(base, path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(base, path)
+}
+
 // Replaces the files extension with the new extension.
 func replaceExt(fn, ext string) string {
 	return removeExt(fn) + ext
@@ -154,6 +286,97 @@ func prettyUrl(fn string) string {
 	return strings.TrimSuffix(fn, "index.html")
 }
 
+// Converts a permalink to the relative path of the generated file. Permalinks
+// ending in a slash are written to the index.html file in that directory.
+func permalinkUrl(permalink string) string {
+	url := strings.TrimLeft(permalink, "/")
+	if url == "" || strings.HasSuffix(url, "/") {
+		url += "index.html"
+	}
+	return url
+}
+
+// Matches runs of consecutive slashes.
+var slashes = regexp.MustCompile(`//+`)
+
+// Replaces runs of consecutive slashes with a single slash.
+func collapseSlashes(url string) string {
+	return slashes.ReplaceAllString(url, "/")
+}
+
+// Matches runs of characters that are not letters or digits.
+var nonAlphanumeric = regexp.MustCompile(`[^\pL\pN]+`)
+
+// Converts a string to a lowercase, URL friendly slug where runs of
+// characters other than letters and digits are replaced with a hyphen.
+// e.g. "Hello, World!" becomes hello-world
+func slugify(s string) string {
+	return strings.Trim(nonAlphanumeric.ReplaceAllString(strings.ToLower(s), "-"), "-")
+}
+
+// Returns ErrBadPermalink if the URL contains any .. segments that could
+// resolve to a file outside of the destination directory.
+func checkUrl(url string) error {
+	for _, part := range strings.FieldsFunc(url, isSeparator) {
+		if part == ".." {
+			return ErrBadPermalink
+		}
+	}
+	return nil
+}
+
+// Returns True if the character separates the segments of a path or URL.
+func isSeparator(r rune) bool {
+	return r == '/' || r == '\\'
+}
+
+// Matches the src and href attributes of HTML elements.
+var urlAttr = regexp.MustCompile(`(src|href)=("[^"]*"|'[^']*')`)
+
+// Rewrites the relative src and href attributes in an HTML fragment so that
+// they are resolved against the base URL. Absolute URLs, root-relative paths
+// and fragments are left unchanged.
+func rewriteUrls(html, base string) string {
+	b, err := url.Parse(base)
+	if err != nil {
+		return html
+	}
+
+	return urlAttr.ReplaceAllStringFunc(html, func(attr string) string {
+		m := urlAttr.FindStringSubmatch(attr)
+		quote, val := m[2][:1], m[2][1:len(m[2])-1]
+		switch {
+		case val == "":
+			return attr
+		case strings.HasPrefix(val, "/"), strings.HasPrefix(val, "#"):
+			return attr
+		}
+
+		ref, err := url.Parse(val)
+		if err != nil || ref.IsAbs() {
+			return attr
+		}
+		return m[1] + "=" + quote + b.ResolveReference(ref).String() + quote
+	})
+}
+
+// Helper function that looks up a character encoding by name, such as
+// iso-8859-1 or windows-1252. Returns nil for UTF-8, which needs no
+// decoding, and an error if the encoding is unknown.
+func parseEncoding(name string) (encoding.Encoding, error) {
+	if name == "" {
+		return nil, nil
+	}
+	enc, err := htmlindex.Get(name)
+	if err != nil {
+		return nil, fmt.Errorf("Unknown encoding %q", name)
+	}
+	if canonical, _ := htmlindex.Name(enc); canonical == "utf-8" {
+		return nil, nil
+	}
+	return enc, nil
+}
+
 // sniff will extract the first N bytes from a file and return the results.
 //
 // This is used, for example, by the hasMatter function to check and see
@@ -173,3 +396,44 @@ func sniff(fn string, n int) ([]byte, error) {
 
 	return b, nil
 }
+
+// Meta tag that asks search engines not to index a page, such as a draft.
+const noindexTag = `<meta name="robots" content="noindex">`
+
+// Helper function that injects a tag into the head of an html page, just
+// before the closing head tag, or at the start of the page if it has none.
+func injectHead(html []byte, tag string) []byte {
+	i := bytes.Index(lowerASCII(html), []byte("</head>"))
+	if i < 0 {
+		return append([]byte(tag), html...)
+	}
+
+	b := make([]byte, 0, len(html)+len(tag))
+	b = append(b, html[:i]...)
+	b = append(b, tag...)
+	return append(b, html[i:]...)
+}
+
+// Returns a copy of the bytes with only the ASCII letters lowered, so that,
+// unlike bytes.ToLower, an index into the copy is also an index into the
+// original, whatever characters it contains.
+func lowerASCII(b []byte) []byte {
+	lower := make([]byte, len(b))
+	for i, c := range b {
+		if 'A' <= c && c <= 'Z' {
+			c += 'a' - 'A'
+		}
+		lower[i] = c
+	}
+	return lower
+}
+
+// Returns True if the list contains the string.
+func contains(list []string, str string) bool {
+	for _, s := range list {
+		if s == str {
+			return true
+		}
+	}
+	return false
+}
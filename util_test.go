@@ -1,6 +1,10 @@
 package main
 
 import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -14,7 +18,27 @@ func TestAppendExt(t *testing.T) {
 }
 
 func TestHasMatter(t *testing.T) {
-	// TODO
+	dir, err := ioutil.TempDir("", "jkl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tests := map[string]bool{
+		"---\ntitle: yaml\n---\n":           true,
+		";;;\n{\"title\": \"json\"}\n;;;\n": true,
+		"{\"title\": \"json\"}\n":           true,
+		"# no front matter\n":               false}
+
+	for key, val := range tests {
+		fn := filepath.Join(dir, "page.md")
+		if err := ioutil.WriteFile(fn, []byte(key), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if result := hasMatter(fn, isMarkdown(fn)); result != val {
+			t.Errorf("Expected hasMatter value of [%v] got [%v] for content [%s]", val, result, key)
+		}
+	}
 }
 
 func TestIsHiddenOrTemp(t *testing.T) {
@@ -118,3 +142,174 @@ func TestPrettyUrl(t *testing.T) {
 		t.Errorf("Expected replaced path [/test/index.html] got [%s]", path)
 	}
 }
+
+func TestCheckUrl(t *testing.T) {
+	tests := map[string]bool{
+		"index.html":                true,
+		"2013/01/01/post/":          true,
+		"/about/index.html":         true,
+		"..foo/index.html":          true,
+		"../index.html":             false,
+		"about/../../index.html":    false,
+		"about\\..\\..\\index.html": false,
+		"..":                        false}
+
+	for key, val := range tests {
+		if result := checkUrl(key) == nil; result != val {
+			t.Errorf("Expected checkUrl value of [%v] got [%v] for url [%s]", val, result, key)
+		}
+	}
+}
+
+func TestRewriteUrls(t *testing.T) {
+	base := "/blog/2013/01/01/post/"
+	tests := map[string]string{
+		`<img src="image.png">`:                   `<img src="/blog/2013/01/01/post/image.png">`,
+		`<img src='img/image.png'>`:               `<img src='/blog/2013/01/01/post/img/image.png'>`,
+		`<a href="../other/">other</a>`:           `<a href="/blog/2013/01/01/other/">other</a>`,
+		`<a href="/about/">about</a>`:             `<a href="/about/">about</a>`,
+		`<a href="#top">top</a>`:                  `<a href="#top">top</a>`,
+		`<a href="http://golang.org">go</a>`:      `<a href="http://golang.org">go</a>`,
+		`<a href="//golang.org">go</a>`:           `<a href="//golang.org">go</a>`,
+		`<a href="mailto:me@example.com">me</a>`:  `<a href="mailto:me@example.com">me</a>`,
+		`<img src="a.png"> and <img src="b.png">`: `<img src="/blog/2013/01/01/post/a.png"> and <img src="/blog/2013/01/01/post/b.png">`}
+
+	for key, val := range tests {
+		if result := rewriteUrls(key, base); result != val {
+			t.Errorf("Expected rewritten html [%s] got [%s]", val, result)
+		}
+	}
+}
+
+func TestIsSass(t *testing.T) {
+	tests := map[string]bool{
+		"css/main.scss":  true,
+		"css/main.sass":  true,
+		"css/main.css":   false,
+		"css/main.scss~": false}
+
+	for key, val := range tests {
+		if result := isSass(key); result != val {
+			t.Errorf("Expected isSass value of [%v] got [%v] for file [%s]", val, result, key)
+		}
+	}
+}
+
+func TestIsPartial(t *testing.T) {
+	tests := map[string]bool{
+		"css/_vars.scss": true,
+		"_base.scss":     true,
+		"css/main.scss":  false}
+
+	for key, val := range tests {
+		if result := isPartial(key); result != val {
+			t.Errorf("Expected isPartial value of [%v] got [%v] for file [%s]", val, result, key)
+		}
+	}
+}
+
+func TestMatchAny(t *testing.T) {
+	patterns := []string{"node_modules", "*.gemspec", "/vendor/", "docs/*.tmp"}
+	tests := map[string]bool{
+		"node_modules":         true,
+		"node_modules/lib.js":  true,
+		"jkl.gemspec":          true,
+		"vendor/bundle/gem.rb": true,
+		"docs/page.tmp":        true,
+		"docs/page.html":       false,
+		"src/node_modules.go":  false}
+
+	for key, val := range tests {
+		if result := matchAny(patterns, key); result != val {
+			t.Errorf("Expected matchAny value of [%v] got [%v] for path [%s]", val, result, key)
+		}
+	}
+}
+
+func TestSlugify(t *testing.T) {
+	tests := map[string]string{
+		"Go":            "go",
+		"Hello, World!": "hello-world",
+		"  go  lang ":   "go-lang",
+		"Übersicht":     "übersicht",
+		"c++/templates": "c-templates"}
+
+	for key, val := range tests {
+		if result := slugify(key); result != val {
+			t.Errorf("Expected slug [%s] got [%s] for [%s]", val, result, key)
+		}
+	}
+}
+
+func TestParseEncoding(t *testing.T) {
+	for _, name := range []string{"", "utf-8", "UTF8"} {
+		if enc, err := parseEncoding(name); enc != nil || err != nil {
+			t.Errorf("Expected no decoding for encoding [%s] got %v %v", name, enc, err)
+		}
+	}
+	if enc, err := parseEncoding("latin1"); enc == nil || err != nil {
+		t.Errorf("Expected latin1 encoding got %v %v", enc, err)
+	}
+	if _, err := parseEncoding("klingon"); err == nil {
+		t.Errorf("Expected error for unknown encoding")
+	}
+}
+
+func TestInjectHead(t *testing.T) {
+	tests := map[string]string{
+		"<html><HEAD><title>x</title></HEAD><body></body></html>": "<html><HEAD><title>x</title><meta></HEAD><body></body></html>",
+		"<p>no head</p>":                    "<meta><p>no head</p>",
+		"<head>\u212a\u212a</head>\xff\xff": "<head>\u212a\u212a<meta></head>\xff\xff",
+	}
+	for html, expected := range tests {
+		if b := string(injectHead([]byte(html), "<meta>")); b != expected {
+			t.Errorf("Expected [%s] got [%s]", expected, b)
+		}
+	}
+}
+
+func TestCopyTo(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jkl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// larger than the buffer, so it is copied in several reads
+	content := make([]byte, 10000)
+	for i := range content {
+		content[i] = byte(i)
+	}
+	from := filepath.Join(dir, "video.mp4")
+	if err := ioutil.WriteFile(from, content, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	to := filepath.Join(dir, "out", "media", "video.mp4")
+	if err := copyTo(from, to, make([]byte, 512)); err != nil {
+		t.Fatal(err)
+	}
+	b, _ := ioutil.ReadFile(to)
+	if !bytes.Equal(b, content) {
+		t.Errorf("Expected the copy to have the same content")
+	}
+	if fi, err := os.Stat(to); err != nil || fi.Mode().Perm() != 0600 {
+		t.Errorf("Expected the copy to have the same permissions, got %v %v", fi.Mode(), err)
+	}
+
+	if !sameContent(from, to, 512) {
+		t.Errorf("Expected the files to have the same content")
+	}
+	content[9999]++
+	ioutil.WriteFile(to, content, 0600)
+	if sameContent(from, to, 512) {
+		t.Errorf("Expected the files to have different content")
+	}
+	if sameContent(from, filepath.Join(dir, "missing"), 512) {
+		t.Errorf("Expected a missing file to have different content")
+	}
+	ioutil.WriteFile(to, content[:100], 0600)
+	if sameContent(from, to, 512) {
+		t.Errorf("Expected files of different sizes to have different content")
+	}
+}
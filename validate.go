@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"golang.org/x/net/html"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Elements that have no end tag.
+var voidElements = []string{
+	"area", "base", "br", "col", "embed", "hr", "img", "input", "link",
+	"meta", "param", "source", "track", "wbr"}
+
+// Elements whose end tag may be left out.
+var optionalEndElements = []string{
+	"body", "colgroup", "dd", "dt", "head", "html", "li", "optgroup",
+	"option", "p", "rp", "rt", "tbody", "td", "tfoot", "th", "thead", "tr"}
+
+// Helper function that checks the html of the generated site for unclosed
+// tags, duplicate ids and images without an alt attribute, when enabled
+// with validate_html in the _config.yml, or the --validate flag. Problems are
+// printed as warnings, unless validate_html is strict, or with the --strict
+// flag, in which case the build fails listing every file that has any.
+func (s *Site) validateHtml() error {
+	var strict bool
+	switch v := s.Conf.Get("validate_html").(type) {
+	case bool:
+		if !v {
+			return nil
+		}
+	case string:
+		strict = v == "strict"
+	default:
+		return nil
+	}
+
+	var errs BuildErrors
+	err := filepath.Walk(s.Dest, func(fn string, fi os.FileInfo, err error) error {
+		if err != nil || fi.IsDir() {
+			return err
+		}
+		if ext := filepath.Ext(fn); ext != ".html" && ext != ".htm" {
+			return nil
+		}
+		b, err := ioutil.ReadFile(fn)
+		if err != nil {
+			return err
+		}
+		if problems := checkHtml(b); len(problems) > 0 {
+			rel, _ := filepath.Rel(s.Dest, fn)
+			errs = append(errs, newBuildError(rel, errors.New(strings.Join(problems, "; "))))
+		}
+		return nil
+	})
+	if err != nil || len(errs) == 0 {
+		return err
+	}
+
+	sort.Sort(byPath(errs))
+	if strict {
+		return errs
+	}
+	for _, err := range errs {
+		fmt.Printf(MsgInvalidFile+"\n", err)
+	}
+	return nil
+}
+
+// Helper function that returns the problems found in an html document, each
+// with the line it is on. The html is only tokenized, not parsed, so that
+// tags the parser would silently close are reported.
+func checkHtml(b []byte) []string {
+	problems := []string{}
+	report := func(line int, format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf("line %d: ", line)+fmt.Sprintf(format, args...))
+	}
+
+	type openTag struct {
+		name string
+		line int
+	}
+	open := []openTag{}
+	ids := map[string]int{}
+	line := 1
+
+	z := html.NewTokenizer(bytes.NewReader(b))
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			if z.Err() != io.EOF {
+				report(line, "%s", z.Err())
+			}
+			break
+		}
+		tagLine := line
+		line += bytes.Count(z.Raw(), []byte("\n"))
+
+		switch tt {
+		case html.StartTagToken, html.SelfClosingTagToken:
+			t := z.Token()
+			name := t.Data
+			alt := false
+			for _, attr := range t.Attr {
+				switch attr.Key {
+				case "id":
+					if first, ok := ids[attr.Val]; ok {
+						report(tagLine, "duplicate id %q, first used on line %d", attr.Val, first)
+					} else {
+						ids[attr.Val] = tagLine
+					}
+				case "alt":
+					alt = true
+				}
+			}
+			if name == "img" && !alt {
+				report(tagLine, "<img> has no alt attribute")
+			}
+			if tt == html.StartTagToken && !contains(voidElements, name) {
+				open = append(open, openTag{name, tagLine})
+			}
+
+		case html.EndTagToken:
+			name := z.Token().Data
+			i := len(open) - 1
+			for i >= 0 && open[i].name != name {
+				i--
+			}
+			if i < 0 {
+				report(tagLine, "</%s> has no opening tag", name)
+				continue
+			}
+			for _, tag := range open[i+1:] {
+				if !contains(optionalEndElements, tag.name) {
+					report(tag.line, "<%s> is not closed", tag.name)
+				}
+			}
+			open = open[:i]
+		}
+	}
+
+	for _, tag := range open {
+		if !contains(optionalEndElements, tag.name) {
+			report(tag.line, "<%s> is not closed", tag.name)
+		}
+	}
+	return problems
+}
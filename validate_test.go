@@ -0,0 +1,53 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCheckHtml(t *testing.T) {
+	tests := map[string][]string{
+		"<!DOCTYPE html><html><head><meta charset=\"utf-8\"><title>Ok</title></head>\n" +
+			"<body><ul><li>one<li>two</ul><p>text<br><img src=\"a.png\" alt=\"\"></body></html>": {},
+		"<div>\n<section id=\"a\">\n<span id=\"a\">x</span>\n</div>": {
+			"line 3: duplicate id \"a\", first used on line 2",
+			"line 2: <section> is not closed",
+		},
+		"<p>\n<img src=\"a.png\">\n<img src=\"b.png\"/></p></em>": {
+			"line 2: <img> has no alt attribute",
+			"line 3: <img> has no alt attribute",
+			"line 3: </em> has no opening tag",
+		},
+		"<main>\n<script>if (a < b) { document.write('<div>') }</script>\n": {
+			"line 1: <main> is not closed",
+		},
+	}
+	for src, expected := range tests {
+		if problems := checkHtml([]byte(src)); !reflect.DeepEqual(problems, expected) {
+			t.Errorf("Expected problems %q for [%s] got %q", expected, src, problems)
+		}
+	}
+}
+
+func TestValidateHtml(t *testing.T) {
+	files := map[string]string{
+		"_config.yml":           "",
+		"_layouts/default.html": "<div>{{.content}}</div>",
+		"index.html":            "---\n---\n<img src=\"logo.png\">",
+		"about.html":            "---\n---\nabout",
+		"feed.xml":              "<feed><link href=\"/\"></feed>"}
+
+	site, cleanup := newTestSiteFlags(t, files, Config{"validate_html": true})
+	defer cleanup()
+	if err := site.Generate(); err != nil {
+		t.Errorf("Expected html problems to be warnings, got %v", err)
+	}
+
+	site.Conf.Set("validate_html", "strict")
+	err := site.Generate()
+	if err == nil || !strings.Contains(err.Error(), "1 file(s)") ||
+		!strings.Contains(err.Error(), "index.html: line 1: <img> has no alt attribute") {
+		t.Errorf("Expected the strict validation to fail on index.html, got %v", err)
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"path"
+)
+
+// Helper function that returns the variants of a page declared in its front
+// matter, such as landing pages for A/B testing, each a copy of the page with
+// the variant's values merged in:
+//
+//	variants:
+//	  - name: b
+//	    headline: Try it free
+//	  - name: c
+//	    permalink: /welcome/
+//	    headline: Start today
+//
+// A variant is written to its name under the page's directory, e.g.
+// landing/b/index.html for landing.html, unless it has a permalink of its
+// own. Each variant has its variant name, and the canonical_url of the page.
+// Returns an error if a variant would overwrite any of the urls, which the
+// variants are added to.
+func (s *Site) variants(page Page, urls map[string]bool) ([]Page, error) {
+	list, ok := page["variants"].([]interface{})
+	if !ok {
+		return nil, nil
+	}
+
+	dir := removeExt(page.GetUrl())
+	if path.Base(page.GetUrl()) == "index.html" {
+		dir = path.Dir(page.GetUrl())
+	}
+
+	variants := []Page{}
+	for i, item := range list {
+		fields, ok := item.(map[interface{}]interface{})
+		if !ok {
+			return nil, fmt.Errorf("Invalid variant %d. Expecting a name and values", i+1)
+		}
+		v := Page{}
+		for k, val := range page {
+			switch k {
+			case "variants", "redirect_from", "permalink":
+			default:
+				v[k] = val
+			}
+		}
+		for k, val := range fields {
+			v[fmt.Sprint(k)] = val
+		}
+
+		name := v.GetString("name")
+		if name == "" || name != slugify(name) {
+			return nil, fmt.Errorf("Invalid variant name %q. Expecting a slug, such as b", name)
+		}
+		v["variant"] = name
+		v["canonical_url"] = s.pageUrl(page)
+
+		var err error
+		if permalink := v.GetString("permalink"); permalink != "" {
+			err = v.setUrl(permalinkUrl(collapseSlashes(permalink)))
+		} else {
+			err = v.setUrl(path.Join(dir, name, "index.html"))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("variant %s: %s", name, err)
+		}
+		if urls[v.GetUrl()] {
+			return nil, fmt.Errorf("variant %s: %s is already generated", name, v.GetUrl())
+		}
+		urls[v.GetUrl()] = true
+		variants = append(variants, v)
+	}
+	return variants, nil
+}
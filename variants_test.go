@@ -0,0 +1,52 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestVariants(t *testing.T) {
+	site, cleanup := newTestSite(t, map[string]string{
+		"_config.yml":           "url: http://example.com\n",
+		"_layouts/default.html": "<head></head>{{.page.headline}}{{with .page.variant}} ({{.}}){{end}}",
+		"landing.html": "---\nheadline: Welcome\nvariants:\n" +
+			"  - name: b\n    headline: Try it free\n" +
+			"  - name: c\n    permalink: /welcome/\n    headline: Start today\n---\n"})
+	defer cleanup()
+
+	if err := site.Generate(); err != nil {
+		t.Fatal(err)
+	}
+	for fn, expected := range map[string]string{
+		"landing.html":         "<head></head>Welcome",
+		"landing/b/index.html": "<head><link rel=\"canonical\" href=\"http://example.com/landing.html\"></head>Try it free (b)",
+		"welcome/index.html":   "<head><link rel=\"canonical\" href=\"http://example.com/landing.html\"></head>Start today (c)",
+	} {
+		if b, _ := ioutil.ReadFile(filepath.Join(site.Dest, fn)); string(b) != expected {
+			t.Errorf("Expected %s to be [%s] got [%s]", fn, expected, b)
+		}
+	}
+}
+
+func TestVariantsInvalid(t *testing.T) {
+	tests := map[string]string{
+		"  - name: B C\n":                       "Invalid variant name",
+		"  - name: b\n  - name: b\n":            "variant b: landing/b/index.html is already generated",
+		"  - name: b\n    permalink: /about/\n": "variant b: about/index.html is already generated",
+		"  - name: b\n    permalink: /../x/\n":  ErrBadPermalink.Error(),
+	}
+	for variants, msg := range tests {
+		site, cleanup := newTestSite(t, map[string]string{
+			"_config.yml":           "",
+			"_layouts/default.html": "{{.content}}",
+			"about.md":              "---\npermalink: /about/\n---\nabout",
+			"landing.html":          "---\nvariants:\n" + variants + "---\n"})
+		err := site.Generate()
+		if err == nil || !strings.Contains(err.Error(), msg) {
+			t.Errorf("Expected error [%s] for variants [%s] got %v", msg, variants, err)
+		}
+		cleanup()
+	}
+}
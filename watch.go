@@ -0,0 +1,137 @@
+package main
+
+import (
+	"github.com/howeyc/fsnotify"
+
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Default time, in milliseconds, to wait for a burst of file changes to
+// settle before re-generating the site.
+const defaultWatchDebounce = 300
+
+func watch(site *Site) {
+
+	// Setup the inotify watcher
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	// Get recursive list of directories to watch
+	for _, path := range site.watchDirs() {
+		if err := watcher.Watch(path); err != nil {
+			fmt.Println(err)
+			return
+		}
+	}
+
+	// Re-generate the site once for each burst of changes, such as an
+	// editor writing a swap file, renaming it and touching the original
+	delay := site.Conf.GetInt("watch_debounce", defaultWatchDebounce)
+	changes := make(chan string)
+	go debounce(changes, time.Duration(delay)*time.Millisecond, func() {
+		recompile(site)
+	})
+
+	for {
+		select {
+		case ev := <-watcher.Event:
+			// Ignore changes to the _site directoy, hidden, temp or
+			// excluded files
+			if !site.isWatchIgnored(ev.Name) {
+				fmt.Println("Event: ", ev.String())
+				changes <- ev.Name
+			}
+		case err := <-watcher.Error:
+			fmt.Println("inotify error:", err)
+		}
+	}
+}
+
+func recompile(site *Site) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if err := site.Reload(); err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	if err := site.Generate(); err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	reloader.Reload()
+}
+
+// Helper function that calls fn once no event has arrived for the given
+// delay, so that a burst of events triggers a single call. Any pending call
+// is made when the events channel is closed, and then debounce returns.
+func debounce(events <-chan string, delay time.Duration, fn func()) {
+	var timer <-chan time.Time
+	for {
+		select {
+		case _, ok := <-events:
+			if !ok {
+				if timer != nil {
+					fn()
+				}
+				return
+			}
+			timer = time.After(delay)
+		case <-timer:
+			timer = nil
+			fn()
+		}
+	}
+}
+
+// Returns True if a change to the file should not re-generate the site.
+// Changes to the destination directory or mirrors, to hidden or temp files
+// (such as editor swap and backup files) and to files excluded in the
+// _config.yml are ignored, the same as when the site is generated.
+func (s *Site) isWatchIgnored(fn string) bool {
+	if s.isDestPath(fn) {
+		return true
+	}
+	rel, err := filepath.Rel(s.Src, fn)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return true
+	}
+	if s.isIncluded(rel) {
+		return false
+	}
+	for _, name := range strings.Split(rel, string(filepath.Separator)) {
+		if isHiddenOrTemp(name) {
+			return true
+		}
+	}
+	return s.isExcluded(rel)
+}
+
+// Helper function that returns a recursive list of the directories in the
+// source directory to watch for changes.
+func (s *Site) watchDirs() (paths []string) {
+	filepath.Walk(s.Src, func(fn string, fi os.FileInfo, err error) error {
+		rel, _ := filepath.Rel(s.Src, fn)
+		switch {
+		case err != nil:
+			return nil
+		case !fi.IsDir():
+			return nil
+		case rel != "." && s.isWatchIgnored(fn) && !s.isIncludedDir(rel):
+			return filepath.SkipDir
+		}
+
+		paths = append(paths, fn)
+		return nil
+	})
+	return
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestIsWatchIgnored(t *testing.T) {
+	site := Site{
+		Src:  "/blog",
+		Dest: "/blog/_site",
+		Conf: Config{"exclude": []interface{}{"node_modules", "*.log"}, "include": []interface{}{".htaccess"}}}
+
+	tests := map[string]bool{
+		"/blog/index.html":              false,
+		"/blog/_posts/2013-02-14-a.md":  false,
+		"/blog/.htaccess":               false,
+		"/blog/_site/index.html":        true,
+		"/blog/_site":                   true,
+		"/blog/_posts/.2013-02-14.swp":  true,
+		"/blog/_posts/2013-02-14-a.md~": true,
+		"/blog/.git/index":              true,
+		"/blog/node_modules/lib/a.js":   true,
+		"/blog/debug.log":               true,
+		"/other/index.html":             true,
+	}
+	for fn, val := range tests {
+		if result := site.isWatchIgnored(fn); result != val {
+			t.Errorf("Expected isWatchIgnored [%v] got [%v] for [%s]", val, result, fn)
+		}
+	}
+}
+
+func TestWatchDirs(t *testing.T) {
+	site, cleanup := newTestSite(t, map[string]string{
+		"_config.yml":          "exclude: [node_modules]\n",
+		"_posts/a.md":          "",
+		"_site/index.html":     "",
+		".git/config":          "",
+		"node_modules/a/b.js":  "",
+		"css/vendor/style.css": ""})
+	defer cleanup()
+	os.MkdirAll(site.Dest, 0755)
+
+	dirs := []string{}
+	for _, dir := range site.watchDirs() {
+		rel, _ := filepath.Rel(site.Src, dir)
+		dirs = append(dirs, rel)
+	}
+	expected := []string{".", "_posts", "css", "css/vendor"}
+	if !reflect.DeepEqual(dirs, expected) {
+		t.Errorf("Expected watched dirs %v got %v", expected, dirs)
+	}
+}
+
+func TestDebounce(t *testing.T) {
+	var calls int32
+	events := make(chan string)
+	done := make(chan bool)
+	go func() {
+		debounce(events, 50*time.Millisecond, func() { atomic.AddInt32(&calls, 1) })
+		done <- true
+	}()
+
+	// a burst of events triggers a single call
+	for _, fn := range []string{"a.md.swp", "a.md~", "a.md"} {
+		events <- fn
+		time.Sleep(5 * time.Millisecond)
+	}
+	time.Sleep(150 * time.Millisecond)
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Errorf("Expected 1 call after a burst of events, got %d", n)
+	}
+
+	// a pending call is made when the events stop
+	events <- "b.md"
+	close(events)
+	<-done
+	if n := atomic.LoadInt32(&calls); n != 2 {
+		t.Errorf("Expected pending call when closed, got %d calls", n)
+	}
+}